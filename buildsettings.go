@@ -0,0 +1,300 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BuildSettings holds the build settings recorded in the build information
+// in typed form. Settings that were not recorded have their zero value.
+type BuildSettings struct {
+	// GOOS is the target operating system.
+	GOOS string
+	// GOARCH is the target architecture.
+	GOARCH string
+	// ArchLevel is the value of the architecture specific setting for the
+	// target, for example GOAMD64 for amd64 or GOARM for arm.
+	ArchLevel string
+	// BuildMode is the -buildmode flag.
+	BuildMode string
+	// Compiler is the -compiler flag.
+	Compiler string
+	// CGOEnabled is true if the binary was built with cgo enabled.
+	CGOEnabled bool
+	// CGOCFlags is the CGO_CFLAGS environment variable.
+	CGOCFlags string
+	// CGOCPPFlags is the CGO_CPPFLAGS environment variable.
+	CGOCPPFlags string
+	// CGOCXXFlags is the CGO_CXXFLAGS environment variable.
+	CGOCXXFlags string
+	// CGOLDFlags is the CGO_LDFLAGS environment variable.
+	CGOLDFlags string
+	// LDFlags is the -ldflags flag.
+	LDFlags string
+	// GCFlags is the -gcflags flag.
+	GCFlags string
+	// ASMFlags is the -asmflags flag.
+	ASMFlags string
+	// Tags are the build tags given with the -tags flag.
+	Tags []string
+	// Trimpath is true if the binary was built with -trimpath.
+	Trimpath bool
+	// PGO is the path of the profile used for profile-guided optimization.
+	PGO string
+	// Race is true if the binary was built with -race.
+	Race bool
+	// MSan is true if the binary was built with -msan.
+	MSan bool
+	// ASan is true if the binary was built with -asan.
+	ASan bool
+	// Cover is true if the binary was built with -cover.
+	Cover bool
+	// VCS is the version control system of the main module, e.g. git.
+	VCS string
+	// VCSRevision is the revision of the main module.
+	VCSRevision string
+	// VCSTime is the commit time of the revision.
+	VCSTime time.Time
+	// VCSModified is true if the working tree had uncommitted changes.
+	VCSModified bool
+	// DefaultGODEBUG is the default GODEBUG setting of the binary.
+	DefaultGODEBUG string
+}
+
+// archLevelSettings maps GOARCH values to the setting that holds the
+// architecture level.
+var archLevelSettings = map[string]string{
+	"386":      "GO386",
+	"amd64":    "GOAMD64",
+	"arm":      "GOARM",
+	"arm64":    "GOARM64",
+	"mips":     "GOMIPS",
+	"mipsle":   "GOMIPS",
+	"mips64":   "GOMIPS64",
+	"mips64le": "GOMIPS64",
+	"ppc64":    "GOPPC64",
+	"ppc64le":  "GOPPC64",
+	"riscv64":  "GORISCV64",
+	"wasm":     "GOWASM",
+}
+
+// BuildSettings returns the build settings in typed form. Nil is returned if
+// the file has no module information.
+func (b *BuildInfo) BuildSettings() *BuildSettings {
+	if b == nil || b.ModInfo == nil {
+		return nil
+	}
+
+	bs := &BuildSettings{}
+	raw := make(map[string]string, len(b.ModInfo.Settings))
+	for _, s := range b.ModInfo.Settings {
+		raw[s.Key] = s.Value
+	}
+
+	bs.GOOS = raw["GOOS"]
+	bs.GOARCH = raw["GOARCH"]
+	if key, ok := archLevelSettings[bs.GOARCH]; ok {
+		bs.ArchLevel = raw[key]
+	}
+	bs.BuildMode = raw["-buildmode"]
+	bs.Compiler = raw["-compiler"]
+	bs.CGOEnabled = raw["CGO_ENABLED"] == "1"
+	bs.CGOCFlags = raw["CGO_CFLAGS"]
+	bs.CGOCPPFlags = raw["CGO_CPPFLAGS"]
+	bs.CGOCXXFlags = raw["CGO_CXXFLAGS"]
+	bs.CGOLDFlags = raw["CGO_LDFLAGS"]
+	bs.LDFlags = raw["-ldflags"]
+	bs.GCFlags = raw["-gcflags"]
+	bs.ASMFlags = raw["-asmflags"]
+	if tags := raw["-tags"]; tags != "" {
+		bs.Tags = strings.Split(tags, ",")
+	}
+	bs.Trimpath = raw["-trimpath"] == "true"
+	bs.PGO = raw["-pgo"]
+	bs.Race = raw["-race"] == "true"
+	bs.MSan = raw["-msan"] == "true"
+	bs.ASan = raw["-asan"] == "true"
+	bs.Cover = raw["-cover"] == "true"
+	bs.VCS = raw["vcs"]
+	bs.VCSRevision = raw["vcs.revision"]
+	if t, err := time.Parse(time.RFC3339Nano, raw["vcs.time"]); err == nil {
+		bs.VCSTime = t
+	}
+	bs.VCSModified = raw["vcs.modified"] == "true"
+	bs.DefaultGODEBUG = raw["DefaultGODEBUG"]
+	return bs
+}
+
+// BuildInconsistency is a recorded build setting that doesn't match what was
+// detected from the binary itself.
+type BuildInconsistency struct {
+	// Setting is the name of the build setting, for example GOARCH.
+	Setting string
+	// Recorded is the value in the build information.
+	Recorded string
+	// Detected is the value detected from the binary.
+	Detected string
+}
+
+// String returns a description of the inconsistency.
+func (b *BuildInconsistency) String() string {
+	return fmt.Sprintf("%s is %q in the build information but %q was detected", b.Setting, b.Recorded, b.Detected)
+}
+
+// CheckBuildSettings compares the build information with what is detected
+// independently of it and returns the settings that don't match. A binary
+// built by an unmodified toolchain has no inconsistencies, so they indicate
+// that the build information has been tampered with.
+//
+// The following is checked:
+//
+//   - GOARCH against the architecture in the file header.
+//   - GOOS against the file format.
+//   - The Go version against the version loaded by runtime.schedinit.
+//   - -trimpath against the GOROOT and the file path of the main package.
+//   - CGO_ENABLED against the presence of the runtime/cgo package.
+//
+// Checks are skipped if the value can't be detected from the binary.
+// ErrNoBuildInfo is returned if the file has no build information.
+func (f *GoFile) CheckBuildSettings() ([]*BuildInconsistency, error) {
+	bs := f.BuildInfo.BuildSettings()
+	if bs == nil || f.BuildInfo.Inferred {
+		return nil, ErrNoBuildInfo
+	}
+
+	var result []*BuildInconsistency
+	add := func(setting, recorded, detected string) {
+		result = append(result, &BuildInconsistency{Setting: setting, Recorded: recorded, Detected: detected})
+	}
+
+	if arch := goArch(f.FileInfo.Arch); arch != "" && bs.GOARCH != "" && !sameArchFamily(bs.GOARCH, arch) {
+		add("GOARCH", bs.GOARCH, arch)
+	}
+
+	if format, ok := fileFormatOf(f.fh); ok && bs.GOOS != "" && !format.matches(bs.GOOS) {
+		add("GOOS", bs.GOOS, format.name)
+	}
+
+	if v := tryFromSchedInit(f); v != nil {
+		recorded := strings.Fields(f.BuildInfo.ModInfo.GoVersion)
+		if len(recorded) != 0 && recorded[0] != v.Name {
+			add("go", recorded[0], v.Name)
+		}
+	}
+
+	pkgs, err := f.GetPackages()
+	if err != nil {
+		return nil, err
+	}
+	goroot, err := findGoRootPath(f)
+	if err != nil && !errors.Is(err, ErrNoGoRootFound) {
+		return nil, err
+	}
+	var mainPath string
+	for _, p := range pkgs {
+		if p.Name == "main" {
+			mainPath = p.Filepath
+		}
+	}
+	switch {
+	case bs.Trimpath && isAbsPath(goroot):
+		add("-trimpath", "true", "GOROOT "+goroot)
+	case bs.Trimpath && isAbsPath(mainPath):
+		add("-trimpath", "true", "main package path "+mainPath)
+	case !bs.Trimpath && mainPath != "" && !isAbsPath(mainPath):
+		add("-trimpath", "false", "main package path "+mainPath)
+	}
+
+	all, err := f.allPackages()
+	if err != nil {
+		return nil, err
+	}
+	hasCgo := false
+	for _, p := range all {
+		if p.Name == "runtime/cgo" {
+			hasCgo = true
+			break
+		}
+	}
+	if !bs.CGOEnabled && hasCgo {
+		add("CGO_ENABLED", "0", "runtime/cgo is linked")
+	}
+
+	return result, nil
+}
+
+// goArch returns the GOARCH value for the architecture in FileInfo.
+func goArch(arch string) string {
+	if arch == Arch386 {
+		return "386"
+	}
+	return arch
+}
+
+// sameArchFamily returns true if the GOARCH value is the detected architecture.
+// The header doesn't tell the endianness variants of mips apart.
+func sameArchFamily(goarch, detected string) bool {
+	if detected == ArchMIPS {
+		return strings.HasPrefix(goarch, "mips")
+	}
+	return goarch == detected
+}
+
+// fileFormat is the set of GOOS values that use a file format.
+type fileFormat struct {
+	name string
+	// goos is the list of GOOS values. If exclude is true, the format is
+	// used by every GOOS not in the list.
+	goos    []string
+	exclude bool
+}
+
+func (f fileFormat) matches(goos string) bool {
+	for _, g := range f.goos {
+		if g == goos {
+			return !f.exclude
+		}
+	}
+	return f.exclude
+}
+
+func fileFormatOf(fh fileHandler) (fileFormat, bool) {
+	switch fh.(type) {
+	case *elfFile:
+		return fileFormat{name: "ELF", goos: []string{"windows", "darwin", "ios", "js", "wasip1", "plan9"}, exclude: true}, true
+	case *peFile:
+		return fileFormat{name: "PE", goos: []string{"windows"}}, true
+	case *machoFile:
+		return fileFormat{name: "Mach-O", goos: []string{"darwin", "ios"}}, true
+	case *wasmFile:
+		return fileFormat{name: "WebAssembly", goos: []string{"js", "wasip1"}}, true
+	}
+	return fileFormat{}, false
+}
+
+// isAbsPath returns true for absolute Unix and Windows paths.
+func isAbsPath(p string) bool {
+	if strings.HasPrefix(p, "/") || strings.HasPrefix(p, `\\`) {
+		return true
+	}
+	return len(p) >= 3 && p[1] == ':' && (p[2] == '\\' || p[2] == '/')
+}
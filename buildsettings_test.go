@@ -0,0 +1,193 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/pe"
+	"encoding/binary"
+	"os"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildSettings(t *testing.T) {
+	assert := assert.New(t)
+
+	bi := &BuildInfo{ModInfo: &debug.BuildInfo{Settings: []debug.BuildSetting{
+		{Key: "-buildmode", Value: "exe"},
+		{Key: "-compiler", Value: "gc"},
+		{Key: "-ldflags", Value: "-s -w"},
+		{Key: "-tags", Value: "netgo,osusergo"},
+		{Key: "-trimpath", Value: "true"},
+		{Key: "-pgo", Value: "/src/default.pgo"},
+		{Key: "-race", Value: "true"},
+		{Key: "-asan", Value: "false"},
+		{Key: "CGO_ENABLED", Value: "1"},
+		{Key: "CGO_CFLAGS", Value: "-O2 -g"},
+		{Key: "GOARCH", Value: "arm"},
+		{Key: "GOOS", Value: "linux"},
+		{Key: "GOARM", Value: "7"},
+		{Key: "GOAMD64", Value: "v3"},
+		{Key: "vcs", Value: "git"},
+		{Key: "vcs.revision", Value: "0123456789abcdef"},
+		{Key: "vcs.time", Value: "2024-05-01T12:30:00Z"},
+		{Key: "vcs.modified", Value: "true"},
+	}}}
+
+	bs := bi.BuildSettings()
+	assert.Equal("linux", bs.GOOS)
+	assert.Equal("arm", bs.GOARCH)
+	assert.Equal("7", bs.ArchLevel, "Level of the target architecture should be used")
+	assert.Equal("exe", bs.BuildMode)
+	assert.Equal("gc", bs.Compiler)
+	assert.True(bs.CGOEnabled)
+	assert.Equal("-O2 -g", bs.CGOCFlags)
+	assert.Equal("-s -w", bs.LDFlags)
+	assert.Equal([]string{"netgo", "osusergo"}, bs.Tags)
+	assert.True(bs.Trimpath)
+	assert.Equal("/src/default.pgo", bs.PGO)
+	assert.True(bs.Race)
+	assert.False(bs.ASan)
+	assert.False(bs.MSan)
+	assert.Equal("git", bs.VCS)
+	assert.Equal("0123456789abcdef", bs.VCSRevision)
+	assert.Equal(time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC), bs.VCSTime)
+	assert.True(bs.VCSModified)
+
+	assert.Nil((&BuildInfo{}).BuildSettings())
+	assert.Nil((*BuildInfo)(nil).BuildSettings())
+}
+
+func TestCheckBuildSettings(t *testing.T) {
+	for _, trimpath := range []bool{false, true} {
+		var args []string
+		if trimpath {
+			args = append(args, "-trimpath")
+		}
+		exe := buildTestBinary(t, testresourcesrc, args...)
+
+		f, err := Open(exe)
+		require.NoError(t, err)
+		defer f.Close()
+
+		issues, err := f.CheckBuildSettings()
+		require.NoError(t, err)
+		assert.Empty(t, issues, "Unmodified build should be consistent")
+
+		// Tamper with the build information.
+		settings := f.BuildInfo.ModInfo.Settings
+		for i, s := range settings {
+			switch s.Key {
+			case "GOARCH":
+				settings[i].Value = "arm64"
+			case "GOOS":
+				settings[i].Value = "windows"
+			case "CGO_ENABLED":
+				settings[i].Value = "1"
+			}
+		}
+		if trimpath {
+			f.BuildInfo.ModInfo.Settings = settings[:0]
+			for _, s := range settings {
+				if s.Key != "-trimpath" {
+					f.BuildInfo.ModInfo.Settings = append(f.BuildInfo.ModInfo.Settings, s)
+				}
+			}
+		} else {
+			f.BuildInfo.ModInfo.Settings = append(settings, debug.BuildSetting{Key: "-trimpath", Value: "true"})
+		}
+
+		issues, err = f.CheckBuildSettings()
+		require.NoError(t, err)
+		var names []string
+		for _, i := range issues {
+			names = append(names, i.Setting)
+		}
+		assert.Equal(t, []string{"GOARCH", "GOOS", "-trimpath"}, names)
+	}
+}
+
+func TestCheckBuildSettingsARM64(t *testing.T) {
+	elfData, err := os.ReadFile(buildTestBinary(t, testresourcesrc))
+	require.NoError(t, err)
+	peData, err := os.ReadFile(buildTestBinaryFor(t, "windows", "amd64", testresourcesrc))
+	require.NoError(t, err)
+	peARM64, err := os.ReadFile(buildTestBinaryFor(t, "windows", "arm64", testresourcesrc))
+	require.NoError(t, err)
+	// The PE header follows the signature at the offset stored at 0x3c.
+	peMachine := binary.LittleEndian.Uint32(peData[0x3c:]) + 4
+
+	for _, test := range []struct {
+		name     string
+		data     []byte
+		offset   int
+		machine  uint16
+		arch     string
+		detected string
+	}{
+		// The machine in the header is changed from x86-64 to AArch64, so
+		// the recorded GOARCH doesn't match.
+		{"elf", elfData, 18, uint16(elf.EM_AARCH64), ArchARM64, "arm64"},
+		{"pe", peData, int(peMachine), pe.IMAGE_FILE_MACHINE_ARM64, ArchARM64, "arm64"},
+		// An unknown machine isn't checked.
+		{"pe unknown", peData, int(peMachine), pe.IMAGE_FILE_MACHINE_RISCV64, "", ""},
+		{"pe arm64 build", peARM64, -1, 0, ArchARM64, ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			data := bytes.Clone(test.data)
+			if test.offset >= 0 {
+				binary.LittleEndian.PutUint16(data[test.offset:], test.machine)
+			}
+
+			f, err := OpenReader(bytes.NewReader(data))
+			require.NoError(t, err)
+			defer f.Close()
+			assert.Equal(t, test.arch, f.FileInfo.Arch)
+
+			issues, err := f.CheckBuildSettings()
+			require.NoError(t, err)
+			var goarch []*BuildInconsistency
+			for _, issue := range issues {
+				if issue.Setting == "GOARCH" {
+					goarch = append(goarch, issue)
+				}
+			}
+			if test.detected == "" {
+				assert.Empty(t, goarch)
+				return
+			}
+			require.Len(t, goarch, 1)
+			assert.Equal(t, "amd64", goarch[0].Recorded)
+			assert.Equal(t, test.detected, goarch[0].Detected)
+		})
+	}
+}
+
+func TestIsAbsPath(t *testing.T) {
+	assert.True(t, isAbsPath("/usr/local/go"))
+	assert.True(t, isAbsPath(`C:\Program Files\Go`))
+	assert.True(t, isAbsPath("c:/go"))
+	assert.False(t, isAbsPath("example.com/app"))
+	assert.False(t, isAbsPath(""))
+}
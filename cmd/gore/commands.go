@@ -0,0 +1,469 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"debug/elf"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+
+	"github.com/blacktop/go-macho"
+
+	"github.com/ZxillyFork/gore"
+)
+
+var infoCmd = &command{
+	name:  "info",
+	short: "Print general information about the file",
+	run: func(s *session) error {
+		f := s.file
+		info := struct {
+			Arch            string `json:"arch"`
+			OS              string `json:"os"`
+			WordSize        int    `json:"wordSize"`
+			ByteOrder       string `json:"byteOrder"`
+			BuildID         string `json:"buildID,omitempty"`
+			Compiler        string `json:"compiler,omitempty"`
+			Toolchain       string `json:"toolchain,omitempty"`
+			Instrumentation string `json:"instrumentation,omitempty"`
+			GoRoot          string `json:"goroot,omitempty"`
+			MainModule      string `json:"mainModule,omitempty"`
+		}{
+			Arch:     f.FileInfo.Arch,
+			OS:       f.FileInfo.OS,
+			WordSize: f.FileInfo.WordSize,
+			BuildID:  f.BuildID,
+		}
+		if f.FileInfo.ByteOrder == binary.BigEndian {
+			info.ByteOrder = "big"
+		} else {
+			info.ByteOrder = "little"
+		}
+		if v, err := f.GetCompilerVersion(); err == nil {
+			info.Compiler = v.Name
+		}
+		if f.FileInfo.Compiler != gore.CompilerGc {
+			info.Compiler = strings.TrimSpace(info.Compiler + " " + f.FileInfo.Compiler)
+		}
+		if t := f.Toolchain(); t.Variant() {
+			info.Toolchain = t.String()
+		}
+		if i, err := f.Instrumentation(); err == nil {
+			info.Instrumentation = strings.Join(i.Names(), ", ")
+		}
+		if f.BuildInfo != nil && f.BuildInfo.ModInfo != nil {
+			info.MainModule = f.BuildInfo.ModInfo.Main.Path
+		}
+
+		// The GOROOT can only be found if the pclntab can be parsed, so this
+		// also tells us if the file is a Go binary.
+		goroot, err := f.GetGoRoot()
+		if err != nil && !errors.Is(err, gore.ErrNoGoRootFound) {
+			return err
+		}
+		info.GoRoot = goroot
+
+		if s.json {
+			return s.printJSON(info)
+		}
+		w := tabwriter.NewWriter(s.out, 0, 0, 1, ' ', 0)
+		fmt.Fprintf(w, "OS:\t%s\n", info.OS)
+		fmt.Fprintf(w, "Arch:\t%s\n", info.Arch)
+		fmt.Fprintf(w, "Word size:\t%d\n", info.WordSize)
+		fmt.Fprintf(w, "Byte order:\t%s\n", info.ByteOrder)
+		printOptional := func(name, val string) {
+			if val != "" {
+				fmt.Fprintf(w, "%s:\t%s\n", name, val)
+			}
+		}
+		printOptional("Build ID", info.BuildID)
+		printOptional("Compiler", info.Compiler)
+		printOptional("Toolchain", info.Toolchain)
+		printOptional("Instrumentation", info.Instrumentation)
+		printOptional("GOROOT", info.GoRoot)
+		printOptional("Main module", info.MainModule)
+		return w.Flush()
+	},
+}
+
+var versionCmd = &command{
+	name:  "version",
+	short: "Print the Go compiler version",
+	run: func(s *session) error {
+		v, err := s.file.GetCompilerVersion()
+		if err != nil {
+			return err
+		}
+		if s.json {
+			return s.printJSON(v)
+		}
+		s.printf("%s\n", v.Name)
+		return nil
+	},
+}
+
+// packageFilter is used by the commands that operate on packages to
+// select which package classes to include.
+type packageFilter struct {
+	std, vendor, generated, unknown bool
+}
+
+func (p *packageFilter) register(fs *flag.FlagSet) {
+	fs.BoolVar(&p.std, "std", false, "include standard library packages")
+	fs.BoolVar(&p.vendor, "vendor", false, "include vendor packages")
+	fs.BoolVar(&p.generated, "generated", false, "include compiler generated packages")
+	fs.BoolVar(&p.unknown, "unknown", false, "include unclassified packages")
+}
+
+type classifiedPackage struct {
+	Class string `json:"class"`
+	*gore.Package
+}
+
+// packages returns the packages selected by the filter. The main packages
+// are always included.
+func (p *packageFilter) packages(f *gore.GoFile) ([]classifiedPackage, error) {
+	getters := []struct {
+		class   gore.PackageClass
+		enabled bool
+		get     func() ([]*gore.Package, error)
+	}{
+		{gore.ClassMain, true, f.GetPackages},
+		{gore.ClassVendor, p.vendor, f.GetVendors},
+		{gore.ClassSTD, p.std, f.GetSTDLib},
+		{gore.ClassGenerated, p.generated, f.GetGeneratedPackages},
+		{gore.ClassUnknown, p.unknown, f.GetUnknown},
+	}
+	var result []classifiedPackage
+	for _, g := range getters {
+		if !g.enabled {
+			continue
+		}
+		pkgs, err := g.get()
+		if err != nil {
+			return nil, err
+		}
+		for _, pkg := range pkgs {
+			result = append(result, classifiedPackage{Class: g.class.String(), Package: pkg})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+var packagesFilter packageFilter
+
+var packagesCmd = &command{
+	name:  "packages",
+	short: "List the packages",
+	flags: packagesFilter.register,
+	run: func(s *session) error {
+		pkgs, err := packagesFilter.packages(s.file)
+		if err != nil {
+			return err
+		}
+		if s.json {
+			return s.printJSON(pkgs)
+		}
+		w := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+		for _, p := range pkgs {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", p.Class, p.Name, p.Filepath)
+		}
+		return w.Flush()
+	},
+}
+
+var srcfilesFilter packageFilter
+
+var srcfilesCmd = &command{
+	name:  "srcfiles",
+	short: "List the source files and the functions in them",
+	flags: srcfilesFilter.register,
+	run: func(s *session) error {
+		pkgs, err := srcfilesFilter.packages(s.file)
+		if err != nil {
+			return err
+		}
+
+		type srcFile struct {
+			Name    string           `json:"name"`
+			Entries []gore.FileEntry `json:"entries"`
+		}
+		type pkgFiles struct {
+			Package string     `json:"package"`
+			Path    string     `json:"path"`
+			Files   []*srcFile `json:"files"`
+		}
+
+		var result []*pkgFiles
+		for _, p := range pkgs {
+			pf := &pkgFiles{Package: p.Name, Path: p.Filepath}
+			for _, sf := range s.file.GetSourceFiles(p.Package) {
+				pf.Files = append(pf.Files, &srcFile{Name: sf.Name, Entries: sf.Entries()})
+			}
+			result = append(result, pf)
+		}
+
+		if s.json {
+			return s.printJSON(result)
+		}
+		for _, pf := range result {
+			s.printf("Package %s: %s\n", pf.Package, pf.Path)
+			for _, sf := range pf.Files {
+				s.printf("File: %s\n", sf.Name)
+				for _, e := range sf.Entries {
+					s.printf("\t%s\n", e)
+				}
+			}
+		}
+		return nil
+	},
+}
+
+var typesIncludeStd bool
+
+var typesCmd = &command{
+	name:  "types",
+	short: "List the types",
+	flags: func(fs *flag.FlagSet) {
+		fs.BoolVar(&typesIncludeStd, "std", false, "include types from the standard library")
+	},
+	run: func(s *session) error {
+		types, err := s.file.GetTypes()
+		if err != nil {
+			return err
+		}
+
+		type typeDef struct {
+			Name        string `json:"name"`
+			Kind        string `json:"kind"`
+			PackagePath string `json:"packagePath"`
+			Addr        uint64 `json:"addr"`
+			Definition  string `json:"definition"`
+			Methods     string `json:"methods,omitempty"`
+		}
+
+		var result []*typeDef
+		for _, t := range types {
+			// Only named types declared in a package are listed.
+			if t.PackagePath == "" || t.Name == "" {
+				continue
+			}
+			if !typesIncludeStd && gore.IsStandardLibrary(t.PackagePath) {
+				continue
+			}
+			result = append(result, &typeDef{
+				Name:        t.Name,
+				Kind:        t.Kind.String(),
+				PackagePath: t.PackagePath,
+				Addr:        t.Addr,
+				Definition:  typeDefinition(t),
+				Methods:     gore.MethodDef(t),
+			})
+		}
+
+		if s.json {
+			return s.printJSON(result)
+		}
+		for _, t := range result {
+			s.printf("%s\n", t.Definition)
+			if t.Methods != "" {
+				s.printf("%s\n", t.Methods)
+			}
+			s.printf("\n")
+		}
+		return nil
+	},
+}
+
+func typeDefinition(t *gore.GoType) string {
+	switch t.Kind {
+	case reflect.Struct:
+		return gore.StructDef(t)
+	case reflect.Interface:
+		return gore.InterfaceDef(t)
+	default:
+		return fmt.Sprintf("type %s %s", t.Name, t.Kind)
+	}
+}
+
+var buildinfoCmd = &command{
+	name:  "buildinfo",
+	short: "Print the build information",
+	run: func(s *session) error {
+		bi := s.file.BuildInfo
+		if bi == nil || bi.ModInfo == nil {
+			return gore.ErrNoBuildInfo
+		}
+		if s.json {
+			return s.printJSON(bi)
+		}
+		s.printf("%s", bi.ModInfo)
+		return nil
+	},
+}
+
+var sbomFormat string
+
+var sbomCmd = &command{
+	name:  "sbom",
+	short: "Print a software bill of materials",
+	flags: func(fs *flag.FlagSet) {
+		fs.StringVar(&sbomFormat, "format", "cyclonedx", "SBOM format, cyclonedx or spdx")
+	},
+	run: func(s *session) error {
+		var format gore.SBOMFormat
+		switch sbomFormat {
+		case "cyclonedx":
+			format = gore.SBOMCycloneDX
+		case "spdx":
+			format = gore.SBOMSPDX
+		default:
+			return fmt.Errorf("%w: %s", gore.ErrUnknownSBOMFormat, sbomFormat)
+		}
+		// The SBOM formats are JSON so the json flag makes no difference.
+		return s.file.WriteSBOM(s.out, format)
+	},
+}
+
+var (
+	ctypesFormat     string
+	ctypesIncludeStd bool
+)
+
+var ctypesCmd = &command{
+	name:  "ctypes",
+	short: "Print C definitions of the types for a decompiler",
+	flags: func(fs *flag.FlagSet) {
+		fs.StringVar(&ctypesFormat, "format", "header", "C flavor, header, ghidra or ida")
+		fs.BoolVar(&ctypesIncludeStd, "std", false, "include types from the standard library")
+	},
+	run: func(s *session) error {
+		var format gore.CTypesFormat
+		switch ctypesFormat {
+		case "header":
+			format = gore.CTypesHeader
+		case "ghidra":
+			format = gore.CTypesGhidra
+		case "ida":
+			format = gore.CTypesIDA
+		default:
+			return fmt.Errorf("%w: %s", gore.ErrUnknownCTypesFormat, ctypesFormat)
+		}
+
+		types, err := s.file.GetTypes()
+		if err != nil {
+			return err
+		}
+		// The types the selected ones refer to are always included.
+		var selected []*gore.GoType
+		for _, t := range types {
+			if t.PackagePath == "" || t.Name == "" {
+				continue
+			}
+			if !ctypesIncludeStd && gore.IsStandardLibrary(t.PackagePath) {
+				continue
+			}
+			selected = append(selected, t)
+		}
+		if len(selected) == 0 {
+			return nil
+		}
+		// The output is C so the json flag makes no difference.
+		return s.file.WriteCTypes(s.out, format, selected...)
+	},
+}
+
+var stringsMinLen int
+
+var stringsCmd = &command{
+	name:  "strings",
+	short: "Print the printable strings in the read-only data",
+	flags: func(fs *flag.FlagSet) {
+		fs.IntVar(&stringsMinLen, "n", 4, "minimum length of the strings")
+	},
+	run: func(s *session) error {
+		data, err := readOnlyData(s.file)
+		if err != nil {
+			return err
+		}
+		strs := printableStrings(data, stringsMinLen)
+		if s.json {
+			return s.printJSON(strs)
+		}
+		for _, str := range strs {
+			s.printf("%s\n", str)
+		}
+		return nil
+	},
+}
+
+// readOnlyData returns the content of the section that holds the read-only
+// data. This is where the string literals are stored.
+func readOnlyData(f *gore.GoFile) ([]byte, error) {
+	switch file := f.GetParsedFile().(type) {
+	case *elf.File:
+		if sect := file.Section(".rodata"); sect != nil {
+			return sect.Data()
+		}
+	case *pe.File:
+		if sect := file.Section(".rdata"); sect != nil {
+			return sect.Data()
+		}
+	case *macho.File:
+		for _, seg := range []string{"__TEXT", "__DATA_CONST"} {
+			if sect := file.Section(seg, "__rodata"); sect != nil {
+				return sect.Data()
+			}
+		}
+	}
+	return nil, gore.ErrSectionDoesNotExist
+}
+
+// printableStrings returns all sequences of printable characters that are at
+// least minLen long. Go strings are not NUL terminated, so string literals
+// stored next to each other are returned as one string.
+func printableStrings(data []byte, minLen int) []string {
+	var result []string
+	var buf strings.Builder
+	flush := func() {
+		if buf.Len() >= minLen {
+			result = append(result, buf.String())
+		}
+		buf.Reset()
+	}
+	for _, b := range data {
+		if b < 0x80 && (unicode.IsPrint(rune(b)) || b == '\t') {
+			buf.WriteByte(b)
+			continue
+		}
+		flush()
+	}
+	flush()
+	return result
+}
@@ -0,0 +1,198 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Command gore prints information extracted from Go binaries.
+//
+// Usage:
+//
+//	gore <command> [flags] <file>
+//
+// The commands are:
+//
+//	info       print general information about the file
+//	version    print the Go compiler version
+//	packages   list the packages
+//	types      list the types
+//	srcfiles   list the source files and the functions in them
+//	buildinfo  print the build information
+//	sbom       print a software bill of materials
+//	ctypes     print C definitions of the types for a decompiler
+//	strings    print the printable strings in the read-only data
+//
+// All commands accept the -json flag to produce JSON output.
+//
+// The exit code is 0 on success, 1 if the file could not be analyzed, 2 for
+// usage errors and 3 if the file is not a supported Go binary.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ZxillyFork/gore"
+)
+
+const (
+	exitOK       = 0
+	exitError    = 1
+	exitUsage    = 2
+	exitNotGoBin = 3
+)
+
+type command struct {
+	name  string
+	short string
+	// flags registers command specific flags on the flag set.
+	flags func(fs *flag.FlagSet)
+	run   func(ctx *session) error
+}
+
+var commands = []*command{
+	infoCmd,
+	versionCmd,
+	packagesCmd,
+	typesCmd,
+	ctypesCmd,
+	srcfilesCmd,
+	buildinfoCmd,
+	sbomCmd,
+	stringsCmd,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitUsage
+	}
+
+	var cmd *command
+	for _, c := range commands {
+		if c.name == args[0] {
+			cmd = c
+			break
+		}
+	}
+	if cmd == nil {
+		if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+			usage(stdout)
+			return exitOK
+		}
+		fmt.Fprintf(stderr, "gore: unknown command %q\n", args[0])
+		usage(stderr)
+		return exitUsage
+	}
+
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	ctx := &session{out: stdout}
+	fs.BoolVar(&ctx.json, "json", false, "print the output as JSON")
+	var goversions string
+	fs.StringVar(&goversions, "goversions", "", "load additional Go releases from a CSV `file` of tag,commit,timestamp lines")
+	if cmd.flags != nil {
+		cmd.flags(fs)
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: gore %s [flags] <file>\n\n%s.\n\nFlags:\n", cmd.name, cmd.short)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	if goversions != "" {
+		if err := loadGoVersions(goversions); err != nil {
+			fmt.Fprintf(stderr, "gore: %s\n", err)
+			return exitError
+		}
+	}
+
+	f, err := gore.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "gore: %s\n", err)
+		return exitCode(err)
+	}
+	defer f.Close()
+	ctx.file = f
+
+	if err = cmd.run(ctx); err != nil {
+		fmt.Fprintf(stderr, "gore: %s\n", err)
+		return exitCode(err)
+	}
+	return exitOK
+}
+
+func loadGoVersions(name string) error {
+	r, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return gore.LoadGoVersions(r)
+}
+
+// exitCode returns the exit code for the error. Files that are not executables
+// or executables without a pclntab are not Go binaries. All other errors are
+// treated as a failure to analyze the file.
+func exitCode(err error) int {
+	if errors.Is(err, gore.ErrUnsupportedFile) || errors.Is(err, gore.ErrNoPCLNTab) {
+		return exitNotGoBin
+	}
+	return exitError
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: gore <command> [flags] <file>")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.short)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "gore <command> -h" for the flags of a command.`)
+}
+
+// session is passed to the command being executed.
+type session struct {
+	file *gore.GoFile
+	out  io.Writer
+	json bool
+}
+
+func (c *session) printJSON(v any) error {
+	enc := json.NewEncoder(c.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (c *session) printf(format string, a ...any) {
+	fmt.Fprintf(c.out, format, a...)
+}
@@ -0,0 +1,159 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExitCodes(t *testing.T) {
+	notExe := filepath.Join(t.TempDir(), "text")
+	require.NoError(t, os.WriteFile(notExe, []byte("this is not an executable"), 0644))
+	badVersions := filepath.Join(t.TempDir(), "goversions.csv")
+	require.NoError(t, os.WriteFile(badVersions, []byte("not a version\n"), 0644))
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"no arguments", nil, exitUsage},
+		{"unknown command", []string{"unknown", "file"}, exitUsage},
+		{"missing file argument", []string{"info"}, exitUsage},
+		{"help", []string{"help"}, exitOK},
+		{"file does not exist", []string{"info", filepath.Join(t.TempDir(), "missing")}, exitError},
+		{"not a Go binary", []string{"info", notExe}, exitNotGoBin},
+		{"versions file does not exist", []string{"info", "-goversions", filepath.Join(t.TempDir(), "missing"), notExe}, exitError},
+		{"invalid versions file", []string{"info", "-goversions", badVersions, notExe}, exitError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			assert.Equal(t, test.code, run(test.args, stdout, stderr), stderr.String())
+		})
+	}
+}
+
+func TestCommandsOnTestBinary(t *testing.T) {
+	exe := buildTestBinary(t)
+
+	t.Run("info", func(t *testing.T) {
+		r := require.New(t)
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		r.Equal(exitOK, run([]string{"info", "-json", exe}, stdout, stderr), stderr.String())
+
+		var info map[string]any
+		r.NoError(json.Unmarshal(stdout.Bytes(), &info))
+		r.Equal("amd64", info["arch"])
+		r.Equal("github.com/goretk/gore/testbin", info["mainModule"])
+	})
+
+	t.Run("packages", func(t *testing.T) {
+		r := require.New(t)
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		r.Equal(exitOK, run([]string{"packages", "-json", exe}, stdout, stderr), stderr.String())
+
+		var pkgs []struct {
+			Class string `json:"class"`
+			Name  string `json:"name"`
+		}
+		r.NoError(json.Unmarshal(stdout.Bytes(), &pkgs))
+		r.Contains(pkgs, struct {
+			Class string `json:"class"`
+			Name  string `json:"name"`
+		}{"main", "main"})
+	})
+
+	t.Run("buildinfo", func(t *testing.T) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		require.Equal(t, exitOK, run([]string{"buildinfo", exe}, stdout, stderr), stderr.String())
+		assert.Contains(t, stdout.String(), "github.com/goretk/gore/testbin")
+	})
+
+	t.Run("sbom", func(t *testing.T) {
+		r := require.New(t)
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		r.Equal(exitOK, run([]string{"sbom", "-format", "spdx", exe}, stdout, stderr), stderr.String())
+
+		var doc map[string]any
+		r.NoError(json.Unmarshal(stdout.Bytes(), &doc))
+		r.Equal("SPDX-2.3", doc["spdxVersion"])
+
+		r.Equal(exitError, run([]string{"sbom", "-format", "xml", exe}, stdout, stderr))
+	})
+
+	t.Run("ctypes", func(t *testing.T) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		require.Equal(t, exitError, run([]string{"ctypes", "-format", "xml", exe}, stdout, stderr))
+		assert.Contains(t, stderr.String(), "unknown C types format")
+	})
+
+	t.Run("srcfiles", func(t *testing.T) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		require.Equal(t, exitOK, run([]string{"srcfiles", exe}, stdout, stderr), stderr.String())
+		assert.Contains(t, stdout.String(), "File: main.go")
+		assert.Contains(t, stdout.String(), "getData Lines:")
+	})
+}
+
+func buildTestBinary(t *testing.T) string {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("No go tool chain found: " + err.Error())
+	}
+	tmpdir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "main.go"), []byte(testsrc), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "go.mod"), []byte("module github.com/goretk/gore/testbin\n\ngo 1.22\n"), 0644))
+
+	exe := filepath.Join(tmpdir, "testbin")
+	cmd := exec.Command(goBin, "build", "-o", exe, ".")
+	cmd.Dir = tmpdir
+	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH=amd64", "CGO_ENABLED=0")
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "building test executable failed: "+string(out))
+	return exe
+}
+
+const testsrc = `
+package main
+
+import "fmt"
+
+//go:noinline
+func getData() string {
+	return "Name: GoRE"
+}
+
+func main() {
+	fmt.Println(getData())
+}
+`
+
+func TestPrintableStrings(t *testing.T) {
+	data := []byte("\x00\x01hello\x00ab\xffworld!\x02")
+	assert.Equal(t, []string{"hello", "world!"}, printableStrings(data, 3))
+	assert.Equal(t, []string{"hello", "ab", "world!"}, printableStrings(data, 2))
+}
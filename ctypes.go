@@ -0,0 +1,539 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// CTypesFormat is the flavor of C used for the type definitions.
+type CTypesFormat uint8
+
+const (
+	// CTypesHeader is a C header that includes stdint.h.
+	CTypesHeader CTypesFormat = iota
+	// CTypesGhidra is C source that can be parsed by Ghidra's C parser
+	// without any other headers.
+	CTypesGhidra
+	// CTypesIDA is C source that can be parsed by IDA's type library
+	// parser. The fixed size integers are defined with IDA's __intN types.
+	CTypesIDA
+)
+
+// ErrUnknownCTypesFormat is returned if the requested C types format is not supported.
+var ErrUnknownCTypesFormat = errors.New("unknown C types format")
+
+// unnamedTypePrefixes are the prefixes of the names of unnamed types, like
+// []main.T or map[string]int.
+var unnamedTypePrefixes = []string{"*", "[", "map[", "chan ", "chan<-", "<-chan", "func(", "struct {", "interface {"}
+
+// isNamedType returns true if the type is a named type declared in a
+// package. The predeclared types are not.
+func isNamedType(t *GoType) bool {
+	if !strings.Contains(t.Name, ".") {
+		return false
+	}
+	for _, prefix := range unnamedTypePrefixes {
+		if strings.HasPrefix(t.Name, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteCTypes writes C definitions of the types to w so they can be
+// imported into a decompiler. If no types are given, all types in the file
+// are written. The types they refer to are always included.
+//
+// Structs are packed and have explicit padding members, so the field
+// offsets are the ones of the Go types. Strings, interfaces, slices and
+// complex numbers are structs of the runtime representation, while maps,
+// channels and functions are pointers. The C names are the Go names with
+// all characters that can't be used in C identifiers replaced by
+// underscores, for example main_T for main.T.
+func (f *GoFile) WriteCTypes(w io.Writer, format CTypesFormat, types ...*GoType) error {
+	if format > CTypesIDA {
+		return ErrUnknownCTypesFormat
+	}
+	all, err := f.GetTypes()
+	if err != nil {
+		return err
+	}
+	if len(types) == 0 {
+		types = all
+	}
+
+	g := newCTypes(f.FileInfo.WordSize, all)
+	for _, t := range types {
+		g.add(t)
+	}
+
+	bw := bufio.NewWriter(w)
+	g.write(bw, format)
+	return bw.Flush()
+}
+
+// cEntity is a C type definition.
+type cEntity struct {
+	name string
+	// typ is the Go type, or the element type for a slice.
+	typ   *GoType
+	slice bool
+	// done and visiting are used to sort the definitions.
+	done, visiting bool
+}
+
+// cTypes generates the C definitions.
+type cTypes struct {
+	wordSize int
+	// canon holds the types by address. Struct fields are copies of the
+	// type, so the address is used to find the entity of a type.
+	canon  map[uint64]*GoType
+	byAddr map[uint64]*cEntity
+	// slices holds the slice structs by the address of the element type.
+	slices map[uint64]*cEntity
+	used   map[string]bool
+	order  []*cEntity
+}
+
+func newCTypes(wordSize int, types []*GoType) *cTypes {
+	g := &cTypes{
+		wordSize: wordSize,
+		canon:    make(map[uint64]*GoType, len(types)),
+		byAddr:   make(map[uint64]*cEntity),
+		slices:   make(map[uint64]*cEntity),
+		used:     make(map[string]bool),
+	}
+	for _, name := range cBuiltinNames {
+		g.used[name] = true
+	}
+	for _, t := range types {
+		g.canon[t.Addr] = t
+	}
+	return g
+}
+
+func (g *cTypes) resolve(t *GoType) *GoType {
+	if c, ok := g.canon[t.Addr]; ok && t.Addr != 0 {
+		return c
+	}
+	return t
+}
+
+// uniqueName returns a C identifier for the name that isn't used yet.
+func (g *cTypes) uniqueName(name string) string {
+	base := cIdentifier(name)
+	name = base
+	for i := 2; g.used[name]; i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	g.used[name] = true
+	return name
+}
+
+// entity returns the definition of a type that has a C name of its own:
+// named types, unnamed structs and slices. Nil is returned for other types.
+func (g *cTypes) entity(t *GoType) *cEntity {
+	t = g.resolve(t)
+	switch {
+	case isNamedType(t), t.Kind == reflect.Struct:
+		if e, ok := g.byAddr[t.Addr]; ok {
+			return e
+		}
+		name := t.Name
+		if !isNamedType(t) {
+			name = fmt.Sprintf("anon_struct_%x", t.Addr)
+		}
+		e := &cEntity{name: g.uniqueName(name), typ: t}
+		g.byAddr[t.Addr] = e
+		return e
+	case t.Kind == reflect.Slice:
+		return g.sliceEntity(t)
+	}
+	return nil
+}
+
+// sliceEntity returns the definition of the slice struct for the element
+// type of the slice.
+func (g *cTypes) sliceEntity(t *GoType) *cEntity {
+	var key uint64
+	if t.Element != nil {
+		key = g.resolve(t.Element).Addr
+	}
+	if e, ok := g.slices[key]; ok {
+		return e
+	}
+	name := "go_slice"
+	if t.Element != nil {
+		name += "_" + goTypeName(t.Element)
+	}
+	e := &cEntity{name: g.uniqueName(name), typ: t.Element, slice: true}
+	g.slices[key] = e
+	return e
+}
+
+func goTypeName(t *GoType) string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return t.String()
+}
+
+// add adds the definitions needed for the type, ordered so that a type is
+// defined before it's used by value.
+func (g *cTypes) add(t *GoType) {
+	if e := g.entity(t); e != nil {
+		g.visit(e)
+		return
+	}
+	for _, d := range g.deps(t, false) {
+		g.visit(d)
+	}
+}
+
+func (g *cTypes) visit(e *cEntity) {
+	if e.done || e.visiting {
+		return
+	}
+	e.visiting = true
+	var deps []*cEntity
+	switch {
+	case e.slice:
+		if e.typ != nil {
+			deps = g.deps(e.typ, true)
+		}
+	case e.typ.Kind == reflect.Struct:
+		for _, f := range e.typ.Fields {
+			deps = append(deps, g.deps(f, false)...)
+		}
+	default:
+		deps = g.underlyingDeps(g.resolve(e.typ), false)
+	}
+	for _, d := range deps {
+		g.visit(d)
+	}
+	e.visiting = false
+	e.done = true
+	g.order = append(g.order, e)
+
+	// Structs referenced by a pointer only need the forward declaration,
+	// but their definition is still included.
+	for _, d := range g.pointerDeps(e) {
+		g.visit(d)
+	}
+}
+
+// pointerDeps returns the structs the entity refers to by pointer.
+func (g *cTypes) pointerDeps(e *cEntity) []*cEntity {
+	var refs []*GoType
+	switch {
+	case e.slice:
+		if e.typ != nil {
+			refs = append(refs, &GoType{Kind: reflect.Ptr, Element: e.typ})
+		}
+	case e.typ.Kind == reflect.Struct:
+		for _, f := range e.typ.Fields {
+			refs = append(refs, f)
+		}
+	default:
+		refs = append(refs, g.resolve(e.typ))
+	}
+	var deps []*cEntity
+	for _, r := range refs {
+		r = g.resolve(r)
+		for (r.Kind == reflect.Ptr || r.Kind == reflect.Array) && r.Element != nil {
+			if d := g.entity(r.Element); d != nil {
+				if r.Kind == reflect.Ptr {
+					deps = append(deps, d)
+				}
+				break
+			}
+			r = g.resolve(r.Element)
+		}
+	}
+	return deps
+}
+
+// deps returns the definitions needed before a value of the type can be
+// declared. If the value is behind a pointer, structs are excluded since
+// they are forward declared.
+func (g *cTypes) deps(t *GoType, viaPtr bool) []*cEntity {
+	if e := g.entity(t); e != nil {
+		if viaPtr && !e.slice && g.resolve(e.typ).Kind == reflect.Struct {
+			return nil
+		}
+		return []*cEntity{e}
+	}
+	return g.underlyingDeps(g.resolve(t), viaPtr)
+}
+
+func (g *cTypes) underlyingDeps(t *GoType, viaPtr bool) []*cEntity {
+	if t.Element == nil && t.Kind != reflect.Slice {
+		return nil
+	}
+	switch t.Kind {
+	case reflect.Array:
+		return g.deps(t.Element, viaPtr)
+	case reflect.Ptr:
+		return g.deps(t.Element, true)
+	case reflect.Slice:
+		return []*cEntity{g.sliceEntity(t)}
+	}
+	return nil
+}
+
+// decl returns the declaration of name with the type.
+func (g *cTypes) decl(t *GoType, name string) string {
+	if e := g.entity(t); e != nil {
+		return joinDecl(e.name, name)
+	}
+	return g.underlyingDecl(g.resolve(t), name)
+}
+
+// underlyingDecl returns the declaration of name with the structure of the
+// type, ignoring its name.
+func (g *cTypes) underlyingDecl(t *GoType, name string) string {
+	switch t.Kind {
+	case reflect.Array:
+		if t.Element == nil {
+			return joinDecl("uint8_t", fmt.Sprintf("%s[%d]", name, t.Size))
+		}
+		return g.decl(t.Element, fmt.Sprintf("%s[%d]", name, t.Length))
+	case reflect.Ptr:
+		if t.Element == nil {
+			return joinDecl("void", "*"+name)
+		}
+		if g.resolve(t.Element).Kind == reflect.Array && g.entity(t.Element) == nil {
+			return g.decl(t.Element, "(*"+name+")")
+		}
+		return g.decl(t.Element, "*"+name)
+	case reflect.Slice:
+		return joinDecl(g.sliceEntity(t).name, name)
+	case reflect.Interface:
+		if len(t.Methods) == 0 {
+			return joinDecl("go_eface", name)
+		}
+		return joinDecl("go_iface", name)
+	case reflect.Struct:
+		// All structs are entities.
+		return joinDecl(g.entity(t).name, name)
+	}
+	return joinDecl(cBasicTypes[t.Kind], name)
+}
+
+func joinDecl(typ, name string) string {
+	if name == "" {
+		return typ
+	}
+	return typ + " " + name
+}
+
+// cBasicTypes maps the kinds that have a fixed C type.
+var cBasicTypes = map[reflect.Kind]string{
+	reflect.Bool:          "go_bool",
+	reflect.Int:           "go_int",
+	reflect.Int8:          "int8_t",
+	reflect.Int16:         "int16_t",
+	reflect.Int32:         "int32_t",
+	reflect.Int64:         "int64_t",
+	reflect.Uint:          "go_uint",
+	reflect.Uint8:         "uint8_t",
+	reflect.Uint16:        "uint16_t",
+	reflect.Uint32:        "uint32_t",
+	reflect.Uint64:        "uint64_t",
+	reflect.Uintptr:       "go_uintptr",
+	reflect.Float32:       "float",
+	reflect.Float64:       "double",
+	reflect.Complex64:     "go_complex64",
+	reflect.Complex128:    "go_complex128",
+	reflect.String:        "go_string",
+	reflect.Map:           "go_map",
+	reflect.Chan:          "go_chan",
+	reflect.Func:          "go_func",
+	reflect.UnsafePointer: "void *",
+	reflect.Invalid:       "void *",
+}
+
+// cBuiltinNames are the types defined by the header of the generated
+// definitions. They are reserved so no type is given the same name.
+var cBuiltinNames = []string{
+	"int8_t", "uint8_t", "int16_t", "uint16_t", "int32_t", "uint32_t", "int64_t", "uint64_t",
+	"go_bool", "go_int", "go_uint", "go_uintptr", "go_map", "go_chan", "go_func",
+	"go_string", "go_slice", "go_iface", "go_eface", "go_complex64", "go_complex128",
+}
+
+// cKeywords are the C keywords and the names used by the generated
+// definitions that can't be used as member names.
+var cKeywords = map[string]bool{
+	"auto": true, "break": true, "case": true, "char": true, "const": true, "continue": true,
+	"default": true, "do": true, "double": true, "else": true, "enum": true, "extern": true,
+	"float": true, "for": true, "goto": true, "if": true, "inline": true, "int": true,
+	"long": true, "register": true, "restrict": true, "return": true, "short": true,
+	"signed": true, "sizeof": true, "static": true, "struct": true, "switch": true,
+	"typedef": true, "union": true, "unsigned": true, "void": true, "volatile": true, "while": true,
+}
+
+// cIdentifier replaces the characters that can't be used in a C identifier
+// with underscores.
+func cIdentifier(s string) string {
+	var b strings.Builder
+	under := false
+	for _, c := range s {
+		ok := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !ok {
+			if !under && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			under = true
+			continue
+		}
+		b.WriteRune(c)
+		under = c == '_'
+	}
+	id := strings.TrimRight(b.String(), "_")
+	if id == "" {
+		return "_"
+	}
+	if id[0] >= '0' && id[0] <= '9' {
+		id = "_" + id
+	}
+	return id
+}
+
+func (g *cTypes) write(w io.Writer, format CTypesFormat) {
+	word, uword := "int64_t", "uint64_t"
+	if g.wordSize == intSize32 {
+		word, uword = "int32_t", "uint32_t"
+	}
+
+	switch format {
+	case CTypesHeader:
+		fmt.Fprint(w, "#ifndef GORE_TYPES_H\n#define GORE_TYPES_H\n\n#include <stdint.h>\n\n")
+	case CTypesGhidra:
+		fmt.Fprint(w, "typedef signed char int8_t;\ntypedef unsigned char uint8_t;\n"+
+			"typedef short int16_t;\ntypedef unsigned short uint16_t;\n"+
+			"typedef int int32_t;\ntypedef unsigned int uint32_t;\n"+
+			"typedef long long int64_t;\ntypedef unsigned long long uint64_t;\n\n")
+	case CTypesIDA:
+		fmt.Fprint(w, "typedef __int8 int8_t;\ntypedef unsigned __int8 uint8_t;\n"+
+			"typedef __int16 int16_t;\ntypedef unsigned __int16 uint16_t;\n"+
+			"typedef __int32 int32_t;\ntypedef unsigned __int32 uint32_t;\n"+
+			"typedef __int64 int64_t;\ntypedef unsigned __int64 uint64_t;\n\n")
+	}
+
+	fmt.Fprintf(w, "typedef uint8_t go_bool;\ntypedef %s go_int;\ntypedef %s go_uint;\ntypedef %s go_uintptr;\n", word, uword, uword)
+	fmt.Fprint(w, "typedef void *go_map;\ntypedef void *go_chan;\ntypedef void *go_func;\n\n")
+	fmt.Fprint(w, "#pragma pack(push, 1)\n\n")
+	fmt.Fprint(w, "typedef struct go_string {\n\tuint8_t *ptr;\n\tgo_int len;\n} go_string;\n\n")
+	fmt.Fprint(w, "typedef struct go_slice {\n\tvoid *ptr;\n\tgo_int len;\n\tgo_int cap;\n} go_slice;\n\n")
+	fmt.Fprint(w, "typedef struct go_iface {\n\tvoid *tab;\n\tvoid *data;\n} go_iface;\n\n")
+	fmt.Fprint(w, "typedef struct go_eface {\n\tvoid *type;\n\tvoid *data;\n} go_eface;\n\n")
+	fmt.Fprint(w, "typedef struct go_complex64 {\n\tfloat real;\n\tfloat imag;\n} go_complex64;\n\n")
+	fmt.Fprint(w, "typedef struct go_complex128 {\n\tdouble real;\n\tdouble imag;\n} go_complex128;\n\n")
+
+	// Forward declarations, so structs can be used through pointers before
+	// they are defined.
+	var structs []string
+	for _, e := range g.order {
+		if e.slice || e.typ.Kind == reflect.Struct {
+			structs = append(structs, e.name)
+		}
+	}
+	sort.Strings(structs)
+	for _, name := range structs {
+		fmt.Fprintf(w, "typedef struct %s %s;\n", name, name)
+	}
+	if len(structs) > 0 {
+		fmt.Fprintln(w)
+	}
+
+	for _, e := range g.order {
+		switch {
+		case e.slice:
+			elem := "void *ptr"
+			if e.typ != nil {
+				elem = g.decl(&GoType{Kind: reflect.Ptr, Element: e.typ}, "ptr")
+			}
+			fmt.Fprintf(w, "struct %s {\n\t%s;\n\tgo_int len;\n\tgo_int cap;\n};\n\n", e.name, elem)
+		case e.typ.Kind == reflect.Struct:
+			g.writeStruct(w, e)
+		default:
+			fmt.Fprintf(w, "/* %s */\ntypedef %s;\n\n", e.typ.Name, g.underlyingDecl(g.resolve(e.typ), e.name))
+		}
+	}
+
+	fmt.Fprint(w, "#pragma pack(pop)\n")
+	if format == CTypesHeader {
+		fmt.Fprint(w, "\n#endif\n")
+	}
+}
+
+// writeStruct writes the definition of the struct. Padding members are
+// added where the next field starts after the end of the previous one.
+func (g *cTypes) writeStruct(w io.Writer, e *cEntity) {
+	t := e.typ
+	if t.Name != "" {
+		fmt.Fprintf(w, "/* %s */\n", t.Name)
+	}
+	fmt.Fprintf(w, "struct %s {\n", e.name)
+
+	var off uint64
+	pad := 0
+	writePad := func(n uint64) {
+		fmt.Fprintf(w, "\tuint8_t _pad%d[%d];\n", pad, n)
+		pad++
+	}
+	names := make(map[string]bool)
+	for i, f := range t.Fields {
+		if f.FieldOffset > off {
+			writePad(f.FieldOffset - off)
+			off = f.FieldOffset
+		}
+		if f.Size == 0 {
+			// Zero sized fields can't be declared in C.
+			continue
+		}
+
+		name := f.FieldName
+		if name == "" {
+			name = goTypeName(f)
+		}
+		name = cIdentifier(name)
+		if name == "_" || cKeywords[name] || names[name] {
+			name = fmt.Sprintf("%s_%d", name, i)
+		}
+		names[name] = true
+
+		fmt.Fprintf(w, "\t%s;", g.decl(f, name))
+		if f.FieldAnon {
+			fmt.Fprint(w, " /* embedded */")
+		}
+		fmt.Fprintln(w)
+		off = f.FieldOffset + f.Size
+	}
+	if t.Size > off {
+		writePad(t.Size - off)
+	}
+	fmt.Fprint(w, "};\n\n")
+}
@@ -0,0 +1,152 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// cTestTypes returns the types of:
+//
+//	type Base struct { id int32 }
+//	type Node struct {
+//		Base
+//		name  string
+//		next  *Node
+//		kids  []*Node
+//		vals  [2]main.ID
+//		attrs map[string]int
+//		flag  bool
+//		any   interface{}
+//	}
+//	type ID uint16
+func cTestTypes() []*GoType {
+	i32 := &GoType{Kind: reflect.Int32, Name: "int32", Addr: 0x10, Size: 4}
+	str := &GoType{Kind: reflect.String, Name: "string", Addr: 0x20, Size: 16}
+	id := &GoType{Kind: reflect.Uint16, Name: "main.ID", Addr: 0x30, Size: 2}
+	boolean := &GoType{Kind: reflect.Bool, Name: "bool", Addr: 0x40, Size: 1}
+	eface := &GoType{Kind: reflect.Interface, Name: "interface {}", Addr: 0x50, Size: 16}
+	m := &GoType{Kind: reflect.Map, Name: "map[string]int", Addr: 0x60, Size: 8}
+	arr := &GoType{Kind: reflect.Array, Name: "[2]main.ID", Addr: 0x70, Size: 4, Length: 2, Element: id}
+
+	base := &GoType{Kind: reflect.Struct, Name: "main.Base", Addr: 0x100, Size: 4}
+	baseID := *i32
+	baseID.FieldName = "id"
+	base.Fields = []*GoType{&baseID}
+
+	node := &GoType{Kind: reflect.Struct, Name: "main.Node", Addr: 0x200, Size: 96}
+	ptr := &GoType{Kind: reflect.Ptr, Name: "*main.Node", Addr: 0x300, Size: 8, Element: node}
+	slice := &GoType{Kind: reflect.Slice, Name: "[]*main.Node", Addr: 0x400, Size: 24, Element: ptr}
+
+	field := func(t *GoType, name string, off uint64) *GoType {
+		f := *t
+		f.FieldName = name
+		f.FieldOffset = off
+		return &f
+	}
+	embedded := field(base, "Base", 0)
+	embedded.FieldAnon = true
+	node.Fields = []*GoType{
+		embedded,
+		field(str, "name", 8),
+		field(ptr, "next", 24),
+		field(slice, "kids", 32),
+		field(arr, "vals", 56),
+		field(m, "attrs", 64),
+		field(boolean, "flag", 72),
+		field(eface, "any", 80),
+	}
+	return []*GoType{i32, str, id, boolean, eface, m, arr, base, node, ptr, slice}
+}
+
+func TestWriteCTypes(t *testing.T) {
+	types := cTestTypes()
+	var node *GoType
+	for _, typ := range types {
+		if typ.Name == "main.Node" {
+			node = typ
+		}
+	}
+
+	g := newCTypes(intSize64, types)
+	g.add(node)
+	var buf strings.Builder
+	g.write(&buf, CTypesHeader)
+	out := buf.String()
+
+	assert.Contains(t, out, "#include <stdint.h>")
+	assert.Contains(t, out, "typedef int64_t go_int;")
+	assert.Contains(t, out, "typedef struct main_Node main_Node;")
+	assert.Contains(t, out, "/* main.ID */\ntypedef uint16_t main_ID;")
+	assert.Contains(t, out, "struct go_slice_main_Node {\n\tmain_Node **ptr;\n\tgo_int len;\n\tgo_int cap;\n};")
+	assert.Contains(t, out, `struct main_Node {
+	main_Base Base; /* embedded */
+	uint8_t _pad0[4];
+	go_string name;
+	main_Node *next;
+	go_slice_main_Node kids;
+	main_ID vals[2];
+	uint8_t _pad1[4];
+	go_map attrs;
+	go_bool flag;
+	uint8_t _pad2[7];
+	go_eface any;
+};`)
+
+	// Types used by value are defined first.
+	assert.Less(t, strings.Index(out, "struct main_Base {"), strings.Index(out, "struct main_Node {"))
+	assert.Less(t, strings.Index(out, "typedef uint16_t main_ID;"), strings.Index(out, "struct main_Node {"))
+
+	// A slice with an unknown element type doesn't redefine the built-in
+	// slice struct.
+	g = newCTypes(intSize64, nil)
+	assert.Equal(t, "go_slice_2", g.sliceEntity(&GoType{Kind: reflect.Slice, Addr: 0x10}).name)
+}
+
+func TestWriteCTypesFormats(t *testing.T) {
+	for format, expected := range map[CTypesFormat]string{
+		CTypesGhidra: "typedef long long int64_t;",
+		CTypesIDA:    "typedef __int64 int64_t;",
+	} {
+		g := newCTypes(intSize32, cTestTypes())
+		var buf strings.Builder
+		g.write(&buf, format)
+		assert.Contains(t, buf.String(), expected)
+		assert.Contains(t, buf.String(), "typedef int32_t go_int;")
+		assert.NotContains(t, buf.String(), "#include")
+	}
+}
+
+func TestCIdentifier(t *testing.T) {
+	tests := map[string]string{
+		"main.T":                   "main_T",
+		"main.List[int]":           "main_List_int",
+		"github.com/a/b.T":         "github_com_a_b_T",
+		"map[string]*main.T":       "map_string_main_T",
+		"0day":                     "_0day",
+		"[]struct { a int }":       "struct_a_int",
+		"main.Pair[main.K,main.V]": "main_Pair_main_K_main_V",
+	}
+	for in, expected := range tests {
+		assert.Equal(t, expected, cIdentifier(in), in)
+	}
+}
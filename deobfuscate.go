@@ -0,0 +1,451 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"crypto/sha256"
+	"go/token"
+	"path"
+	"strings"
+)
+
+const (
+	// Garble hashes are between garbleMinHashLength and
+	// garbleMaxHashLength characters long.
+	garbleMinHashLength = 6
+	garbleMaxHashLength = 12
+	// garbleSumBytes is the number of hash bytes needed for a name of
+	// garbleMaxHashLength characters.
+	garbleSumBytes = 9
+)
+
+// garbleNameCharset is the base64 alphabet garble encodes hashes with.
+const garbleNameCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_z"
+
+// GarbleHash returns the name garble gives the identifier or import path
+// name when hashed with the salt and the -seed value. Without -seed, the
+// salt is the garble action ID of the package. With -seed, it is the import
+// path of the package followed by "|".
+func GarbleHash(salt, seed []byte, name string) string {
+	if name == "" {
+		return ""
+	}
+	h := sha256.New()
+	h.Write(salt)
+	h.Write(seed)
+	h.Write([]byte(name))
+	sum := h.Sum(nil)
+
+	length := garbleMinHashLength + int(sum[garbleSumBytes])%(garbleMaxHashLength-garbleMinHashLength+1)
+
+	b := make([]byte, 0, garbleSumBytes/3*4)
+	for i := 0; i < garbleSumBytes; i += 3 {
+		v := uint(sum[i])<<16 | uint(sum[i+1])<<8 | uint(sum[i+2])
+		b = append(b,
+			garbleNameCharset[v>>18&0x3f], garbleNameCharset[v>>12&0x3f],
+			garbleNameCharset[v>>6&0x3f], garbleNameCharset[v&0x3f])
+	}
+	b = b[:length]
+
+	// The result must be a valid identifier that is exported if and only if
+	// the name is, as decided by token.IsExported. Names starting with an
+	// underscore or a character that has no case are not exported.
+	if '0' <= b[0] && b[0] <= '9' {
+		b[0] += 'A' - '0'
+	}
+	if token.IsExported(name) {
+		switch {
+		case b[0] == '_':
+			b[0] = 'Z'
+		case 'a' <= b[0] && b[0] <= 'z':
+			b[0] -= 'a' - 'A'
+		}
+	} else if 'A' <= b[0] && b[0] <= 'Z' {
+		b[0] += 'a' - 'A'
+	}
+	return string(b)
+}
+
+// DeobfuscateOptions holds the input for the deobfuscation.
+type DeobfuscateOptions struct {
+	// Candidates are the possible original names. Both import paths and
+	// identifiers, for example function, method, type and field names,
+	// can be given.
+	Candidates []string
+	// Salts are the possible garble action IDs of the packages.
+	Salts [][]byte
+	// Seed is the -seed value, if known. If set, the import path salts are
+	// tried for the candidate import paths.
+	Seed []byte
+	// Reference is a binary that is not obfuscated, built with the same
+	// Go version for the same architecture. Functions that can't be
+	// recovered from the candidates are matched against its standard
+	// library functions by code hash. Can be nil.
+	Reference *GoFile
+}
+
+// Deobfuscation holds the recovered names.
+type Deobfuscation struct {
+	// Packages maps hashed import paths to the original paths.
+	Packages map[string]string
+	// Identifiers maps hashed identifiers to the original names.
+	Identifiers map[string]string
+	// Functions maps the hashed full function names to the recovered names.
+	Functions map[string]string
+
+	// matched holds the reference functions matched by code hash, keyed by
+	// the hashed full function name.
+	matched map[string]*Function
+}
+
+// Deobfuscate tries to recover the names hashed by garble. The hashes of the
+// candidates are computed for every salt and compared to the names in the
+// binary. Functions that are still not recovered are matched by code hash
+// against the standard library functions in the reference binary, if one is
+// given.
+//
+// The recovered names replace the hashed names of the packages and functions
+// returned by the file, and of the types returned by GetTypes. The package
+// classification is not changed.
+func (f *GoFile) Deobfuscate(opts *DeobfuscateOptions) (*Deobfuscation, error) {
+	pkgs, err := f.allPackages()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Deobfuscation{
+		Packages:    make(map[string]string),
+		Identifiers: make(map[string]string),
+		Functions:   make(map[string]string),
+		matched:     make(map[string]*Function),
+	}
+	d.resolveHashes(pkgs, opts)
+	if opts.Reference != nil {
+		if err := d.matchCode(f, pkgs, opts.Reference); err != nil {
+			return nil, err
+		}
+	}
+	d.renamePackages(pkgs)
+	f.deobfuscation = d
+	return d, nil
+}
+
+// resolveHashes recovers package paths and identifiers from the candidates.
+func (d *Deobfuscation) resolveHashes(pkgs []*Package, opts *DeobfuscateOptions) {
+	var paths, idents []string
+	for _, c := range opts.Candidates {
+		// Any candidate can be an import path, also the single element ones
+		// that look like identifiers.
+		paths = append(paths, c)
+		if isIdentifier(c) {
+			idents = append(idents, c)
+		}
+	}
+
+	hashes := func(salt []byte, names []string) map[string]string {
+		m := make(map[string]string, len(names))
+		for _, n := range names {
+			m[GarbleHash(salt, opts.Seed, n)] = n
+		}
+		return m
+	}
+	identTables := make(map[string]map[string]string)
+	identTable := func(salt []byte) map[string]string {
+		t, ok := identTables[string(salt)]
+		if !ok {
+			t = hashes(salt, idents)
+			identTables[string(salt)] = t
+		}
+		return t
+	}
+
+	// The salts that hashed each package, keyed by the hashed path.
+	pkgSalts := make(map[string][][]byte)
+	for _, salt := range opts.Salts {
+		for hashed, orig := range hashes(salt, paths) {
+			d.Packages[hashed] = orig
+			pkgSalts[hashed] = append(pkgSalts[hashed], salt)
+		}
+	}
+	if opts.Seed != nil {
+		for _, p := range paths {
+			salt := []byte(p + "|")
+			hashed := GarbleHash(salt, opts.Seed, p)
+			d.Packages[hashed] = p
+			pkgSalts[hashed] = append(pkgSalts[hashed], salt)
+		}
+	}
+
+	for _, p := range pkgs {
+		salts := pkgSalts[p.Name]
+		if salts == nil {
+			// The package path isn't hashed or wasn't recovered, try all.
+			salts = opts.Salts
+			if opts.Seed != nil {
+				salts = append(salts[:len(salts):len(salts)], []byte(p.Name+"|"))
+			}
+		}
+		var tables []map[string]string
+		for _, salt := range salts {
+			tables = append(tables, identTable(salt))
+		}
+		lookup := func(s string) {
+			forEachIdentifier(s, func(id string) {
+				for _, t := range tables {
+					if orig, ok := t[id]; ok {
+						d.Identifiers[id] = orig
+						return
+					}
+				}
+			})
+		}
+		for _, fn := range p.Functions {
+			lookup(fn.Name)
+		}
+		for _, m := range p.Methods {
+			lookup(m.Receiver)
+			lookup(m.Name)
+		}
+	}
+
+	// Only keep the packages in the file.
+	present := make(map[string]bool, len(pkgs))
+	for _, p := range pkgs {
+		present[p.Name] = true
+	}
+	for hashed := range d.Packages {
+		if !present[hashed] {
+			delete(d.Packages, hashed)
+		}
+	}
+}
+
+// matchCode recovers the functions that are still hashed by matching them
+// against the standard library functions in the reference. Packages that
+// are also in the reference are not obfuscated and are skipped.
+func (d *Deobfuscation) matchCode(f *GoFile, pkgs []*Package, ref *GoFile) error {
+	refStd, err := ref.GetSTDLib()
+	if err != nil {
+		return err
+	}
+	refPkgs, err := ref.allPackages()
+	if err != nil {
+		return err
+	}
+	inRef := make(map[string]bool, len(refPkgs))
+	for _, p := range refPkgs {
+		inRef[p.Name] = true
+	}
+	known := make(map[string]*Function)
+	dup := make(map[string]bool)
+	for _, fn := range packageFunctions(refStd) {
+		h, err := ref.FunctionHash(fn)
+		if err != nil {
+			continue
+		}
+		if _, ok := known[h]; ok {
+			dup[h] = true
+		}
+		known[h] = fn
+	}
+
+	for _, p := range pkgs {
+		if inRef[p.Name] || IsStandardLibrary(p.Name) {
+			continue
+		}
+		_, pkgKnown := d.Packages[p.Name]
+		for _, fn := range packageFunctions([]*Package{p}) {
+			if d.recovered(fn) {
+				continue
+			}
+			h, err := f.FunctionHash(fn)
+			if err != nil || dup[h] {
+				continue
+			}
+			match, ok := known[h]
+			if !ok {
+				continue
+			}
+			d.matched[fn.Func.Name] = match
+			if !pkgKnown {
+				d.Packages[p.Name] = match.PackageName
+				pkgKnown = true
+			}
+		}
+	}
+	return nil
+}
+
+// recovered returns true if all identifiers in the function name have been
+// recovered from the candidates.
+func (d *Deobfuscation) recovered(fn *Function) bool {
+	all := true
+	forEachIdentifier(fn.Name, func(id string) {
+		if _, ok := d.Identifiers[id]; !ok && !isClosureName(id) {
+			all = false
+		}
+	})
+	return all
+}
+
+// isClosureName returns true for the compiler generated closure names.
+func isClosureName(id string) bool {
+	return strings.HasPrefix(id, "func") || strings.HasPrefix(id, "gowrap") || strings.HasPrefix(id, "deferwrap")
+}
+
+// renamePackages applies the recovered names to the packages and their
+// functions.
+func (d *Deobfuscation) renamePackages(pkgs []*Package) {
+	for _, p := range pkgs {
+		if orig, ok := d.Packages[p.Name]; ok {
+			p.Name = orig
+		}
+		for _, fn := range p.Functions {
+			d.renameFunction(fn, p.Name)
+		}
+		for _, m := range p.Methods {
+			if match, ok := d.matched[m.Func.Name]; ok {
+				m.Receiver = match.Func.ReceiverName()
+			} else {
+				m.Receiver = d.rename(m.Receiver)
+			}
+			d.renameFunction(m.Function, p.Name)
+		}
+	}
+}
+
+func (d *Deobfuscation) renameFunction(fn *Function, pkg string) {
+	old := fn.Func.Name
+	full := old
+	if match, ok := d.matched[old]; ok {
+		fn.Name, fn.PackageName, full = match.Name, match.PackageName, match.Func.Name
+	} else {
+		full = pkg + d.rename(strings.TrimPrefix(old, fn.PackageName))
+		fn.Name, fn.PackageName = d.rename(fn.Name), pkg
+	}
+	if full != old {
+		d.Functions[old] = full
+		fn.Func.Name = full
+	}
+}
+
+// rename replaces the recovered identifiers in the string.
+func (d *Deobfuscation) rename(s string) string {
+	var b strings.Builder
+	last := 0
+	forEachIdentifierIndex(s, func(start, end int) {
+		if orig, ok := d.Identifiers[s[start:end]]; ok {
+			b.WriteString(s[last:start])
+			b.WriteString(orig)
+			last = end
+		}
+	})
+	if last == 0 {
+		return s
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// renameTypes applies the recovered names to the types.
+func (d *Deobfuscation) renameTypes(types []*GoType) {
+	seen := make(map[*GoType]bool)
+	var walk func(t *GoType)
+	walk = func(t *GoType) {
+		if t == nil || seen[t] {
+			return
+		}
+		seen[t] = true
+
+		if orig, ok := d.Packages[t.PackagePath]; ok {
+			t.PackagePath = orig
+		}
+		if i := strings.LastIndex(t.Name, "."); i != -1 {
+			pkg := t.Name[:i]
+			for hashed, orig := range d.Packages {
+				if pkg == path.Base(hashed) {
+					pkg = path.Base(orig)
+					break
+				}
+			}
+			t.Name = d.rename(pkg) + "." + d.rename(t.Name[i+1:])
+		} else {
+			t.Name = d.rename(t.Name)
+		}
+		t.FieldName = d.rename(t.FieldName)
+		for _, m := range t.Methods {
+			m.Name = d.rename(m.Name)
+			walk(m.Type)
+		}
+		for _, c := range t.Fields {
+			walk(c)
+		}
+		for _, c := range t.FuncArgs {
+			walk(c)
+		}
+		for _, c := range t.FuncReturnVals {
+			walk(c)
+		}
+		walk(t.Element)
+		walk(t.Key)
+	}
+	for _, t := range types {
+		walk(t)
+	}
+}
+
+func isIdentChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c >= 0x80
+}
+
+// isIdentifier returns true if the name is a Go identifier.
+func isIdentifier(s string) bool {
+	if s == "" || '0' <= s[0] && s[0] <= '9' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isIdentChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// forEachIdentifierIndex calls fn with the bounds of every identifier in s.
+func forEachIdentifierIndex(s string, fn func(start, end int)) {
+	start := -1
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) && isIdentChar(s[i]) {
+			if start == -1 {
+				start = i
+			}
+			continue
+		}
+		if start != -1 {
+			fn(start, i)
+			start = -1
+		}
+	}
+}
+
+// forEachIdentifier calls fn with every identifier in s.
+func forEachIdentifier(s string, fn func(id string)) {
+	forEachIdentifierIndex(s, func(start, end int) {
+		fn(s[start:end])
+	})
+}
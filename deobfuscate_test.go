@@ -0,0 +1,193 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ZxillyFork/gosym"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGarbleHash(t *testing.T) {
+	salt := []byte("action id")
+	for _, name := range []string{"getData", "Handler", "github.com/user/app", "_x"} {
+		h := GarbleHash(salt, nil, name)
+		assert.True(t, isIdentifier(h), h)
+		assert.GreaterOrEqual(t, len(h), garbleMinHashLength)
+		assert.LessOrEqual(t, len(h), garbleMaxHashLength)
+		assert.Equal(t, h, GarbleHash(salt, nil, name))
+		assert.NotEqual(t, h, GarbleHash([]byte("other"), nil, name))
+		assert.NotEqual(t, h, GarbleHash(salt, []byte("seed"), name))
+	}
+	assert.Regexp(t, "^[a-z_]", GarbleHash(salt, nil, "getData"))
+	assert.Regexp(t, "^[A-Z_]", GarbleHash(salt, nil, "Handler"))
+	assert.Empty(t, GarbleHash(salt, nil, ""))
+
+	// The expected hashes follow garble's hashWithCustomSalt: the first 9
+	// bytes of the sum are base64 encoded and the 10th byte picks the
+	// length.
+	for _, test := range []struct {
+		salt, seed, name, expected string
+	}{
+		// The length is 6 + 154%7 = 6 and 6 + 13%7 = 12.
+		{"action id", "seed", "Handler", "Ce8jzS"},
+		{"salt0", "", "Éclair", "Kpv74kbp9eMW"},
+		// An import path is not exported, the encoded "H" is lowered.
+		{"salt2", "", "github.com/user/app", "hO89ryFINBWx"},
+		// The encoded "4" becomes "E" and is lowered.
+		{"salt3", "", "getData", "eOLxMtAe2"},
+		// An exported name turns the encoded "_" into "Z".
+		{"salt11", "", "Handler", "Zk173jg9f6"},
+		// Names starting with an underscore or a non-ASCII lower case
+		// letter are not exported, the encoded upper case letter is lowered.
+		{"salt1", "", "_x", "sjYwmxzCd"},
+		{"salt2", "", "über", "y3wisYBMv6"},
+	} {
+		assert.Equal(t, test.expected, GarbleHash([]byte(test.salt), []byte(test.seed), test.name), test.name)
+	}
+}
+
+func TestDeobfuscateHashes(t *testing.T) {
+	salt := []byte("pkg salt")
+	seed := []byte("seed")
+	newFn := func(pkg, recv, name string) *Function {
+		full := pkg + "." + name
+		if recv != "" {
+			full = pkg + "." + recv + "." + name
+		}
+		return &Function{Name: name, PackageName: pkg, Func: &gosym.Func{Sym: &gosym.Sym{Name: full}}}
+	}
+
+	hPkg := GarbleHash(salt, nil, "example.com/app/store")
+	hLoad := GarbleHash(salt, nil, "Load")
+	hStore := GarbleHash(salt, nil, "Store")
+	hSave := GarbleHash(salt, nil, "save")
+	// The main package isn't renamed, so with -seed its salt is its path.
+	mSalt := []byte("main|")
+	hRun := GarbleHash(mSalt, seed, "run")
+
+	store := &Package{
+		Name:      hPkg,
+		Functions: []*Function{newFn(hPkg, "", hLoad), newFn(hPkg, "", hLoad+".func1")},
+		Methods:   []*Method{{Receiver: "(*" + hStore + ")", Function: newFn(hPkg, "(*"+hStore+")", hSave)}},
+	}
+	unknown := GarbleHash([]byte("missing"), nil, "secret")
+	main := &Package{
+		Name:      "main",
+		Functions: []*Function{newFn("main", "", "main"), newFn("main", "", hRun), newFn("main", "", unknown)},
+	}
+
+	d := &Deobfuscation{
+		Packages:    make(map[string]string),
+		Identifiers: make(map[string]string),
+		Functions:   make(map[string]string),
+		matched:     make(map[string]*Function),
+	}
+	opts := &DeobfuscateOptions{
+		Candidates: []string{"example.com/app/store", "Load", "Store", "save", "run", "unused"},
+		Salts:      [][]byte{[]byte("wrong"), salt},
+	}
+	d.resolveHashes([]*Package{store, main}, opts)
+	// Without the seed the main package identifiers can't be recovered.
+	assert.NotContains(t, d.Identifiers, hRun)
+
+	opts.Seed = seed
+	// Hashes with a seed differ, so try again with the seed for the main
+	// package only.
+	opts.Salts = [][]byte{[]byte("wrong")}
+	d.resolveHashes([]*Package{main}, opts)
+	assert.Equal(t, "run", d.Identifiers[hRun])
+
+	opts.Seed = nil
+	opts.Salts = [][]byte{salt}
+	d.resolveHashes([]*Package{store}, opts)
+	assert.Equal(t, "example.com/app/store", d.Packages[hPkg])
+	assert.Equal(t, "Load", d.Identifiers[hLoad])
+	assert.Equal(t, "Store", d.Identifiers[hStore])
+	assert.Equal(t, "save", d.Identifiers[hSave])
+
+	d.renamePackages([]*Package{store, main})
+	assert.Equal(t, "example.com/app/store", store.Name)
+	assert.Equal(t, "Load", store.Functions[0].Name)
+	assert.Equal(t, "Load.func1", store.Functions[1].Name)
+	assert.Equal(t, "example.com/app/store.Load.func1", store.Functions[1].Func.Name)
+	assert.Equal(t, "(*Store)", store.Methods[0].Receiver)
+	assert.Equal(t, "save", store.Methods[0].Name)
+	assert.Equal(t, "example.com/app/store.(*Store).save", store.Methods[0].Func.Name)
+	assert.Equal(t, "run", main.Functions[1].Name)
+	assert.Equal(t, unknown, main.Functions[2].Name)
+	assert.Equal(t, "main.run", d.Functions["main."+hRun])
+
+	typ := &GoType{Kind: reflect.Struct, Name: hPkg + "." + hStore, PackagePath: hPkg}
+	typ.Fields = []*GoType{{Kind: reflect.Ptr, FieldName: hLoad, Element: typ}}
+	d.renameTypes([]*GoType{typ})
+	assert.Equal(t, "store.Store", typ.Name)
+	assert.Equal(t, "example.com/app/store", typ.PackagePath)
+	assert.Equal(t, "Load", typ.Fields[0].FieldName)
+}
+
+func TestDeobfuscateReference(t *testing.T) {
+	exe := buildTestBinary(t, testresourcesrc)
+	ref, err := Open(exe)
+	require.NoError(t, err)
+	defer ref.Close()
+
+	f, err := Open(exe)
+	require.NoError(t, err)
+	defer f.Close()
+
+	// Obfuscate the fmt package like garble -tiny does.
+	std, err := f.GetSTDLib()
+	require.NoError(t, err)
+	salt := []byte("salt")
+	hFmt := GarbleHash(salt, nil, "fmt")
+	for _, p := range std {
+		if p.Name != "fmt" {
+			continue
+		}
+		p.Name = hFmt
+		for _, fn := range p.Functions {
+			fn.Name = GarbleHash(salt, nil, fn.Name)
+			fn.PackageName = hFmt
+			fn.Func.Name = hFmt + "." + fn.Name
+		}
+	}
+
+	d, err := f.Deobfuscate(&DeobfuscateOptions{Reference: ref})
+	require.NoError(t, err)
+	assert.Equal(t, "fmt", d.Packages[hFmt])
+	assert.Contains(t, d.Functions, hFmt+"."+GarbleHash(salt, nil, "Fprintln"))
+
+	var found bool
+	for _, p := range std {
+		if p.Name != "fmt" {
+			continue
+		}
+		for _, fn := range p.Functions {
+			if fn.Name == "Fprintln" {
+				found = true
+				assert.Equal(t, "fmt", fn.PackageName)
+				assert.Equal(t, "fmt.Fprintln", fn.Func.Name)
+			}
+		}
+	}
+	assert.True(t, found, "fmt.Fprintln was not recovered")
+}
@@ -0,0 +1,494 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// FileDiff is the structured change report produced by Diff. Names of
+// removed entries are taken from the old file and names of added entries
+// from the new file.
+type FileDiff struct {
+	// Compiler is set if the compiler version changed.
+	Compiler *Change `json:"compiler,omitempty"`
+	// Packages holds the added and removed packages.
+	Packages *PackageDiff `json:"packages"`
+	// Functions holds the changes to functions and methods.
+	Functions *FunctionDiff `json:"functions"`
+	// Types holds the changes to named types. It is nil if the types could
+	// not be extracted from one of the files.
+	Types *TypeDiff `json:"types,omitempty"`
+	// Modules holds the changes to the module dependencies. It is nil if
+	// one of the files has no module information.
+	Modules *ModuleDiff `json:"modules,omitempty"`
+	// Notes explains why parts of the files could not be compared.
+	Notes []string `json:"notes,omitempty"`
+}
+
+// Change is a value that differs between the two files.
+type Change struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// PackageDiff lists the packages that only exist in one of the files.
+type PackageDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// FunctionDiff holds the function and method changes. Functions are
+// identified by their full symbol name, for example "main.(*T).String".
+type FunctionDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	// Modified are the functions that exist in both files but have a
+	// different normalized body hash. See FunctionHash.
+	Modified []string `json:"modified,omitempty"`
+	// Renamed are functions that only exist under different names in the
+	// two files but have the same body hash.
+	Renamed []*Change `json:"renamed,omitempty"`
+}
+
+// TypeDiff holds the changes to the named types. Types are identified by
+// their package path and name, for example "example.com/app/store.Config".
+type TypeDiff struct {
+	Added    []string      `json:"added,omitempty"`
+	Removed  []string      `json:"removed,omitempty"`
+	Modified []*TypeChange `json:"modified,omitempty"`
+}
+
+// TypeChange describes how a named type changed.
+type TypeChange struct {
+	// Name of the type with its package path.
+	Name string `json:"name"`
+	// Kind is set if the kind of the type changed.
+	Kind *Change `json:"kind,omitempty"`
+	// FieldsAdded are the struct fields only in the new type.
+	FieldsAdded []string `json:"fieldsAdded,omitempty"`
+	// FieldsRemoved are the struct fields only in the old type.
+	FieldsRemoved []string `json:"fieldsRemoved,omitempty"`
+	// FieldsChanged are the struct fields whose type or tag changed.
+	FieldsChanged []*FieldChange `json:"fieldsChanged,omitempty"`
+	// MethodsAdded are the methods only in the new type.
+	MethodsAdded []string `json:"methodsAdded,omitempty"`
+	// MethodsRemoved are the methods only in the old type.
+	MethodsRemoved []string `json:"methodsRemoved,omitempty"`
+}
+
+// FieldChange describes how a struct field changed.
+type FieldChange struct {
+	// Name of the field.
+	Name string `json:"name"`
+	// Type is set if the field's type changed.
+	Type *Change `json:"type,omitempty"`
+	// Tag is set if the field's tag changed.
+	Tag *Change `json:"tag,omitempty"`
+}
+
+// ModuleDiff holds the changes to the modules the files were built from.
+type ModuleDiff struct {
+	Added   []*ModuleVersion `json:"added,omitempty"`
+	Removed []*ModuleVersion `json:"removed,omitempty"`
+	Updated []*ModuleChange  `json:"updated,omitempty"`
+}
+
+// ModuleVersion is a module and the version it was built with.
+type ModuleVersion struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+}
+
+// ModuleChange is a module whose version changed.
+type ModuleChange struct {
+	Path string `json:"path"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// Diff compares the old file a with the new file b. An error is only returned
+// if the packages can't be extracted from one of the files. If other parts of
+// the files can't be compared, the reason is recorded in the notes of the
+// report.
+func Diff(a, b *GoFile) (*FileDiff, error) {
+	result := &FileDiff{}
+
+	oldPkgs, err := a.allPackages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get packages from the old file: %w", err)
+	}
+	newPkgs, err := b.allPackages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get packages from the new file: %w", err)
+	}
+	result.Packages = diffPackages(oldPkgs, newPkgs)
+
+	var notes []string
+	result.Functions, notes = diffFunctions(a, oldPkgs, b, newPkgs)
+	result.Notes = append(result.Notes, notes...)
+
+	oldVer, oldErr := a.GetCompilerVersion()
+	newVer, newErr := b.GetCompilerVersion()
+	switch {
+	case oldErr != nil:
+		result.Notes = append(result.Notes, "compiler version of the old file is unknown: "+oldErr.Error())
+	case newErr != nil:
+		result.Notes = append(result.Notes, "compiler version of the new file is unknown: "+newErr.Error())
+	case oldVer.Name != newVer.Name:
+		result.Compiler = &Change{Old: oldVer.Name, New: newVer.Name}
+	}
+
+	oldTypes, oldErr := a.GetTypes()
+	newTypes, newErr := b.GetTypes()
+	switch {
+	case oldErr != nil:
+		result.Notes = append(result.Notes, "types of the old file could not be extracted: "+oldErr.Error())
+	case newErr != nil:
+		result.Notes = append(result.Notes, "types of the new file could not be extracted: "+newErr.Error())
+	default:
+		result.Types = diffTypes(oldTypes, newTypes)
+	}
+
+	if a.BuildInfo == nil || a.BuildInfo.ModInfo == nil || b.BuildInfo == nil || b.BuildInfo.ModInfo == nil {
+		result.Notes = append(result.Notes, "module information is missing from at least one of the files")
+	} else {
+		result.Modules = diffModules(a.BuildInfo.ModInfo, b.BuildInfo.ModInfo)
+	}
+
+	return result, nil
+}
+
+// allPackages returns the packages of all classes.
+func (f *GoFile) allPackages() ([]*Package, error) {
+	if err := f.initPackages(); err != nil {
+		return nil, err
+	}
+	var pkgs []*Package
+	for _, class := range [][]*Package{f.pkgs, f.vendors, f.stdPkgs, f.generated, f.unknown} {
+		pkgs = append(pkgs, class...)
+	}
+	return pkgs, nil
+}
+
+func diffPackages(a, b []*Package) *PackageDiff {
+	names := func(pkgs []*Package) map[string]bool {
+		m := make(map[string]bool, len(pkgs))
+		for _, p := range pkgs {
+			m[p.Name] = true
+		}
+		return m
+	}
+	added, removed := diffKeys(names(a), names(b))
+	return &PackageDiff{Added: added, Removed: removed}
+}
+
+// functionName returns the full symbol name of the function.
+func functionName(fn *Function) string {
+	if fn.Func != nil {
+		return fn.Func.Name
+	}
+	return fn.PackageName + "." + fn.Name
+}
+
+func packageFunctions(pkgs []*Package) map[string]*Function {
+	fns := make(map[string]*Function)
+	for _, p := range pkgs {
+		for _, fn := range p.Functions {
+			fns[functionName(fn)] = fn
+		}
+		for _, m := range p.Methods {
+			fns[functionName(m.Function)] = m.Function
+		}
+	}
+	return fns
+}
+
+func diffFunctions(a *GoFile, aPkgs []*Package, b *GoFile, bPkgs []*Package) (*FunctionDiff, []string) {
+	oldFns := packageFunctions(aPkgs)
+	newFns := packageFunctions(bPkgs)
+	result := &FunctionDiff{}
+
+	// failed holds the functions whose code could not be read, by name so
+	// that a function that fails in both files is counted once.
+	failed := make(map[string]bool)
+	hash := func(f *GoFile, fn *Function) string {
+		h, err := f.FunctionHash(fn)
+		if err != nil {
+			failed[functionName(fn)] = true
+		}
+		return h
+	}
+
+	for name, oldFn := range oldFns {
+		newFn, ok := newFns[name]
+		if !ok {
+			continue
+		}
+		oldHash, newHash := hash(a, oldFn), hash(b, newFn)
+		if oldHash != "" && newHash != "" && oldHash != newHash {
+			result.Modified = append(result.Modified, name)
+		}
+	}
+	sort.Strings(result.Modified)
+
+	// Functions that only exist in one of the files are renamed if their body
+	// hash is unique among those functions and matches exactly one function
+	// in the other file.
+	uniqueHashes := func(f *GoFile, fns map[string]*Function, other map[string]*Function) map[string]string {
+		byHash := make(map[string]string)
+		dup := make(map[string]bool)
+		for name, fn := range fns {
+			if _, ok := other[name]; ok {
+				continue
+			}
+			h := hash(f, fn)
+			if h == "" {
+				continue
+			}
+			if _, ok := byHash[h]; ok {
+				dup[h] = true
+			}
+			byHash[h] = name
+		}
+		for h := range dup {
+			delete(byHash, h)
+		}
+		return byHash
+	}
+	oldOnly := uniqueHashes(a, oldFns, newFns)
+	newOnly := uniqueHashes(b, newFns, oldFns)
+	renamed := make(map[string]bool)
+	for h, oldName := range oldOnly {
+		newName, ok := newOnly[h]
+		if !ok {
+			continue
+		}
+		result.Renamed = append(result.Renamed, &Change{Old: oldName, New: newName})
+		renamed[oldName] = true
+		renamed[newName] = true
+	}
+	sort.Slice(result.Renamed, func(i, j int) bool {
+		return result.Renamed[i].Old < result.Renamed[j].Old
+	})
+
+	for name := range oldFns {
+		if _, ok := newFns[name]; !ok && !renamed[name] {
+			result.Removed = append(result.Removed, name)
+		}
+	}
+	for name := range newFns {
+		if _, ok := oldFns[name]; !ok && !renamed[name] {
+			result.Added = append(result.Added, name)
+		}
+	}
+	sort.Strings(result.Removed)
+	sort.Strings(result.Added)
+
+	var notes []string
+	if len(failed) != 0 {
+		notes = append(notes, fmt.Sprintf("the code of %d functions could not be read, they were not compared", len(failed)))
+	}
+	return result, notes
+}
+
+// qualifiedTypeName returns the name of the type with the package path
+// instead of the package name, for example "example.com/app/store.Config".
+// Types of different packages with the same name can be told apart by it.
+func qualifiedTypeName(t *GoType) string {
+	name := t.Name
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+	return t.PackagePath + "." + name
+}
+
+// namedTypes returns the named types keyed by their qualified name.
+func namedTypes(types []*GoType) map[string]*GoType {
+	m := make(map[string]*GoType)
+	for _, t := range types {
+		if t.Name == "" || t.PackagePath == "" {
+			continue
+		}
+		key := qualifiedTypeName(t)
+		if _, ok := m[key]; !ok {
+			m[key] = t
+		}
+	}
+	return m
+}
+
+func diffTypes(a, b []*GoType) *TypeDiff {
+	oldTypes := namedTypes(a)
+	newTypes := namedTypes(b)
+	result := &TypeDiff{}
+
+	for key, oldType := range oldTypes {
+		newType, ok := newTypes[key]
+		if !ok {
+			result.Removed = append(result.Removed, key)
+			continue
+		}
+		if c := diffType(oldType, newType); c != nil {
+			result.Modified = append(result.Modified, c)
+		}
+	}
+	for key := range newTypes {
+		if _, ok := oldTypes[key]; !ok {
+			result.Added = append(result.Added, key)
+		}
+	}
+
+	sort.Strings(result.Added)
+	sort.Strings(result.Removed)
+	sort.Slice(result.Modified, func(i, j int) bool {
+		return result.Modified[i].Name < result.Modified[j].Name
+	})
+	return result
+}
+
+// diffType returns the changes between the two versions of the type or nil
+// if they are the same.
+func diffType(a, b *GoType) *TypeChange {
+	c := &TypeChange{Name: qualifiedTypeName(b)}
+	changed := false
+
+	if a.Kind != b.Kind {
+		c.Kind = &Change{Old: a.Kind.String(), New: b.Kind.String()}
+		changed = true
+	}
+
+	fields := func(t *GoType) map[string]*GoType {
+		m := make(map[string]*GoType, len(t.Fields))
+		for _, f := range t.Fields {
+			m[f.FieldName] = f
+		}
+		return m
+	}
+	oldFields, newFields := fields(a), fields(b)
+	for name, of := range oldFields {
+		nf, ok := newFields[name]
+		if !ok {
+			c.FieldsRemoved = append(c.FieldsRemoved, name)
+			continue
+		}
+		fc := &FieldChange{Name: name}
+		if ot, nt := of.String(), nf.String(); ot != nt {
+			fc.Type = &Change{Old: ot, New: nt}
+		}
+		if of.FieldTag != nf.FieldTag {
+			fc.Tag = &Change{Old: of.FieldTag, New: nf.FieldTag}
+		}
+		if fc.Type != nil || fc.Tag != nil {
+			c.FieldsChanged = append(c.FieldsChanged, fc)
+		}
+	}
+	for name := range newFields {
+		if _, ok := oldFields[name]; !ok {
+			c.FieldsAdded = append(c.FieldsAdded, name)
+		}
+	}
+	sort.Strings(c.FieldsAdded)
+	sort.Strings(c.FieldsRemoved)
+	sort.Slice(c.FieldsChanged, func(i, j int) bool {
+		return c.FieldsChanged[i].Name < c.FieldsChanged[j].Name
+	})
+
+	methods := func(t *GoType) map[string]bool {
+		m := make(map[string]bool, len(t.Methods))
+		for _, meth := range t.Methods {
+			m[meth.Name] = true
+		}
+		return m
+	}
+	c.MethodsAdded, c.MethodsRemoved = diffKeys(methods(a), methods(b))
+
+	if changed || len(c.FieldsAdded) != 0 || len(c.FieldsRemoved) != 0 || len(c.FieldsChanged) != 0 ||
+		len(c.MethodsAdded) != 0 || len(c.MethodsRemoved) != 0 {
+		return c
+	}
+	return nil
+}
+
+// moduleVersion returns the version of the module that was used in the build.
+// If the module was replaced, the replacement is included.
+func moduleVersion(m *debug.Module) string {
+	if m.Replace == nil {
+		return m.Version
+	}
+	if m.Replace.Path == m.Path {
+		return m.Replace.Version
+	}
+	if m.Replace.Version == "" {
+		return m.Replace.Path
+	}
+	return m.Replace.Path + "@" + m.Replace.Version
+}
+
+func diffModules(a, b *debug.BuildInfo) *ModuleDiff {
+	modules := func(bi *debug.BuildInfo) map[string]string {
+		m := map[string]string{bi.Main.Path: moduleVersion(&bi.Main)}
+		for _, dep := range bi.Deps {
+			m[dep.Path] = moduleVersion(dep)
+		}
+		return m
+	}
+	oldMods, newMods := modules(a), modules(b)
+	result := &ModuleDiff{}
+
+	for path, oldVer := range oldMods {
+		newVer, ok := newMods[path]
+		if !ok {
+			result.Removed = append(result.Removed, &ModuleVersion{Path: path, Version: oldVer})
+			continue
+		}
+		if oldVer != newVer {
+			result.Updated = append(result.Updated, &ModuleChange{Path: path, Old: oldVer, New: newVer})
+		}
+	}
+	for path, newVer := range newMods {
+		if _, ok := oldMods[path]; !ok {
+			result.Added = append(result.Added, &ModuleVersion{Path: path, Version: newVer})
+		}
+	}
+
+	sort.Slice(result.Added, func(i, j int) bool { return result.Added[i].Path < result.Added[j].Path })
+	sort.Slice(result.Removed, func(i, j int) bool { return result.Removed[i].Path < result.Removed[j].Path })
+	sort.Slice(result.Updated, func(i, j int) bool { return result.Updated[i].Path < result.Updated[j].Path })
+	return result
+}
+
+// diffKeys returns the sorted keys only in b and only in a.
+func diffKeys(a, b map[string]bool) (added, removed []string) {
+	for k := range b {
+		if !a[k] {
+			added = append(added, k)
+		}
+	}
+	for k := range a {
+		if !b[k] {
+			removed = append(removed, k)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
@@ -0,0 +1,247 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"encoding/binary"
+	"reflect"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeCode(t *testing.T) {
+	tests := []struct {
+		name string
+		info *FileInfo
+		a, b []byte
+	}{
+		{
+			"amd64 call and rip-relative lea",
+			&FileInfo{Arch: ArchAMD64, ByteOrder: binary.LittleEndian},
+			// call rel32; lea rax, [rip+disp32]; ret; int3 padding
+			[]byte{0xe8, 0x10, 0x00, 0x00, 0x00, 0x48, 0x8d, 0x05, 0x00, 0x10, 0x00, 0x00, 0xc3, 0xcc, 0xcc},
+			[]byte{0xe8, 0x20, 0x01, 0x00, 0x00, 0x48, 0x8d, 0x05, 0x00, 0x20, 0x00, 0x00, 0xc3, 0xcc},
+		},
+		{
+			"amd64 avx-512 rip-relative load",
+			&FileInfo{Arch: ArchAMD64, ByteOrder: binary.LittleEndian},
+			// vmovdqu64 zmm0, [rip+disp32]; ret
+			[]byte{0x62, 0xf1, 0xfe, 0x48, 0x6f, 0x05, 0x36, 0x3c, 0x09, 0x00, 0xc3},
+			[]byte{0x62, 0xf1, 0xfe, 0x48, 0x6f, 0x05, 0xb6, 0x4c, 0x09, 0x00, 0xc3},
+		},
+		{
+			"386 absolute address",
+			&FileInfo{Arch: Arch386, ByteOrder: binary.LittleEndian},
+			// mov eax, [disp32]; ret
+			[]byte{0x8b, 0x05, 0x00, 0x10, 0x40, 0x00, 0xc3},
+			[]byte{0x8b, 0x05, 0x00, 0x20, 0x40, 0x00, 0xc3},
+		},
+		{
+			"arm64 bl and adrp add",
+			&FileInfo{Arch: ArchARM64, ByteOrder: binary.LittleEndian},
+			// bl; adrp x0; add x0, x0, #imm; ret
+			le32(0x94000010, 0x90000020, 0x91004000, 0xd65f03c0, 0),
+			le32(0x94000400, 0xb0000040, 0x91008000, 0xd65f03c0),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.NotEqual(t, test.a, test.b)
+			assert.Equal(t, normalizeCode(test.a, test.info), normalizeCode(test.b, test.info))
+		})
+	}
+
+	t.Run("code changes are kept", func(t *testing.T) {
+		info := &FileInfo{Arch: ArchAMD64, ByteOrder: binary.LittleEndian}
+		// mov eax, 1; ret vs mov eax, 2; ret
+		a := []byte{0xb8, 0x01, 0x00, 0x00, 0x00, 0xc3}
+		b := []byte{0xb8, 0x02, 0x00, 0x00, 0x00, 0xc3}
+		assert.NotEqual(t, normalizeCode(a, info), normalizeCode(b, info))
+	})
+}
+
+func le32(insns ...uint32) []byte {
+	var buf []byte
+	for _, i := range insns {
+		buf = binary.LittleEndian.AppendUint32(buf, i)
+	}
+	return buf
+}
+
+func TestDiffFunctionsUnreadable(t *testing.T) {
+	fh := &mockFileHandler{
+		mGetSectionDataFromAddress: func(uint64) (uint64, []byte, error) {
+			return 0, nil, ErrSectionDoesNotExist
+		},
+	}
+	a := &GoFile{fh: fh, FileInfo: &FileInfo{Arch: ArchAMD64}}
+	b := &GoFile{fh: fh, FileInfo: &FileInfo{Arch: ArchAMD64}}
+	pkgs := func(names ...string) []*Package {
+		p := &Package{Name: "main"}
+		for _, name := range names {
+			p.Functions = append(p.Functions, &Function{Name: name, PackageName: "main", Offset: 0x1000, End: 0x1010})
+		}
+		return []*Package{p}
+	}
+
+	// The function in both files is counted once.
+	d, notes := diffFunctions(a, pkgs("main", "old"), b, pkgs("main"))
+	assert.Empty(t, d.Modified)
+	assert.Equal(t, []string{"main.old"}, d.Removed)
+	assert.Equal(t, []string{"the code of 2 functions could not be read, they were not compared"}, notes)
+}
+
+func TestDiffTypes(t *testing.T) {
+	assert := assert.New(t)
+
+	intType := &GoType{Kind: reflect.Int}
+	stringType := &GoType{Kind: reflect.String}
+	field := func(name string, typ *GoType, tag string) *GoType {
+		f := *typ
+		f.FieldName = name
+		f.FieldTag = tag
+		return &f
+	}
+
+	oldTypes := []*GoType{
+		{
+			Kind: reflect.Struct, Name: "main.config", PackagePath: "main",
+			Fields: []*GoType{
+				field("Name", stringType, `json:"name"`),
+				field("Port", intType, ""),
+				field("Debug", intType, ""),
+			},
+			Methods: []*TypeMethod{{Name: "Load"}},
+		},
+		{Kind: reflect.Int, Name: "main.level", PackagePath: "main"},
+		{Kind: reflect.Struct, Name: "main.gone", PackagePath: "main"},
+		// Types of different packages with the same name are kept apart.
+		{Kind: reflect.Struct, Name: "store.Config", PackagePath: "example.com/a/store"},
+		// Unnamed types are not compared.
+		{Kind: reflect.Slice, Element: intType},
+	}
+	newTypes := []*GoType{
+		{
+			Kind: reflect.Struct, Name: "main.config", PackagePath: "main",
+			Fields: []*GoType{
+				field("Name", stringType, `json:"name,omitempty"`),
+				field("Port", stringType, ""),
+				field("Timeout", intType, ""),
+			},
+			Methods: []*TypeMethod{{Name: "Save"}},
+		},
+		{Kind: reflect.Int, Name: "main.level", PackagePath: "main"},
+		{Kind: reflect.Struct, Name: "main.fresh", PackagePath: "main"},
+		{Kind: reflect.Struct, Name: "store.Config", PackagePath: "example.com/b/store"},
+	}
+
+	d := diffTypes(oldTypes, newTypes)
+	assert.Equal([]string{"example.com/b/store.Config", "main.fresh"}, d.Added)
+	assert.Equal([]string{"example.com/a/store.Config", "main.gone"}, d.Removed)
+	require.Len(t, d.Modified, 1)
+
+	c := d.Modified[0]
+	assert.Equal("main.config", c.Name)
+	assert.Nil(c.Kind)
+	assert.Equal([]string{"Timeout"}, c.FieldsAdded)
+	assert.Equal([]string{"Debug"}, c.FieldsRemoved)
+	assert.Equal([]*FieldChange{
+		{Name: "Name", Tag: &Change{Old: `json:"name"`, New: `json:"name,omitempty"`}},
+		{Name: "Port", Type: &Change{Old: "int", New: "string"}},
+	}, c.FieldsChanged)
+	assert.Equal([]string{"Save"}, c.MethodsAdded)
+	assert.Equal([]string{"Load"}, c.MethodsRemoved)
+}
+
+func TestDiffModules(t *testing.T) {
+	oldInfo := &debug.BuildInfo{
+		Main: debug.Module{Path: "example.com/app", Version: "v1.0.0"},
+		Deps: []*debug.Module{
+			{Path: "example.com/a", Version: "v1.2.0"},
+			{Path: "example.com/b", Version: "v0.1.0"},
+			{Path: "example.com/c", Version: "v1.0.0", Replace: &debug.Module{Path: "example.com/c", Version: "v1.0.1"}},
+		},
+	}
+	newInfo := &debug.BuildInfo{
+		Main: debug.Module{Path: "example.com/app", Version: "v1.1.0"},
+		Deps: []*debug.Module{
+			{Path: "example.com/a", Version: "v1.3.0"},
+			{Path: "example.com/c", Version: "v1.0.0", Replace: &debug.Module{Path: "../c"}},
+			{Path: "example.com/d", Version: "v2.0.0"},
+		},
+	}
+
+	d := diffModules(oldInfo, newInfo)
+	assert.Equal(t, []*ModuleVersion{{Path: "example.com/d", Version: "v2.0.0"}}, d.Added)
+	assert.Equal(t, []*ModuleVersion{{Path: "example.com/b", Version: "v0.1.0"}}, d.Removed)
+	assert.Equal(t, []*ModuleChange{
+		{Path: "example.com/a", Old: "v1.2.0", New: "v1.3.0"},
+		{Path: "example.com/app", Old: "v1.0.0", New: "v1.1.0"},
+		{Path: "example.com/c", Old: "v1.0.1", New: "../c"},
+	}, d.Updated)
+}
+
+func TestDiff(t *testing.T) {
+	r := require.New(t)
+
+	oldFile, err := Open(buildTestBinary(t, testresourcesrc))
+	r.NoError(err)
+	defer oldFile.Close()
+
+	newFile, err := Open(buildTestBinary(t, diffNewSrc))
+	r.NoError(err)
+	defer newFile.Close()
+
+	d, err := Diff(oldFile, newFile)
+	r.NoError(err)
+
+	r.Nil(d.Compiler, "Same compiler should not be reported as changed")
+	r.Contains(d.Functions.Modified, "main.getData")
+	r.Contains(d.Functions.Added, "main.extra")
+	r.NotContains(d.Functions.Modified, "runtime.main", "Moved code should not be reported as modified")
+	r.Contains(d.Packages.Added, "strings")
+	r.NotNil(d.Modules)
+}
+
+const diffNewSrc = `
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+//go:noinline
+func getData() string {
+	return strings.ToUpper("Name: GoRE")
+}
+
+//go:noinline
+func extra() int {
+	return 42
+}
+
+func main() {
+	fmt.Println(getData(), extra())
+	fmt.Println(runtime.GOROOT())
+}
+`
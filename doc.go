@@ -46,8 +46,16 @@ Type recovery
 
 The types in the binary are parsed from the "typelink" list. Not
 all versions of Go are supported equally. Versions 1.7 and later
-are fully supported. Versions 1.5 and 1.6 are partially supported.
-Version prior to 1.5 are not supported at all at the moment.
+are fully supported. Versions 1.2 to 1.6 are partially supported.
+Binaries compiled before 1.5 have no moduledata, so the typelinks
+are located by the linker symbols or by scanning the binary.
+
+Binaries compiled by gccgo and TinyGo, including TinyGo WebAssembly
+modules, have no PCLN table. Their functions are recovered from the
+symbols and the DWARF data, and their types from the symbols of the
+type descriptors, so stripped binaries have no types. WebAssembly
+modules only name their functions and not their data, so no types
+are recovered from them.
 
 Example code
 
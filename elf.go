@@ -133,6 +133,10 @@ func (e *elfFile) getPCLNTABData() (uint64, []byte, error) {
 	// in the .data.rel.ro section. Because it's not in its own section, we will have to
 	// search for it in the section.
 	start, data, err := e.getSectionData(".data.rel.ro")
+	if errors.Is(err, ErrSectionDoesNotExist) {
+		// Without any of the sections, the file is most likely not a Go binary.
+		return 0, nil, ErrNoPCLNTab
+	}
 	if err != nil {
 		return 0, nil, fmt.Errorf("failed to get section: .data.rel.ro: %w", err)
 	}
@@ -152,6 +156,10 @@ func (e *elfFile) getPCLNTABData() (uint64, []byte, error) {
 }
 
 func (e *elfFile) moduledataSection() string {
+	// Newer linkers store the moduledata in its own section.
+	if e.file.Section(".go.module") != nil {
+		return ".go.module"
+	}
 	return ".noptrdata"
 }
 
@@ -199,6 +207,8 @@ func (e *elfFile) getFileInfo() *FileInfo {
 		arch = ArchAMD64
 	case elf.EM_ARM:
 		arch = ArchARM
+	case elf.EM_AARCH64:
+		arch = ArchARM64
 	}
 
 	return &FileInfo{
@@ -35,4 +35,7 @@ var (
 	ErrInvalidGoVersion = errors.New("invalid go version")
 	// ErrNoGoRootFound is returned if no goroot was found in the binary.
 	ErrNoGoRootFound = errors.New("no goroot found")
+	// ErrNoModuledata is returned for binaries without a moduledata structure,
+	// which are the ones compiled by gccgo, TinyGo or before Go 1.5.
+	ErrNoModuledata = errors.New("the binary has no moduledata")
 )
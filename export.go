@@ -0,0 +1,392 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"sort"
+)
+
+// ExportSchemaVersion is the version of the JSON schema written by Export.
+// It is incremented whenever a field is removed or changes meaning. New
+// fields may be added without changing the version.
+const ExportSchemaVersion = 1
+
+// Report is the full analysis model of a file in a form that can be
+// serialized. Types are stored in a flat list and refer to each other by
+// their ID, which is the index of the type in the list. This breaks the
+// cycles that exist between GoType values.
+type Report struct {
+	// SchemaVersion is the version of the report schema.
+	SchemaVersion int `json:"schemaVersion"`
+	// FileInfo holds information about the file.
+	FileInfo *ReportFileInfo `json:"fileInfo"`
+	// BuildID is the Go build ID extracted from the binary.
+	BuildID string `json:"buildID,omitempty"`
+	// Compiler is the compiler version, if it could be determined.
+	Compiler *GoVersion `json:"compiler,omitempty"`
+	// Toolchain describes the toolchain if it's not an unmodified Go
+	// release.
+	Toolchain *Toolchain `json:"toolchain,omitempty"`
+	// Instrumentation describes the instrumentation of the build, for
+	// example the race detector, if there is any.
+	Instrumentation *Instrumentation `json:"instrumentation,omitempty"`
+	// GoRoot is the GOROOT used when the binary was built.
+	GoRoot string `json:"goroot,omitempty"`
+	// BuildInfo holds the data from the buildinfo structure.
+	BuildInfo *ReportBuildInfo `json:"buildInfo,omitempty"`
+	// Packages holds all packages found in the binary.
+	Packages []*ReportPackage `json:"packages"`
+	// Types holds all types found in the binary.
+	Types []*ReportType `json:"types,omitempty"`
+	// Errors holds the errors for the parts of the analysis that failed,
+	// keyed by the name of the report field that could not be populated.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// ReportFileInfo is the serializable form of FileInfo.
+type ReportFileInfo struct {
+	Arch      string `json:"arch"`
+	OS        string `json:"os"`
+	ByteOrder string `json:"byteOrder"`
+	WordSize  int    `json:"wordSize"`
+}
+
+// ReportBuildInfo is the serializable form of BuildInfo.
+type ReportBuildInfo struct {
+	GoVersion string                `json:"goVersion"`
+	Path      string                `json:"path"`
+	Main      *ReportModule         `json:"main,omitempty"`
+	Deps      []*ReportModule       `json:"deps,omitempty"`
+	Settings  []*ReportBuildSetting `json:"settings,omitempty"`
+}
+
+// ReportModule is the serializable form of a module dependency.
+type ReportModule struct {
+	Path    string        `json:"path"`
+	Version string        `json:"version,omitempty"`
+	Sum     string        `json:"sum,omitempty"`
+	Replace *ReportModule `json:"replace,omitempty"`
+}
+
+// ReportBuildSetting is a key/value pair from the build settings.
+type ReportBuildSetting struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ReportPackage is the serializable form of a Package.
+type ReportPackage struct {
+	Name        string              `json:"name"`
+	Path        string              `json:"path"`
+	Class       string              `json:"class"`
+	Functions   []*ReportFunction   `json:"functions"`
+	Methods     []*ReportFunction   `json:"methods"`
+	SourceFiles []*ReportSourceFile `json:"sourceFiles,omitempty"`
+}
+
+// ReportFunction is the serializable form of a Function or a Method.
+type ReportFunction struct {
+	Name     string `json:"name"`
+	Receiver string `json:"receiver,omitempty"`
+	Offset   uint64 `json:"offset"`
+	End      uint64 `json:"end"`
+}
+
+// ReportSourceFile is the serializable form of a SourceFile.
+type ReportSourceFile struct {
+	Name    string      `json:"name"`
+	Entries []FileEntry `json:"entries"`
+}
+
+// ReportType is the serializable form of a GoType. All references to other
+// types are IDs of types in the report's type list.
+type ReportType struct {
+	ID          int                 `json:"id"`
+	Kind        string              `json:"kind"`
+	Name        string              `json:"name,omitempty"`
+	PackagePath string              `json:"packagePath,omitempty"`
+	Addr        uint64              `json:"addr"`
+	Length      int                 `json:"length,omitempty"`
+	ChanDir     string              `json:"chanDir,omitempty"`
+	Key         *int                `json:"key,omitempty"`
+	Element     *int                `json:"element,omitempty"`
+	Fields      []*ReportField      `json:"fields,omitempty"`
+	FuncArgs    []int               `json:"funcArgs,omitempty"`
+	FuncReturns []int               `json:"funcReturns,omitempty"`
+	IsVariadic  bool                `json:"isVariadic,omitempty"`
+	Methods     []*ReportTypeMethod `json:"methods,omitempty"`
+}
+
+// ReportField is a struct field of a ReportType.
+type ReportField struct {
+	Name      string `json:"name"`
+	Tag       string `json:"tag,omitempty"`
+	Anonymous bool   `json:"anonymous,omitempty"`
+	Type      int    `json:"type"`
+}
+
+// ReportTypeMethod is the serializable form of a TypeMethod.
+type ReportTypeMethod struct {
+	Name            string `json:"name"`
+	Type            *int   `json:"type,omitempty"`
+	IfaceCallOffset uint64 `json:"ifaceCallOffset,omitempty"`
+	FuncCallOffset  uint64 `json:"funcCallOffset,omitempty"`
+}
+
+// Export writes the full analysis report for the file as JSON to w.
+func (f *GoFile) Export(w io.Writer) error {
+	r, err := f.Report()
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(r)
+}
+
+// Report collects the full analysis model of the file. Only a failure to
+// recover the packages is returned as an error. Failures in the other parts
+// of the analysis are recorded in the report's Errors field.
+func (f *GoFile) Report() (*Report, error) {
+	r := &Report{
+		SchemaVersion: ExportSchemaVersion,
+		FileInfo:      newReportFileInfo(f.FileInfo),
+		BuildID:       f.BuildID,
+		Errors:        make(map[string]string),
+	}
+
+	if f.BuildInfo != nil && f.BuildInfo.ModInfo != nil {
+		r.BuildInfo = newReportBuildInfo(f.BuildInfo.ModInfo)
+	}
+
+	if err := f.initPackages(); err != nil {
+		return nil, fmt.Errorf("failed to recover the packages: %w", err)
+	}
+
+	classes := []struct {
+		class PackageClass
+		pkgs  []*Package
+	}{
+		{ClassMain, f.pkgs},
+		{ClassVendor, f.vendors},
+		{ClassSTD, f.stdPkgs},
+		{ClassGenerated, f.generated},
+		{ClassUnknown, f.unknown},
+	}
+	for _, c := range classes {
+		for _, p := range c.pkgs {
+			r.Packages = append(r.Packages, f.newReportPackage(p, c.class))
+		}
+	}
+	sort.Slice(r.Packages, func(i, j int) bool {
+		return r.Packages[i].Name < r.Packages[j].Name
+	})
+
+	if goroot, err := f.GetGoRoot(); err != nil {
+		r.Errors["goroot"] = err.Error()
+	} else {
+		r.GoRoot = goroot
+	}
+
+	if t := f.Toolchain(); t.Variant() {
+		r.Toolchain = t
+	}
+
+	if i, err := f.Instrumentation(); err != nil {
+		r.Errors["instrumentation"] = err.Error()
+	} else if len(i.Names()) != 0 {
+		r.Instrumentation = i
+	}
+
+	// Types can only be parsed if the compiler version is known.
+	ver, err := f.GetCompilerVersion()
+	if err != nil {
+		r.Errors["compiler"] = err.Error()
+	} else {
+		r.Compiler = ver
+		types, err := f.GetTypes()
+		if err != nil {
+			r.Errors["types"] = err.Error()
+		} else {
+			r.Types = newReportTypes(types)
+		}
+	}
+
+	if len(r.Errors) == 0 {
+		r.Errors = nil
+	}
+
+	return r, nil
+}
+
+func newReportFileInfo(fi *FileInfo) *ReportFileInfo {
+	rfi := &ReportFileInfo{
+		Arch:     fi.Arch,
+		OS:       fi.OS,
+		WordSize: fi.WordSize,
+	}
+	switch fi.ByteOrder {
+	case binary.LittleEndian:
+		rfi.ByteOrder = "little"
+	case binary.BigEndian:
+		rfi.ByteOrder = "big"
+	}
+	return rfi
+}
+
+func newReportBuildInfo(bi *debug.BuildInfo) *ReportBuildInfo {
+	rbi := &ReportBuildInfo{
+		GoVersion: bi.GoVersion,
+		Path:      bi.Path,
+		Main:      newReportModule(&bi.Main),
+	}
+	for _, dep := range bi.Deps {
+		rbi.Deps = append(rbi.Deps, newReportModule(dep))
+	}
+	for _, s := range bi.Settings {
+		rbi.Settings = append(rbi.Settings, &ReportBuildSetting{Key: s.Key, Value: s.Value})
+	}
+	return rbi
+}
+
+func newReportModule(m *debug.Module) *ReportModule {
+	if m == nil || m.Path == "" {
+		return nil
+	}
+	return &ReportModule{
+		Path:    m.Path,
+		Version: m.Version,
+		Sum:     m.Sum,
+		Replace: newReportModule(m.Replace),
+	}
+}
+
+func (f *GoFile) newReportPackage(p *Package, class PackageClass) *ReportPackage {
+	rp := &ReportPackage{
+		Name:      p.Name,
+		Path:      p.Filepath,
+		Class:     class.String(),
+		Functions: make([]*ReportFunction, 0, len(p.Functions)),
+		Methods:   make([]*ReportFunction, 0, len(p.Methods)),
+	}
+	for _, fn := range p.Functions {
+		rp.Functions = append(rp.Functions, &ReportFunction{
+			Name:   fn.Name,
+			Offset: fn.Offset,
+			End:    fn.End,
+		})
+	}
+	for _, m := range p.Methods {
+		rp.Methods = append(rp.Methods, &ReportFunction{
+			Name:     m.Name,
+			Receiver: m.Receiver,
+			Offset:   m.Offset,
+			End:      m.End,
+		})
+	}
+	for _, sf := range f.GetSourceFiles(p) {
+		rp.SourceFiles = append(rp.SourceFiles, &ReportSourceFile{Name: sf.Name, Entries: sf.Entries()})
+	}
+	return rp
+}
+
+// newReportTypes converts the types to their serializable form. Types that
+// are referenced but not part of the given list, are appended to the end of
+// the returned list.
+func newReportTypes(types []*GoType) []*ReportType {
+	ids := make(map[uint64]int, len(types))
+	queue := make([]*GoType, 0, len(types))
+
+	// ref returns the ID for the type, assigning a new one if the type
+	// hasn't been seen before. Struct fields are copies of the field's type
+	// so the type's address is used as the identity.
+	ref := func(t *GoType) int {
+		if id, ok := ids[t.Addr]; ok {
+			return id
+		}
+		id := len(queue)
+		ids[t.Addr] = id
+		queue = append(queue, t)
+		return id
+	}
+	optRef := func(t *GoType) *int {
+		if t == nil {
+			return nil
+		}
+		id := ref(t)
+		return &id
+	}
+
+	for _, t := range types {
+		ref(t)
+	}
+
+	result := make([]*ReportType, 0, len(queue))
+	for i := 0; i < len(queue); i++ {
+		t := queue[i]
+		rt := &ReportType{
+			ID:          i,
+			Kind:        t.Kind.String(),
+			Name:        t.Name,
+			PackagePath: t.PackagePath,
+			Addr:        t.Addr,
+			Length:      t.Length,
+			ChanDir:     t.ChanDir.String(),
+			Key:         optRef(t.Key),
+			Element:     optRef(t.Element),
+			IsVariadic:  t.IsVariadic,
+		}
+		for _, fld := range t.Fields {
+			if fld == nil {
+				continue
+			}
+			rt.Fields = append(rt.Fields, &ReportField{
+				Name:      fld.FieldName,
+				Tag:       fld.FieldTag,
+				Anonymous: fld.FieldAnon,
+				Type:      ref(fld),
+			})
+		}
+		for _, a := range t.FuncArgs {
+			if a != nil {
+				rt.FuncArgs = append(rt.FuncArgs, ref(a))
+			}
+		}
+		for _, ret := range t.FuncReturnVals {
+			if ret != nil {
+				rt.FuncReturns = append(rt.FuncReturns, ref(ret))
+			}
+		}
+		for _, m := range t.Methods {
+			if m == nil {
+				continue
+			}
+			rt.Methods = append(rt.Methods, &ReportTypeMethod{
+				Name:            m.Name,
+				Type:            optRef(m.Type),
+				IfaceCallOffset: m.IfaceCallOffset,
+				FuncCallOffset:  m.FuncCallOffset,
+			})
+		}
+		result = append(result, rt)
+	}
+	return result
+}
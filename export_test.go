@@ -0,0 +1,104 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewReportTypesHandlesCycles(t *testing.T) {
+	assert := assert.New(t)
+
+	// type node struct { next *node; val int }
+	intType := &GoType{Kind: reflect.Int, Addr: 0x10}
+	node := &GoType{Kind: reflect.Struct, Name: "main.node", PackagePath: "main", Addr: 0x20}
+	ptr := &GoType{Kind: reflect.Ptr, Addr: 0x30, Element: node}
+	next := *ptr
+	next.FieldName = "next"
+	val := *intType
+	val.FieldName = "val"
+	val.FieldTag = `json:"val"`
+	node.Fields = []*GoType{&next, &val}
+
+	types := newReportTypes([]*GoType{node})
+
+	// The pointer and int types are not in the list passed in so they
+	// should have been appended.
+	assert.Len(types, 3)
+	assert.Equal(0, types[0].ID)
+	assert.Equal("main.node", types[0].Name)
+	assert.Equal("struct", types[0].Kind)
+	assert.Len(types[0].Fields, 2)
+
+	ptrID := types[0].Fields[0].Type
+	assert.Equal("next", types[0].Fields[0].Name)
+	assert.Equal("ptr", types[ptrID].Kind)
+	assert.Equal(0, *types[ptrID].Element, "Pointer should refer back to the struct")
+
+	intID := types[0].Fields[1].Type
+	assert.Equal(`json:"val"`, types[0].Fields[1].Tag)
+	assert.Equal("int", types[intID].Kind)
+
+	_, err := json.Marshal(types)
+	assert.NoError(err, "Cyclic types should be serializable")
+}
+
+func TestExport(t *testing.T) {
+	r := require.New(t)
+
+	exe := buildTestBinary(t, testresourcesrc)
+	f, err := Open(exe)
+	r.NoError(err)
+	defer f.Close()
+
+	buf := &bytes.Buffer{}
+	r.NoError(f.Export(buf))
+
+	var report Report
+	r.NoError(json.Unmarshal(buf.Bytes(), &report))
+
+	r.Equal(ExportSchemaVersion, report.SchemaVersion)
+	r.Equal(ArchAMD64, report.FileInfo.Arch)
+	r.Equal("little", report.FileInfo.ByteOrder)
+	r.NotNil(report.BuildInfo)
+	r.Equal("github.com/goretk/gore/testbin", report.BuildInfo.Path)
+
+	var mainPkg *ReportPackage
+	for _, p := range report.Packages {
+		if p.Name == "main" {
+			mainPkg = p
+		}
+	}
+	r.NotNil(mainPkg, "main package should be exported")
+	r.Equal("main", mainPkg.Class)
+	r.NotEmpty(mainPkg.SourceFiles)
+	r.Equal("main.go", mainPkg.SourceFiles[0].Name)
+
+	var names []string
+	for _, fn := range mainPkg.Functions {
+		names = append(names, fn.Name)
+	}
+	r.Contains(names, "main")
+	r.Contains(names, "getData")
+}
@@ -27,6 +27,7 @@ import (
 	"os"
 	"path"
 	"sort"
+	"strings"
 	"sync"
 
 	"github.com/ZxillyFork/gosym"
@@ -87,6 +88,12 @@ func OpenReader(f io.ReaderAt) (*GoFile, error) {
 			return nil, err
 		}
 		gofile.fh = machO
+	} else if fileMagicMatch(buf, wasmMagic) {
+		wasm, err := openWasm(f)
+		if err != nil {
+			return nil, err
+		}
+		gofile.fh = wasm
 	} else {
 		return nil, ErrUnsupportedFile
 	}
@@ -108,7 +115,9 @@ func OpenReader(f io.ReaderAt) (*GoFile, error) {
 		if bi.Compiler != nil {
 			gofile.FileInfo.goversion = bi.Compiler
 		}
+		gofile.FileInfo.experiments = goExperiments(bi.ModInfo)
 	}
+	gofile.FileInfo.Compiler = gofile.compiler()
 
 	return gofile, nil
 }
@@ -133,21 +142,59 @@ type GoFile struct {
 
 	pclntab *gosym.Table
 
+	dwarfData  *dwarf.Data
+	dwarfOnce  sync.Once
+	dwarfError error
+
+	// nativeFuncs holds the functions of a binary without a PCLN table by
+	// their address.
+	nativeFuncs map[uint64]nativeFunc
+
 	initPackagesOnce  sync.Once
 	initPackagesError error
 
 	runtimeText  uint64
 	pclntabAddr  uint64
 	pclntabBytes []byte
-	pclntabOnce  sync.Once
-	pclntabError error
+	// pclntabRecovered is true if the PCLN table header was corrupted and
+	// the table was located by its structure.
+	pclntabRecovered bool
+	pclntabOnce      sync.Once
+	pclntabError     error
 
 	moduledata moduledata
 
 	versionError error
 
+	deobfuscation *Deobfuscation
+
 	initModuleDataOnce  sync.Once
 	initModuleDataError error
+
+	warningsMu sync.Mutex
+	warnings   []string
+
+	// scannedInstrumentation holds the instrumentation found in the
+	// packages and the symbols, see Instrumentation.
+	scannedInstrumentation   *Instrumentation
+	scanInstrumentationOnce  sync.Once
+	scanInstrumentationError error
+}
+
+// Warnings returns the problems that were worked around while the file was
+// parsed. For example, if the binary was compiled by a Go release newer than
+// the ones known to the library, the layout that was used instead is
+// reported.
+func (f *GoFile) Warnings() []string {
+	f.warningsMu.Lock()
+	defer f.warningsMu.Unlock()
+	return append([]string(nil), f.warnings...)
+}
+
+func (f *GoFile) warn(format string, args ...any) {
+	f.warningsMu.Lock()
+	defer f.warningsMu.Unlock()
+	f.warnings = append(f.warnings, fmt.Sprintf(format, args...))
 }
 
 func (f *GoFile) GetPCLNTableAddr() uint64 {
@@ -156,11 +203,8 @@ func (f *GoFile) GetPCLNTableAddr() uint64 {
 
 func (f *GoFile) initModuleData() error {
 	f.initModuleDataOnce.Do(func() {
-		err := f.ensureCompilerVersion()
-		if err != nil {
-			f.initModuleDataError = err
-			return
-		}
+		// If the version can't be determined, the layout is probed.
+		_ = f.ensureCompilerVersion()
 		f.moduledata, f.initModuleDataError = extractModuledata(f)
 	})
 	return f.initModuleDataError
@@ -177,6 +221,14 @@ func (f *GoFile) Moduledata() (Moduledata, error) {
 
 func (f *GoFile) initPackages() error {
 	f.initPackagesOnce.Do(func() {
+		switch f.FileInfo.Compiler {
+		case CompilerGccgo:
+			f.initPackagesError = f.enumNativePackages(gccgoNaming)
+			return
+		case CompilerTinyGo:
+			f.initPackagesError = f.enumNativePackages(tinygoNaming)
+			return
+		}
 		tab, err := f.PCLNTab()
 		if err != nil {
 			f.initPackagesError = err
@@ -199,13 +251,15 @@ func (f *GoFile) GetReader() io.ReaderAt {
 //   - *pe.File
 //   - *github.com/blacktop/go-macho.File
 //
-// all from the debug package.
+// all from the debug package. WebAssembly modules are parsed by the library
+// and nil is returned for them.
 func (f *GoFile) GetParsedFile() any {
 	return f.fh.getParsedFile()
 }
 
 // GetCompilerVersion returns the Go compiler version of the compiler
-// that was used to compile the binary.
+// that was used to compile the binary. If the version can't be found,
+// EstimateGoVersion can be used to get a range of possible versions.
 func (f *GoFile) GetCompilerVersion() (*GoVersion, error) {
 	err := f.ensureCompilerVersion()
 	if err != nil {
@@ -238,6 +292,10 @@ func (f *GoFile) tryExtractCompilerVersion() {
 // SourceInfo returns the source code filename, starting line number
 // and ending line number for the function.
 func (f *GoFile) SourceInfo(fn *Function) (string, int, int) {
+	if f.nativeFuncs != nil {
+		info := f.nativeFuncs[fn.Offset]
+		return info.file, info.line, info.endLine
+	}
 	srcFile, _, _ := f.pclntab.PCToLine(fn.Offset)
 	start, end := findSourceLines(fn.Offset, fn.End, f.pclntab)
 	return srcFile, start, end
@@ -254,11 +312,14 @@ func (f *GoFile) GetGoRoot() (string, error) {
 
 // SetGoVersion sets the assumed compiler version that was used. This
 // can be used to force a version if gore is not able to determine the
-// compiler version used. The version string must match one of the strings
-// normally extracted from the binary. For example, to set the version to
-// go 1.12.0, use "go1.12". For 1.7.2, use "go1.7.2".
-// If an incorrect version string or version not known to the library,
-// ErrInvalidGoVersion is returned.
+// compiler version used. The version string must be formatted like the
+// strings normally extracted from the binary. For example, to set the
+// version to go 1.12.0, use "go1.12". For 1.7.2, use "go1.7.2". Versions not
+// known to the library, like newer releases, are accepted. The structure
+// layouts are selected by the minor version, and versions newer than the
+// known layouts use the nearest ones.
+// If the version string is not a valid Go version, ErrInvalidGoVersion is
+// returned.
 func (f *GoFile) SetGoVersion(version string) error {
 	gv := ResolveGoVersion(version)
 	if gv == nil {
@@ -324,6 +385,7 @@ func (f *GoFile) enumPackages() error {
 					Offset:      n.Entry,
 					End:         n.End,
 					PackageName: n.PackageName(),
+					Instance:    parseInstantiation(n.Name),
 					Func:        &n,
 				},
 				Receiver: n.ReceiverName(),
@@ -336,6 +398,7 @@ func (f *GoFile) enumPackages() error {
 				Offset:      n.Entry,
 				End:         n.End,
 				PackageName: n.PackageName(),
+				Instance:    parseInstantiation(n.Name),
 				Func:        &n,
 			}
 			p.Functions = append(p.Functions, f)
@@ -357,6 +420,11 @@ func (f *GoFile) enumPackages() error {
 
 	allPackages.Sort()
 
+	return f.classifyPackages(packages)
+}
+
+// classifyPackages sorts the packages by their class.
+func (f *GoFile) classifyPackages(packages map[string]*Package) error {
 	var classifier PackageClassifier
 
 	if f.BuildInfo != nil && f.BuildInfo.ModInfo != nil {
@@ -400,12 +468,12 @@ func (f *GoFile) GetSymbol(name string) (Symbol, error) {
 }
 
 func (f *GoFile) getPCLNTABDataBySymbol() (uint64, []byte, error) {
-	sym, err := f.fh.getSymbol("runtime.pclntab")
+	sym, err := f.getLinkerSymbol("pclntab")
 	if err != nil {
 		return 0, nil, err
 	}
 	start := sym.Value
-	sym, err = f.fh.getSymbol("runtime.epclntab")
+	sym, err = f.getLinkerSymbol("epclntab")
 	if err != nil {
 		return 0, nil, err
 	}
@@ -425,11 +493,24 @@ func (f *GoFile) initPclntab() error {
 		addr, data, err := f.getPCLNTABDataBySymbol()
 		if err != nil {
 			addr, data, err = f.fh.getPCLNTABData()
-			if err != nil {
-				f.pclntabError = fmt.Errorf("error when getting pclntab: %w", err)
-				return
+		}
+		if err == nil && !knownPCLNTabMagic(data, f.FileInfo.ByteOrder) {
+			if tab, ok := newerPCLNTab(data, f.FileInfo); ok {
+				f.warn("unknown PCLN table magic %#x, the table is parsed with the Go 1.20 layout", f.FileInfo.ByteOrder.Uint32(data))
+				data = tab
 			}
 		}
+		if (err != nil || !knownPCLNTabMagic(data, f.FileInfo.ByteOrder)) && f.hasGoEvidence() {
+			// The header may have been corrupted to hide the table.
+			if raddr, rdata, rerr := f.recoverPCLNTab(); rerr == nil {
+				addr, data, err = raddr, rdata, nil
+				f.pclntabRecovered = true
+			}
+		}
+		if err != nil {
+			f.pclntabError = fmt.Errorf("error when getting pclntab: %w", err)
+			return
+		}
 		f.pclntabAddr = addr
 		f.pclntabBytes = data
 
@@ -439,7 +520,7 @@ func (f *GoFile) initPclntab() error {
 		// external linkers may add additional code to the section before the "Go" code. We can find "runtime.text"
 		// in the moduledata structure in the binary.
 		// If we have the symbol table, just get it
-		sym, err := f.fh.getSymbol("runtime.text")
+		sym, err := f.getLinkerSymbol("text")
 		if err == nil {
 			f.runtimeText = sym.Value
 			return
@@ -463,6 +544,13 @@ func (f *GoFile) initPclntab() error {
 
 		// Since the moduledata starts with the address to the pclntab, we can use this to find the moduledata structure.
 		runtimeText, err := f.findRuntimeText(textStart, textStart+uint64(len(textData)), f.pclntabAddr, moddataSection)
+		if err != nil && f.FileInfo.ByteOrder.Uint32(data) == gopclntab12magic {
+			// The Go 1.2 table stores the function addresses, so the start of
+			// the text is not needed. Binaries compiled before Go 1.5 don't
+			// have a moduledata structure to find it with.
+			f.runtimeText = textStart
+			return
+		}
 		if err != nil {
 			if f.FileInfo.OS == "macOS" && f.FileInfo.Arch == ArchARM64 {
 				t, err := f.findRuntimeTextMachoChainedFixups(f.pclntabAddr)
@@ -482,6 +570,55 @@ func (f *GoFile) initPclntab() error {
 	return f.pclntabError
 }
 
+// hasGoEvidence returns true if something other than the PCLN table shows
+// that the binary was compiled by Go: a Go build ID, the build information or
+// symbols of the runtime. The scan for a corrupted table is only done for
+// these binaries, so it isn't run for every file that is not a Go binary.
+func (f *GoFile) hasGoEvidence() bool {
+	if f.BuildID != "" || f.BuildInfo != nil {
+		return true
+	}
+	syms, err := f.symbols()
+	if err != nil {
+		return false
+	}
+	for name := range syms {
+		if strings.HasPrefix(name, "runtime.") {
+			return true
+		}
+	}
+	return false
+}
+
+// recoverPCLNTab searches the sections where the PCLN table is stored for a
+// table with a corrupted header. See recoverPCLNTab in pclntab.go.
+func (f *GoFile) recoverPCLNTab() (uint64, []byte, error) {
+	var sections []string
+	switch f.fh.(type) {
+	case *elfFile:
+		sections = []string{".gopclntab", ".data.rel.ro.gopclntab", ".data.rel.ro", ".rodata"}
+	case *peFile:
+		sections = []string{".rdata", ".text"}
+	case *machoFile:
+		sections = []string{"__gopclntab", "__rodata"}
+	}
+	var version string
+	if f.BuildInfo != nil && f.BuildInfo.ModInfo != nil {
+		version = f.BuildInfo.ModInfo.GoVersion
+	}
+	for _, name := range sections {
+		base, data, err := f.fh.getSectionData(name)
+		if err != nil {
+			continue
+		}
+		off, tab, err := recoverPCLNTab(data, f.FileInfo, version)
+		if err == nil {
+			return base + uint64(off), tab, nil
+		}
+	}
+	return 0, nil, ErrNoPCLNTab
+}
+
 // PCLNTab returns the PCLN table.
 func (f *GoFile) PCLNTab() (*gosym.Table, error) {
 	err := f.initPclntab()
@@ -565,20 +702,46 @@ func (f *GoFile) findRuntimeText(textStart, textEnd, pclntabAddr uint64, modSect
 
 // GetTypes returns a map of all types found in the binary file.
 func (f *GoFile) GetTypes() ([]*GoType, error) {
-	err := f.initModuleData()
-	if err != nil {
-		return nil, err
-	}
-	md := f.moduledata
+	var t map[uint64]*GoType
+	if f.FileInfo.Compiler == CompilerGccgo {
+		var err error
+		t, err = f.getGccgoTypes()
+		if err != nil {
+			return nil, err
+		}
+	} else if f.FileInfo.Compiler == CompilerTinyGo {
+		var err error
+		t, err = f.getTinyGoTypes()
+		if err != nil {
+			return nil, err
+		}
+	} else if _ = f.ensureCompilerVersion(); !hasModuledata(f.FileInfo) {
+		var err error
+		t, err = f.getTypesGo12()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		err := f.initModuleData()
+		if err != nil {
+			return nil, err
+		}
+		md := f.moduledata
 
-	t, err := getTypes(f.FileInfo, f.fh, md)
-	if err != nil {
-		return nil, err
+		t, err = getTypes(f.FileInfo, f.fh, md)
+		if err != nil {
+			return nil, err
+		}
 	}
-	if err = f.initPackages(); err != nil {
+	if err := f.initPackages(); err != nil {
 		return nil, err
 	}
-	return sortTypes(t), nil
+	types := sortTypes(t)
+	if f.deobfuscation != nil {
+		f.deobfuscation.renameTypes(types)
+	}
+	setTypeInstances(types)
+	return types, nil
 }
 
 // Bytes return a slice of raw bytes with the length in the file from the address.
@@ -642,14 +805,28 @@ type FileInfo struct {
 	// ByteOrder is the byte order.
 	ByteOrder binary.ByteOrder
 	// WordSize is the natural integer size used by the file.
-	WordSize  int
+	WordSize int
+	// Compiler is the toolchain that compiled the binary, CompilerGc,
+	// CompilerGccgo or CompilerTinyGo.
+	Compiler  string
 	goversion *GoVersion
+	// experiments holds the GOEXPERIMENT settings that differ from the
+	// defaults of the release, if they are known.
+	experiments []string
 }
 
+// The compilers, named as runtime.Compiler.
+const (
+	CompilerGc     = "gc"
+	CompilerGccgo  = "gccgo"
+	CompilerTinyGo = "tinygo"
+)
+
 const (
 	ArchAMD64 = "amd64"
 	ArchARM   = "arm"
 	ArchARM64 = "arm64"
 	Arch386   = "i386"
 	ArchMIPS  = "mips"
+	ArchWasm  = "wasm"
 )
@@ -216,6 +216,16 @@ func TestSetGoVersion(t *testing.T) {
 		assert.Nil(err, "Should not return an error when the version string is correct format")
 		assert.Equal(expected, f.FileInfo.goversion, "Incorrect go version has be set")
 	})
+
+	t.Run("should accept an unknown release", func(t *testing.T) {
+		f := new(GoFile)
+		f.FileInfo = new(FileInfo)
+
+		err := f.SetGoVersion("go1.99.1")
+
+		assert.NoError(err)
+		assert.Equal("go1.99.1", f.FileInfo.goversion.Name)
+	})
 }
 
 type mockFileHandler struct {
@@ -296,6 +306,53 @@ func TestBytes(t *testing.T) {
 	assert.Equal(expectedBytes, data, "Return data not as expected")
 }
 
+func TestOpenCompilerWithoutDwarf(t *testing.T) {
+	exe := buildTestBinary(t, "package main\n\nfunc main() { println(\"hello\") }\n")
+	f, err := Open(exe)
+	require.NoError(t, err)
+	defer f.Close()
+
+	// A gc binary is recognized without parsing its DWARF data.
+	assert.Equal(t, CompilerGc, f.FileInfo.Compiler)
+	assert.Nil(t, f.dwarfData)
+	assert.NoError(t, f.dwarfError)
+
+	// Without the build information, the PCLN table is enough.
+	f.BuildInfo = nil
+	assert.Equal(t, CompilerGc, f.compiler())
+	assert.Nil(t, f.dwarfData)
+}
+
+// buildTestBinary compiles the source code with the Go toolchain on the host
+// and returns the path to the executable. The extra arguments are passed to
+// "go build".
+func buildTestBinary(t *testing.T, src string, args ...string) string {
+	t.Helper()
+	return buildTestBinaryFor(t, "linux", "amd64", src, args...)
+}
+
+// buildTestBinaryFor is buildTestBinary for another GOOS and GOARCH.
+func buildTestBinaryFor(t *testing.T, goos, goarch, src string, args ...string) string {
+	t.Helper()
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("No go tool chain found: " + err.Error())
+	}
+	tmpdir := t.TempDir()
+	err = os.WriteFile(filepath.Join(tmpdir, "main.go"), []byte(src), 0644)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(tmpdir, "go.mod"), []byte("module github.com/goretk/gore/testbin\n\ngo 1.22\n"), 0644)
+	require.NoError(t, err)
+
+	exe := filepath.Join(tmpdir, "testbin")
+	cmd := exec.Command(goBin, append(append([]string{"build", "-o", exe}, args...), ".")...)
+	cmd.Dir = tmpdir
+	cmd.Env = append(os.Environ(), "GOOS="+goos, "GOARCH="+goarch, "CGO_ENABLED=0", "GOFLAGS=-mod=mod")
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "building test executable failed: "+string(out))
+	return exe
+}
+
 func getTestResourcePath(resource string) (string, error) {
 	return filepath.Abs(filepath.Join(resourceFolder, resource))
 }
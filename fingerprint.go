@@ -0,0 +1,213 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+
+	"golang.org/x/arch/x86/x86asm"
+)
+
+// FunctionHash returns a hex encoded SHA-256 hash of the function's code.
+// Operands that encode the location of other code or data, for example the
+// target of a call or a RIP-relative load, are masked out before the code is
+// hashed. The hash of a function is therefore the same in two builds as long
+// as its code is the same, even if the function or anything it references has
+// been moved.
+//
+// Masking is supported for x86, ARM and ARM64. For other architectures the
+// raw code is hashed.
+func (f *GoFile) FunctionHash(fn *Function) (string, error) {
+	code, err := f.Bytes(fn.Offset, fn.End-fn.Offset)
+	if err != nil {
+		return "", err
+	}
+	return hashCode(normalizeCode(code, f.FileInfo)), nil
+}
+
+// hashCode returns the hex encoded SHA-256 hash of normalized code.
+func hashCode(norm []byte) string {
+	sum := sha256.Sum256(norm)
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeCode returns a copy of the code where position dependent operands
+// have been replaced with zeros and the alignment padding at the end of the
+// function has been removed.
+func normalizeCode(code []byte, fi *FileInfo) []byte {
+	buf := make([]byte, len(code))
+	copy(buf, code)
+
+	switch fi.Arch {
+	case ArchAMD64:
+		buf = trimINT3(buf)
+		maskX86(buf, 64)
+	case Arch386:
+		buf = trimINT3(buf)
+		maskX86(buf, 32)
+	case ArchARM64:
+		buf = trimZeroWords(buf)
+		maskARM64(buf, fi.ByteOrder)
+	case ArchARM:
+		buf = trimZeroWords(buf)
+		maskARM(buf, fi.ByteOrder)
+	}
+	return buf
+}
+
+// trimINT3 removes the INT3 instructions used to pad x86 functions.
+func trimINT3(code []byte) []byte {
+	for len(code) > 0 && code[len(code)-1] == 0xcc {
+		code = code[:len(code)-1]
+	}
+	return code
+}
+
+// trimZeroWords removes trailing zero padding for architectures with fixed
+// size instructions.
+func trimZeroWords(code []byte) []byte {
+	for len(code) >= 4 && binary.LittleEndian.Uint32(code[len(code)-4:]) == 0 {
+		code = code[:len(code)-4]
+	}
+	return code
+}
+
+func maskX86(code []byte, mode int) {
+	for i := 0; i < len(code); {
+		inst, err := x86asm.Decode(code[i:], mode)
+		if err != nil || inst.Len == 0 {
+			if n := maskEVEX(code[i:], mode); n != 0 {
+				i += n
+				continue
+			}
+			// Not valid code, probably data embedded in the function.
+			// Move on to the next byte.
+			i++
+			continue
+		}
+		ins := code[i : i+inst.Len]
+
+		// Relative branches, calls and RIP-relative memory operands.
+		if inst.PCRel != 0 {
+			clear(ins[inst.PCRelOff : inst.PCRelOff+inst.PCRel])
+		}
+
+		// In 32-bit mode, global data is accessed with absolute addresses.
+		if mode == 32 {
+			for _, arg := range inst.Args {
+				mem, ok := arg.(x86asm.Mem)
+				if !ok || mem.Base != 0 || mem.Index != 0 || mem.Segment != 0 || mem.Disp == 0 {
+					continue
+				}
+				disp := binary.LittleEndian.AppendUint32(nil, uint32(mem.Disp))
+				if off := bytes.Index(ins[1:], disp); off != -1 {
+					clear(ins[off+1 : off+1+len(disp)])
+				}
+			}
+		}
+
+		i += inst.Len
+	}
+}
+
+// maskEVEX masks the RIP-relative operand of an AVX-512 instruction, which
+// the disassembler doesn't support. The length of the instruction is returned
+// or 0 if the code is not an EVEX encoded instruction.
+func maskEVEX(code []byte, mode int) int {
+	if mode != 64 || len(code) < 6 || code[0] != 0x62 {
+		return 0
+	}
+	opcodeMap := code[1] & 0x7
+	if opcodeMap < 1 || opcodeMap > 3 {
+		return 0
+	}
+	op, modrm := code[4], code[5]
+	n := 6
+	mod, rm := modrm>>6, modrm&0x7
+	ripRel := mod == 0 && rm == 5
+	if mod != 3 && rm == 4 {
+		// SIB byte
+		if len(code) < n+1 {
+			return 0
+		}
+		if mod == 0 && code[n]&0x7 == 5 {
+			mod = 2
+		}
+		n++
+	}
+	dispOff := n
+	switch {
+	case ripRel || mod == 2:
+		n += 4
+	case mod == 1:
+		n++
+	}
+	if opcodeMap == 3 || (opcodeMap == 1 && (op >= 0x70 && op <= 0x73 || op == 0xc2 || op >= 0xc4 && op <= 0xc6)) {
+		// imm8
+		n++
+	}
+	if len(code) < n {
+		return 0
+	}
+	if ripRel {
+		clear(code[dispOff : dispOff+4])
+	}
+	return n
+}
+
+func maskARM64(code []byte, order binary.ByteOrder) {
+	var afterADRP bool
+	for i := 0; i+4 <= len(code); i += 4 {
+		insn := order.Uint32(code[i:])
+		wasADRP := afterADRP
+		afterADRP = false
+
+		switch {
+		// B and BL
+		case insn&0x7c000000 == 0x14000000:
+			insn &^= 0x03ffffff
+		// ADR and ADRP
+		case insn&0x1f000000 == 0x10000000:
+			insn &^= 0x60ffffe0
+			afterADRP = insn&0x80000000 != 0
+		// LDR (literal)
+		case insn&0x3b000000 == 0x18000000:
+			insn &^= 0x00ffffe0
+		// The low 12 bits of the address loaded by ADRP are added by the
+		// next instruction, either an ADD or a load/store.
+		case wasADRP && insn&0x7f800000 == 0x11000000,
+			wasADRP && insn&0x3b000000 == 0x39000000:
+			insn &^= 0x003ffc00
+		}
+		order.PutUint32(code[i:], insn)
+	}
+}
+
+func maskARM(code []byte, order binary.ByteOrder) {
+	for i := 0; i+4 <= len(code); i += 4 {
+		insn := order.Uint32(code[i:])
+		// B and BL
+		if insn&0x0e000000 == 0x0a000000 {
+			insn &^= 0x00ffffff
+		}
+		order.PutUint32(code[i:], insn)
+	}
+}
@@ -35,8 +35,17 @@ type Function struct {
 	End uint64 `json:"end"`
 	// PackageName is the name of the Go package the function belongs to.
 	PackageName string `json:"packageName"`
-
-	Func *gosym.Func
+	// RecoveredName is the full name of the function recovered from a
+	// signature database, if it differs from the name in the binary.
+	RecoveredName string `json:"recoveredName,omitempty"`
+	// Instance is set if the function is an instantiation of a generic
+	// function or method.
+	Instance *Instantiation `json:"instance,omitempty"`
+	// Dictionaries holds the dictionaries that are passed to the function.
+	// It is set by GoFile.Dictionaries.
+	Dictionaries []*Dictionary `json:"dictionaries,omitempty"`
+
+	Func *gosym.Func `json:"-"`
 }
 
 // String returns a string representation of the function.
@@ -60,11 +69,11 @@ func (m *Method) String() string {
 // a function or a method.
 type FileEntry struct {
 	// Name of the function or method.
-	Name string
+	Name string `json:"name"`
 	// Start is the source line where the code starts.
-	Start int
+	Start int `json:"start"`
 	// End is the source line where the code ends.
-	End int
+	End int `json:"end"`
 }
 
 // String returns a string representation of the entry.
@@ -83,6 +92,17 @@ type SourceFile struct {
 	entries []FileEntry
 }
 
+// Entries returns the functions and methods in the file, sorted by the
+// source line where they start.
+func (s *SourceFile) Entries() []FileEntry {
+	entries := make([]FileEntry, len(s.entries))
+	copy(entries, s.entries)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Start < entries[j].Start
+	})
+	return entries
+}
+
 // String produces a string representation of a source code file.
 // The multi-line string has this format:
 //
@@ -0,0 +1,175 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Binaries compiled by gccgo don't have the PCLN table, moduledata or the
+// type structures of the gc toolchain. The functions are recovered from the
+// symbols and the DWARF data, and the types from the type descriptors of
+// libgo, which use the layout of the Go 1.6 runtime.
+
+// gccgoProducer is the prefix of the DWARF producer of gccgo.
+const gccgoProducer = "GNU Go"
+
+// gccgoTypeDescriptorPrefixes are the prefixes of the type descriptor
+// symbols. Older releases used __go_td_ and __go_tdn_ for named types.
+var gccgoTypeDescriptorPrefixes = []string{"__go_tdn_", "__go_td_", "type.."}
+
+var errNoTypeDescriptors = errors.New("no type descriptors found")
+
+var gccgoNaming = nativeNaming{
+	demangle:   demangleGccgo,
+	isFunction: isGccgoFunction,
+	split:      splitGccgoName,
+}
+
+// isGccgo returns true if the binary was compiled by gccgo. Either the
+// DWARF data is produced by gccgo or the binary has the symbols of the
+// libgo runtime.
+func (f *GoFile) isGccgo() bool {
+	if f.hasDwarfProducer(gccgoProducer) {
+		return true
+	}
+	syms, err := f.symbols()
+	if err != nil {
+		return false
+	}
+	for name := range syms {
+		if strings.HasPrefix(name, "__go_") {
+			return true
+		}
+	}
+	return false
+}
+
+// demangleGccgo decodes the characters that gccgo encodes in symbol names.
+// Since GCC 10, characters that are not allowed in a symbol, for example
+// the slash in a package path, are written as ..z followed by two hex
+// digits, or ..u and ..U followed by four or eight hex digits.
+func demangleGccgo(name string) string {
+	if !strings.Contains(name, "..") {
+		return name
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if strings.HasPrefix(name[i:], "..") && i+2 < len(name) {
+			digits := 0
+			switch name[i+2] {
+			case 'z':
+				digits = 2
+			case 'u':
+				digits = 4
+			case 'U':
+				digits = 8
+			}
+			if digits != 0 && i+3+digits <= len(name) {
+				if r, err := strconv.ParseUint(name[i+3:i+3+digits], 16, 32); err == nil && utf8.ValidRune(rune(r)) {
+					b.WriteRune(rune(r))
+					i += 2 + digits
+					continue
+				}
+			}
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+// splitGccgoName splits a demangled function name into the package, the
+// receiver type and the name. Methods are named by the receiver type
+// followed by the method, for example main.T.Method. Compiler generated
+// functions are appended with two dots, for example main.main..func1.
+func splitGccgoName(name string) (string, string, string) {
+	start := strings.LastIndex(name, "/") + 1
+	dot := strings.Index(name[start:], ".")
+	if dot < 0 {
+		return "", "", name
+	}
+	pkg, rest := name[:start+dot], name[start+dot+1:]
+
+	base := rest
+	if i := strings.Index(base, ".."); i >= 0 {
+		base = base[:i]
+	}
+	if i := strings.Index(base, "."); i > 0 {
+		return pkg, rest[:i], rest[i+1:]
+	}
+	return pkg, "", rest
+}
+
+// isGccgoFunction returns false for the symbols of the C parts of the
+// runtime and for the symbols generated by the compiler that are not
+// functions of a package.
+func isGccgoFunction(name string) bool {
+	if name == "" || name[0] == '_' || !strings.Contains(name, ".") {
+		return false
+	}
+	return !strings.HasPrefix(name, ".")
+}
+
+// getGccgoTypes parses the type descriptors of a gccgo binary. They are
+// found by their symbols, so no types are returned if the binary is
+// stripped.
+func (f *GoFile) getGccgoTypes() (map[uint64]*GoType, error) {
+	syms, err := f.symbols()
+	if err != nil {
+		return nil, err
+	}
+	textStart, text, err := f.fh.getCodeSection()
+	if err != nil {
+		return nil, err
+	}
+	textEnd := textStart + uint64(len(text))
+
+	types := make(map[uint64]*GoType)
+	kindOff := uint64(typeOffset(f.FileInfo, _typeFieldKind))
+	for name, sym := range syms {
+		if !isGccgoTypeDescriptor(name) || sym.Value >= textStart && sym.Value < textEnd {
+			continue
+		}
+		base, data, err := f.fh.getSectionDataFromAddress(sym.Value)
+		if err != nil || sym.Value-base+kindOff >= uint64(len(data)) {
+			continue
+		}
+		// Skip the symbols with the prefix that are not type descriptors.
+		if kind := data[sym.Value-base+kindOff] & kindMask; kind == 0 || kind > uint8(reflect.UnsafePointer) {
+			continue
+		}
+		typeParse(types, f.FileInfo, sym.Value-base, data, base)
+	}
+	if len(types) == 0 {
+		return nil, errNoTypeDescriptors
+	}
+	return types, nil
+}
+
+func isGccgoTypeDescriptor(name string) bool {
+	for _, prefix := range gccgoTypeDescriptorPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
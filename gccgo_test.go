@@ -0,0 +1,128 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDemangleGccgo(t *testing.T) {
+	for mangled, expected := range map[string]string{
+		"main.main":                        "main.main",
+		"github.com..z2fuser..z2fpkg.Func": "github.com/user/pkg.Func",
+		"main.main..func1":                 "main.main..func1",
+		"main.h..u00e9llo":                 "main.héllo",
+		"main.x..z":                        "main.x..z",
+	} {
+		assert.Equal(t, expected, demangleGccgo(mangled), mangled)
+	}
+}
+
+func TestSplitGccgoName(t *testing.T) {
+	for _, test := range []struct {
+		name, pkg, receiver, fn string
+	}{
+		{"main.main", "main", "", "main"},
+		{"main.T.Method", "main", "T", "Method"},
+		{"main.main..func1", "main", "", "main..func1"},
+		{"main.T.Method..func1", "main", "T", "Method..func1"},
+		{"main..import", "main", "", ".import"},
+		{"github.com/user/pkg.T.Method", "github.com/user/pkg", "T", "Method"},
+		{"noPackage", "", "", "noPackage"},
+	} {
+		pkg, receiver, fn := splitGccgoName(test.name)
+		assert.Equal(t, test.pkg, pkg, test.name)
+		assert.Equal(t, test.receiver, receiver, test.name)
+		assert.Equal(t, test.fn, fn, test.name)
+	}
+}
+
+func TestIsGccgoFunction(t *testing.T) {
+	assert.True(t, isGccgoFunction("main.main"))
+	assert.True(t, isGccgoFunction("runtime.main"))
+	assert.False(t, isGccgoFunction("__go_init_main"))
+	assert.False(t, isGccgoFunction("runtime_mstart"))
+	assert.False(t, isGccgoFunction(""))
+}
+
+func TestParseGccgoTypeDescriptor(t *testing.T) {
+	const base = 0x1000
+	data := make([]byte, 0x600)
+	put := func(off int, v uint64) { binary.LittleEndian.PutUint64(data[off:], v) }
+	str := func(hdr, off int, s string) {
+		put(hdr, uint64(base+off))
+		put(hdr+8, uint64(len(s)))
+		copy(data[off:], s)
+	}
+	str(0x400, 0x500, "int")
+	str(0x410, 0x510, "main.T")
+	str(0x420, 0x520, "A")
+	str(0x430, 0x530, "T")
+	str(0x440, 0x540, "Get")
+	str(0x450, 0x550, "Set")
+
+	// int
+	put(0x100, 8)
+	data[0x100+21], data[0x100+22], data[0x100+23] = 8, 8, uint8(reflect.Int)
+	put(0x100+40, base+0x400)
+
+	// main.T, a struct with one field and two methods.
+	put(0x000, 8)
+	data[21], data[22], data[23] = 8, 8, uint8(reflect.Struct)
+	put(40, base+0x410)
+	put(48, base+0x200)
+	put(64, base+0x180)
+	put(72, 1)
+	put(80, 1)
+
+	put(0x180, base+0x420)
+	put(0x180+16, base+0x100)
+
+	put(0x200, base+0x430)
+	put(0x200+16, base+0x300)
+	put(0x200+24, 2)
+	put(0x200+32, 2)
+
+	put(0x300, base+0x440)
+	put(0x300+32, 0x401000)
+	put(0x328, base+0x450)
+	put(0x328+32, 0x402000)
+
+	fi := &FileInfo{WordSize: intSize64, ByteOrder: binary.LittleEndian, Compiler: CompilerGccgo}
+	types := make(map[uint64]*GoType)
+	typ := typeParse(types, fi, 0, data, base)
+	require.NotNil(t, typ)
+
+	assert.Equal(t, "main.T", typ.Name)
+	assert.Equal(t, reflect.Struct, typ.Kind)
+	require.Len(t, typ.Fields, 1)
+	assert.Equal(t, "A", typ.Fields[0].FieldName)
+	assert.Equal(t, "int", typ.Fields[0].Name)
+
+	require.Len(t, typ.Methods, 2)
+	assert.Equal(t, "Get", typ.Methods[0].Name)
+	assert.Equal(t, uint64(0x401000), typ.Methods[0].FuncCallOffset)
+	assert.Equal(t, "Set", typ.Methods[1].Name)
+	assert.Equal(t, uint64(0x402000), typ.Methods[1].FuncCallOffset)
+	assert.Zero(t, typ.Methods[1].IfaceCallOffset)
+}
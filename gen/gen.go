@@ -15,8 +15,10 @@
 // You should have received a copy of the GNU Affero General Public License
 // along with this program. If not, see <http://www.gnu.org/licenses/>.
 
-// This program generates stdpkgs_gen.go, goversion_gen.go and moduledata_gen.go. It can be invoked by running
+// This program generates stdpkgs_gen.go, goversion_gen.go, moduledata_gen.go and typelayout_gen.go. It can be invoked by running
 // go generate
+//
+// It also generates the function signature databases, see "go run ./gen signatures -h".
 
 package main
 
@@ -26,19 +28,27 @@ import (
 )
 
 func main() {
-	if len(os.Args) != 2 {
-		fmt.Println("go run ./gen [stdpkgs|goversion|moduledata]")
+	if len(os.Args) < 2 {
+		fmt.Println("go run ./gen [stdpkgs|goversion|moduledata|types|signatures]")
 		return
 	}
 
 	switch os.Args[1] {
 	case "stdpkgs":
+		initRepo()
 		generateStdPkgs()
 	case "goversion":
+		initRepo()
 		generateGoVersions()
 	case "moduledata":
+		initRepo()
 		generateModuleData()
+	case "types":
+		initRepo()
+		generateTypeLayouts()
+	case "signatures":
+		generateSignatures(os.Args[2:])
 	default:
-		fmt.Println("go run ./gen [stdpkgs|goversion|moduledata]")
+		fmt.Println("go run ./gen [stdpkgs|goversion|moduledata|types|signatures]")
 	}
 }
@@ -216,6 +216,8 @@ func (g *moduleDataGenerator) writeSelector() {
 
 	g.writeln("}\n}\n")
 
+	g.writeln("// newestModuleDataVersion is the newest minor version with a known layout.")
+	g.writeln("const newestModuleDataVersion = %d", g.knownVersions[len(g.knownVersions)-1])
 }
 
 func (*moduleDataGenerator) generateTypeName(versionCode int, bits int) string {
@@ -92,7 +92,8 @@ func tryLoadFromEnv() error {
 	return errors.New("no env")
 }
 
-func init() {
+// initRepo opens the Go repository, cloning it if needed.
+func initRepo() {
 	err := tryLoadFromEnv()
 	if err == nil {
 		// if we have the repo, we don't need to do anything
@@ -0,0 +1,264 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ZxillyFork/gore"
+)
+
+// generateSignatures builds signature databases. For every toolchain and
+// architecture, a program that references all exported functions and
+// methods of the packages is compiled and its functions are hashed.
+//
+// Toolchains are given as GOTOOLCHAIN values, for example go1.22.1, which
+// the local go command downloads when needed, or as paths to go commands.
+func generateSignatures(args []string) {
+	fs := flag.NewFlagSet("signatures", flag.ExitOnError)
+	toolchains := fs.String("go", "local", "comma separated list of toolchains or paths to go commands")
+	arches := fs.String("arch", "amd64,386,arm64,arm", "comma separated list of GOARCH values")
+	out := fs.String("out", signatureOutputDir, "output directory")
+	fs.Usage = func() {
+		fmt.Println("go run ./gen signatures [-go toolchains] [-arch arches] [-out dir] [module packages...]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	extra := fs.Args()
+
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		fmt.Println("Error when creating the output directory:", err)
+		return
+	}
+
+	for _, tc := range strings.Split(*toolchains, ",") {
+		for _, arch := range strings.Split(*arches, ",") {
+			fmt.Printf("Generating signatures for %s %s\n", tc, arch)
+			if err := generateSignatureDB(tc, arch, extra, *out); err != nil {
+				fmt.Printf("Error when generating signatures for %s %s: %s\n", tc, arch, err)
+			}
+		}
+	}
+}
+
+func generateSignatureDB(toolchain, arch string, extra []string, out string) error {
+	dir, err := os.MkdirTemp("", "gore-signatures")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	goCmd := "go"
+	env := append(os.Environ(), "GOOS=linux", "GOARCH="+arch, "CGO_ENABLED=0", "GOFLAGS=-mod=mod")
+	if strings.ContainsRune(toolchain, filepath.Separator) {
+		goCmd = toolchain
+	} else {
+		env = append(env, "GOTOOLCHAIN="+toolchain)
+	}
+	run := func(args ...string) ([]byte, error) {
+		cmd := exec.Command(goCmd, args...)
+		cmd.Dir = dir
+		cmd.Env = env
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		o, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("go %s: %w: %s", strings.Join(args, " "), err, stderr.String())
+		}
+		return o, nil
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module gore/signatures\n\ngo 1.21\n"), 0644); err != nil {
+		return err
+	}
+	for _, p := range extra {
+		if _, err := run("get", p); err != nil {
+			return err
+		}
+	}
+
+	listed, err := run(append([]string{"list", "-json", "std"}, extra...)...)
+	if err != nil {
+		return err
+	}
+	pkgs, err := signaturePackages(listed)
+	if err != nil {
+		return err
+	}
+
+	src, err := signatureProgram(pkgs)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), src, 0644); err != nil {
+		return err
+	}
+	exe := filepath.Join(dir, "sigbin")
+	if _, err := run("build", "-trimpath", "-o", exe, "."); err != nil {
+		return err
+	}
+
+	f, err := gore.Open(exe)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	db, err := gore.NewSignatureDB(f, func(p *gore.Package) bool {
+		if gore.IsStandardLibrary(p.Name) {
+			return true
+		}
+		for _, e := range extra {
+			if p.Name == e || strings.HasPrefix(p.Name, e+"/") {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	version, err := run("env", "GOVERSION")
+	if err != nil {
+		return err
+	}
+	db.GoVersion = strings.TrimSpace(string(version))
+
+	name := fmt.Sprintf("%s-%s.json.gz", db.GoVersion, arch)
+	o, err := os.Create(filepath.Join(out, name))
+	if err != nil {
+		return err
+	}
+	defer o.Close()
+	if err := db.Write(o); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d signatures to %s\n", len(db.Signatures), name)
+	return nil
+}
+
+// listedPackage is the part of the "go list -json" output that is used.
+type listedPackage struct {
+	ImportPath string
+	Name       string
+	Dir        string
+	GoFiles    []string
+}
+
+// signaturePackages returns the packages that can be imported by a program.
+func signaturePackages(listed []byte) ([]*listedPackage, error) {
+	var pkgs []*listedPackage
+	dec := json.NewDecoder(bytes.NewReader(listed))
+	for {
+		p := &listedPackage{}
+		err := dec.Decode(p)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p.Name == "main" || p.ImportPath == "unsafe" || len(p.GoFiles) == 0 {
+			continue
+		}
+		elems := strings.Split(p.ImportPath, "/")
+		skip := false
+		for _, e := range elems {
+			if e == "internal" || e == "vendor" {
+				skip = true
+			}
+		}
+		if skip || elems[0] == "cmd" || p.ImportPath == "runtime/cgo" || p.ImportPath == "plugin" {
+			continue
+		}
+		pkgs = append(pkgs, p)
+	}
+	return pkgs, nil
+}
+
+// signatureProgram returns the source of a program that references every
+// exported, non-generic function and method of the packages.
+func signatureProgram(pkgs []*listedPackage) ([]byte, error) {
+	var imports, refs []string
+	fset := token.NewFileSet()
+	for i, p := range pkgs {
+		alias := fmt.Sprintf("p%d", i)
+		var pkgRefs []string
+		for _, name := range p.GoFiles {
+			file, err := parser.ParseFile(fset, filepath.Join(p.Dir, name), nil, parser.SkipObjectResolution)
+			if err != nil {
+				return nil, err
+			}
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || !fn.Name.IsExported() || fn.Type.TypeParams != nil {
+					continue
+				}
+				if fn.Recv == nil {
+					pkgRefs = append(pkgRefs, alias+"."+fn.Name.Name)
+					continue
+				}
+				recv := fn.Recv.List[0].Type
+				ptr := false
+				if star, ok := recv.(*ast.StarExpr); ok {
+					recv, ptr = star.X, true
+				}
+				ident, ok := recv.(*ast.Ident)
+				if !ok || !ident.IsExported() {
+					// Unexported or generic receiver.
+					continue
+				}
+				if ptr {
+					pkgRefs = append(pkgRefs, fmt.Sprintf("(*%s.%s).%s", alias, ident.Name, fn.Name.Name))
+				} else {
+					pkgRefs = append(pkgRefs, fmt.Sprintf("%s.%s.%s", alias, ident.Name, fn.Name.Name))
+				}
+			}
+		}
+		if len(pkgRefs) == 0 {
+			continue
+		}
+		sort.Strings(pkgRefs)
+		imports = append(imports, fmt.Sprintf("%s %q", alias, p.ImportPath))
+		refs = append(refs, pkgRefs...)
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("package main\n\nimport (\n")
+	for _, imp := range imports {
+		fmt.Fprintf(buf, "\t%s\n", imp)
+	}
+	buf.WriteString(")\n\nvar keep = []any{\n")
+	for _, r := range refs {
+		fmt.Fprintf(buf, "\t%s,\n", r)
+	}
+	buf.WriteString("}\n\nfunc main() {\n\tprintln(len(keep))\n}\n")
+	return buf.Bytes(), nil
+}
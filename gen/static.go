@@ -109,11 +109,39 @@ import "fmt"
 
 `
 
+const typeLayoutHeader = `
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Code generated by go generate; DO NOT EDIT.
+
+package gore
+
+import "fmt"
+
+`
+
 var (
 	goversionCsv         = filepath.Join(getSourceDir(), "resources", "goversions.csv")
 	stdpkgOutputFile     = filepath.Join(getSourceDir(), "stdpkg_gen.go")
 	goversionOutputFile  = filepath.Join(getSourceDir(), "goversion_gen.go")
 	moduleDataOutputFile = filepath.Join(getSourceDir(), "moduledata_gen.go")
+	typeLayoutOutputFile = filepath.Join(getSourceDir(), "typelayout_gen.go")
+	signatureOutputDir   = filepath.Join(getSourceDir(), "resources", "signatures")
 
 	repoCacheFile = filepath.Join(getSourceDir(), "gen", ".go-repo-cache")
 )
@@ -0,0 +1,527 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// The type structures are defined in reflect/type.go up to Go 1.20 and in
+// internal/abi/type.go since Go 1.21.
+const (
+	firstTypeLayoutVersion = 7
+	firstABITypeVersion    = 21
+)
+
+// layoutField is a field of the parsed type structure a source field is
+// converted to.
+type layoutField struct {
+	// names holds the target field names. Slices are stored as three
+	// fields, the data pointer, the length and the capacity.
+	names []string
+	// typ is the Go type of the target fields.
+	typ string
+}
+
+// layoutKind describes a runtime type structure.
+type layoutKind struct {
+	// name is the prefix of the generated structs.
+	name string
+	// parser is the field of typeLayouts the parse function is assigned to.
+	parser string
+	// target is the type the generated structs are converted to.
+	target string
+	// sources are the struct names in reflect and internal/abi.
+	sources [2]string
+	// fields maps the lower case source field names, without the trailing
+	// underscore used in internal/abi, to the target fields. Source fields
+	// that are not listed are kept as blank fields.
+	fields map[string]layoutField
+}
+
+// The map types are not generated since the layout also depends on the
+// GOEXPERIMENT settings, see newTypeParser.
+var layoutKinds = []*layoutKind{
+	{
+		name: "rtype", parser: "rtype", target: "rtypeGo64", sources: [2]string{"rtype", "Type"},
+		fields: map[string]layoutField{
+			"size":       {[]string{"Size"}, "uint64"},
+			"ptrdata":    {[]string{"Ptrdata"}, "uint64"},
+			"ptrbytes":   {[]string{"Ptrdata"}, "uint64"},
+			"hash":       {[]string{"Hash"}, "uint32"},
+			"tflag":      {[]string{"Tflag"}, "uint8"},
+			"align":      {[]string{"Align"}, "uint8"},
+			"fieldalign": {[]string{"FieldAlign"}, "uint8"},
+			"kind":       {[]string{"Kind"}, "uint8"},
+			"alg":        {[]string{"Equal"}, "uint64"},
+			"equal":      {[]string{"Equal"}, "uint64"},
+			"gcdata":     {[]string{"Gcdata"}, "uint64"},
+			"str":        {[]string{"Str"}, "int32"},
+			"ptrtothis":  {[]string{"PtrToThis"}, "int32"},
+		},
+	},
+	{
+		name: "uncommonType", parser: "uncommon", target: "uncommonType", sources: [2]string{"uncommonType", "UncommonType"},
+		fields: map[string]layoutField{
+			"pkgpath": {[]string{"PkgPath"}, "int32"},
+			"mcount":  {[]string{"Mcount"}, "uint16"},
+			"xcount":  {[]string{"Xcount"}, "uint16"},
+			"moff":    {[]string{"Moff"}, "uint32"},
+		},
+	},
+	{
+		name: "method", parser: "method", target: "method", sources: [2]string{"method", "Method"},
+		fields: map[string]layoutField{
+			"name": {[]string{"Name"}, "int32"},
+			"mtyp": {[]string{"Mtyp"}, "int32"},
+			"ifn":  {[]string{"Ifn"}, "int32"},
+			"tfn":  {[]string{"Tfn"}, "int32"},
+		},
+	},
+	{
+		name: "imethod", parser: "imethod", target: "imethod", sources: [2]string{"imethod", "Imethod"},
+		fields: map[string]layoutField{
+			"name": {[]string{"Name"}, "int32"},
+			"typ":  {[]string{"Typ"}, "int32"},
+		},
+	},
+	{
+		name: "arrayType", parser: "arrayType", target: "arrayType64", sources: [2]string{"arrayType", "ArrayType"},
+		fields: map[string]layoutField{
+			"elem":  {[]string{"Eem"}, "uint64"},
+			"slice": {[]string{"Slice"}, "uint64"},
+			"len":   {[]string{"Len"}, "uint64"},
+		},
+	},
+	{
+		name: "chanType", parser: "chanType", target: "chanType", sources: [2]string{"chanType", "ChanType"},
+		fields: map[string]layoutField{
+			"elem": {[]string{"Elem"}, "uint64"},
+			"dir":  {[]string{"Dir"}, "uint64"},
+		},
+	},
+	{
+		name: "funcType", parser: "funcType", target: "funcType", sources: [2]string{"funcType", "FuncType"},
+		fields: map[string]layoutField{
+			"incount":  {[]string{"InCount"}, "uint64"},
+			"outcount": {[]string{"OutCount"}, "uint64"},
+		},
+	},
+	{
+		name: "interfaceType", parser: "interfaceType", target: "interfaceType", sources: [2]string{"interfaceType", "InterfaceType"},
+		fields: map[string]layoutField{
+			"pkgpath": {[]string{"PkgPath"}, "uint64"},
+			"methods": {[]string{"Methods", "MethodsLen", "MethodsCap"}, "uint64"},
+		},
+	},
+	{
+		name: "structType", parser: "structType", target: "structType64", sources: [2]string{"structType", "StructType"},
+		fields: map[string]layoutField{
+			"pkgpath": {[]string{"PkgPath"}, "uint64"},
+			"fields":  {[]string{"FieldsData", "FieldsLen", "FieldsCap"}, "uint64"},
+		},
+	},
+	{
+		name: "structField", parser: "structField", target: "structField", sources: [2]string{"structField", "StructField"},
+		fields: map[string]layoutField{
+			"name": {[]string{"Name"}, "uint64"},
+			"typ":  {[]string{"Typ"}, "uint64"},
+			// The offset has been called offset, offsetAnon and offsetEmbed.
+			"offset":      {[]string{"OffsetEmbed"}, "uint64"},
+			"offsetanon":  {[]string{"OffsetEmbed"}, "uint64"},
+			"offsetembed": {[]string{"OffsetEmbed"}, "uint64"},
+		},
+	},
+}
+
+// getTypeLayoutSources returns the source file with the type structures for
+// each minor version from Go 1.7 to the latest known release.
+func getTypeLayoutSources() (map[int]string, error) {
+	maxMinor, err := getMaxVersionBit()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[int]string)
+	for i := firstTypeLayoutVersion; i <= maxMinor; i++ {
+		fmt.Println("Process type structures for go1." + fmt.Sprint(i) + "...")
+		branch := fmt.Sprintf("release-branch.go1.%d", i)
+
+		reference, err := goRepo.Reference(plumbing.NewBranchReferenceName(branch), false)
+		if err != nil {
+			return nil, err
+		}
+		commit, err := goRepo.CommitObject(reference.Hash())
+		if err != nil {
+			return nil, err
+		}
+		tree, err := commit.Tree()
+		if err != nil {
+			return nil, err
+		}
+
+		path := "src/reflect/type.go"
+		if i >= firstABITypeVersion {
+			path = "src/internal/abi/type.go"
+		}
+		file, err := tree.File(path)
+		if err != nil {
+			return nil, err
+		}
+		content, err := file.Contents()
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = content
+	}
+	return ret, nil
+}
+
+// layoutFieldType is the storage class of a source field.
+type layoutFieldType int
+
+const (
+	fieldWord layoutFieldType = iota
+	fieldSlice
+	fieldUint8
+	fieldUint16
+	fieldUint32
+	fieldInt32
+	fieldUint64
+)
+
+func (t layoutFieldType) size(bits int) int {
+	switch t {
+	case fieldWord:
+		return bits / 8
+	case fieldSlice:
+		return 3 * bits / 8
+	case fieldUint8:
+		return 1
+	case fieldUint16:
+		return 2
+	case fieldUint32, fieldInt32:
+		return 4
+	}
+	return 8
+}
+
+func (t layoutFieldType) align(bits int) int {
+	if t == fieldSlice {
+		return bits / 8
+	}
+	if t == fieldUint64 && bits == 32 {
+		return 4
+	}
+	return t.size(bits)
+}
+
+func (t layoutFieldType) goType(bits int) string {
+	switch t {
+	case fieldWord, fieldSlice:
+		return fmt.Sprintf("uint%d", bits)
+	case fieldUint8:
+		return "uint8"
+	case fieldUint16:
+		return "uint16"
+	case fieldUint32:
+		return "uint32"
+	case fieldInt32:
+		return "int32"
+	}
+	return "uint64"
+}
+
+// typeSpecs holds the type declarations of a source file.
+type typeSpecs map[string]*ast.TypeSpec
+
+func parseTypeSpecs(src string) (typeSpecs, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	specs := make(typeSpecs)
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			ts := spec.(*ast.TypeSpec)
+			specs[ts.Name.Name] = ts
+		}
+	}
+	return specs, nil
+}
+
+// fieldType resolves the storage class of the type expression. Named types
+// are resolved to their underlying type, and structs with a single field,
+// like name, to the type of the field.
+func (s typeSpecs) fieldType(expr ast.Expr) (layoutFieldType, error) {
+	switch t := expr.(type) {
+	case *ast.StarExpr, *ast.FuncType:
+		return fieldWord, nil
+	case *ast.SelectorExpr:
+		if pkg, ok := t.X.(*ast.Ident); ok && pkg.Name == "unsafe" && t.Sel.Name == "Pointer" {
+			return fieldWord, nil
+		}
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return fieldSlice, nil
+		}
+	case *ast.StructType:
+		if len(t.Fields.List) == 1 && len(t.Fields.List[0].Names) <= 1 {
+			return s.fieldType(t.Fields.List[0].Type)
+		}
+	case *ast.Ident:
+		switch t.Name {
+		case "uintptr", "int", "uint":
+			return fieldWord, nil
+		case "uint8", "byte", "bool", "int8":
+			return fieldUint8, nil
+		case "uint16", "int16":
+			return fieldUint16, nil
+		case "uint32":
+			return fieldUint32, nil
+		case "int32":
+			return fieldInt32, nil
+		case "uint64", "int64":
+			return fieldUint64, nil
+		}
+		if spec, ok := s[t.Name]; ok {
+			return s.fieldType(spec.Type)
+		}
+	}
+	return 0, fmt.Errorf("unhandled type: %#v", expr)
+}
+
+// structLayout returns the fields of the generated struct for a kind. The
+// embedded type structure that the kind specific structures start with is
+// skipped. Its size is a multiple of the word size.
+func structLayout(specs typeSpecs, kind *layoutKind, source string, bits int) ([]string, error) {
+	spec, ok := specs[source]
+	if !ok {
+		return nil, fmt.Errorf("%s not found", source)
+	}
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a struct", source)
+	}
+
+	var fields []string
+	off, maxAlign, pad := 0, 1, 0
+	addPad := func(n int) {
+		fields = append(fields, fmt.Sprintf("_ [%d]byte", n))
+		pad++
+	}
+	for _, field := range st.Fields.List {
+		if len(field.Names) == 0 {
+			// The embedded rtype or Type.
+			maxAlign = bits / 8
+			continue
+		}
+		ft, err := specs.fieldType(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		if a := ft.align(bits); off%a != 0 {
+			addPad(a - off%a)
+			off += a - off%a
+		}
+		maxAlign = max(maxAlign, ft.align(bits))
+
+		for _, name := range field.Names {
+			norm := strings.TrimSuffix(strings.ToLower(name.Name), "_")
+			target, known := kind.fields[norm]
+			switch {
+			case known && ft == fieldSlice:
+				fields = append(fields, fmt.Sprintf("%s %s", strings.Join(target.names, ", "), ft.goType(bits)))
+			case known:
+				fields = append(fields, fmt.Sprintf("%s %s", target.names[0], ft.goType(bits)))
+			case ft == fieldSlice:
+				fields = append(fields, fmt.Sprintf("_, _, _ %s", ft.goType(bits)))
+			default:
+				fields = append(fields, fmt.Sprintf("_ %s", ft.goType(bits)))
+			}
+			off += ft.size(bits)
+		}
+	}
+	if off%maxAlign != 0 {
+		addPad(maxAlign - off%maxAlign)
+	}
+	return fields, nil
+}
+
+// typeLayoutGenerator writes the structs for the layouts. A struct is only
+// written for the first version that uses the layout.
+type typeLayoutGenerator struct {
+	buf *bytes.Buffer
+	// layouts maps the kind, bits and fields to the struct name.
+	layouts map[string]string
+	// selected holds the struct names for each version and word size, in
+	// the order of layoutKinds.
+	selected map[int]map[int][]string
+	versions []int
+}
+
+func (g *typeLayoutGenerator) init() {
+	g.buf = &bytes.Buffer{}
+	g.buf.WriteString(typeLayoutHeader)
+	g.layouts = make(map[string]string)
+	g.selected = make(map[int]map[int][]string)
+}
+
+func (g *typeLayoutGenerator) writeln(format string, a ...interface{}) {
+	_, _ = fmt.Fprintf(g.buf, format+"\n", a...)
+}
+
+func (g *typeLayoutGenerator) add(versionCode int, src string) error {
+	specs, err := parseTypeSpecs(src)
+	if err != nil {
+		return err
+	}
+	g.versions = append(g.versions, versionCode)
+	g.selected[versionCode] = make(map[int][]string)
+
+	source := 0
+	if versionCode >= firstABITypeVersion {
+		source = 1
+	}
+	for _, kind := range layoutKinds {
+		for _, bits := range []int{32, 64} {
+			fields, err := structLayout(specs, kind, kind.sources[source], bits)
+			if err != nil {
+				return fmt.Errorf("go1.%d: %w", versionCode, err)
+			}
+			key := fmt.Sprintf("%s/%d/%s", kind.name, bits, strings.Join(fields, ";"))
+			name, ok := g.layouts[key]
+			if !ok {
+				name = fmt.Sprintf("%s_1_%d_%d", kind.name, versionCode, bits)
+				g.layouts[key] = name
+				g.writeLayout(kind, name, fields)
+			}
+			g.selected[versionCode][bits] = append(g.selected[versionCode][bits], name)
+		}
+	}
+	return nil
+}
+
+func (g *typeLayoutGenerator) writeLayout(kind *layoutKind, name string, fields []string) {
+	g.writeln("type %s struct {", name)
+	for _, f := range fields {
+		g.writeln("%s", f)
+	}
+	g.writeln("}\n")
+
+	g.writeln("func (t %s) convert() %s {", name, kind.target)
+	g.writeln("return %s{", kind.target)
+	for _, f := range fields {
+		names := f[:strings.LastIndex(f, " ")]
+		for _, n := range strings.Split(names, ", ") {
+			if n == "_" {
+				continue
+			}
+			for _, target := range kind.fields {
+				if contains(target.names, n) {
+					g.writeln("%s: %s(t.%[1]s),", n, target.typ)
+					break
+				}
+			}
+		}
+	}
+	g.writeln("}\n}\n")
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// writeSelector writes selectTypeLayouts. Consecutive versions with the same
+// layouts share a case.
+func (g *typeLayoutGenerator) writeSelector() {
+	g.writeln("func selectTypeLayouts(v int, bits int) (*typeLayouts, error) {")
+	g.writeln("switch {")
+	for _, bits := range []int{32, 64} {
+		for i := 0; i < len(g.versions); {
+			first := g.versions[i]
+			names := g.selected[first][bits]
+			j := i + 1
+			for j < len(g.versions) && strings.Join(g.selected[g.versions[j]][bits], ",") == strings.Join(names, ",") {
+				j++
+			}
+			last := g.versions[j-1]
+			if first == last {
+				g.writeln("case v == %d && bits == %d:", first, bits)
+			} else {
+				g.writeln("case v >= %d && v <= %d && bits == %d:", first, last, bits)
+			}
+			g.writeln("return &typeLayouts{")
+			for k, kind := range layoutKinds {
+				g.writeln("%s: readTypeLayout[%s, %s],", kind.parser, names[k], kind.target)
+			}
+			g.writeln("}, nil")
+			i = j
+		}
+	}
+	g.writeln("default:")
+	g.writeln(`return nil, fmt.Errorf("unsupported version %%d and bits %%d", v, bits)`)
+	g.writeln("}\n}\n")
+
+	g.writeln("// newestTypeLayoutVersion is the newest minor version with known type layouts.")
+	g.writeln("const newestTypeLayoutVersion = %d", g.versions[len(g.versions)-1])
+}
+
+func generateTypeLayouts() {
+	fmt.Println("Generating " + typeLayoutOutputFile)
+
+	sources, err := getTypeLayoutSources()
+	if err != nil {
+		panic(err)
+	}
+
+	g := typeLayoutGenerator{}
+	g.init()
+	for v := firstTypeLayoutVersion; v < firstTypeLayoutVersion+len(sources); v++ {
+		if err = g.add(v, sources[v]); err != nil {
+			panic(err)
+		}
+	}
+	g.writeSelector()
+
+	out, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		panic(err)
+	}
+	if err = os.WriteFile(typeLayoutOutputFile, out, 0o666); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,297 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"sort"
+	"strings"
+)
+
+// shapePrefix is the prefix of the shape types the compiler instantiates
+// generic functions with. All type arguments with the same underlying type
+// share a shape, and the code for the shape.
+const shapePrefix = "go.shape."
+
+// dictionaryInfix separates the package path from the instantiation in the
+// name of a dictionary symbol, for example main..dict.Sum[int].
+const dictionaryInfix = "..dict."
+
+// Instantiation describes a type or function instantiated from a generic
+// one.
+type Instantiation struct {
+	// Generic is the name without the type arguments, for example main.List
+	// for main.List[int] and main.(*List).Push for
+	// main.(*List[go.shape.int]).Push.
+	Generic string `json:"generic"`
+	// TypeArgs holds the type arguments as they are written in the name.
+	// Functions are instantiated with shapes, for example go.shape.int.
+	TypeArgs []string `json:"typeArgs"`
+	// ArgTypes holds the types of the arguments, if they are known. Only
+	// set for types.
+	ArgTypes []*GoType `json:"-"`
+}
+
+// IsShape returns true if the type argument is a shape type.
+func IsShape(arg string) bool {
+	return strings.HasPrefix(arg, shapePrefix)
+}
+
+// Dictionary is the dictionary of an instantiation of a generic function or
+// type. The code is shared by all instantiations with the same shape, the
+// dictionary holds the information that is specific to the type arguments.
+type Dictionary struct {
+	// Name is the symbol name, for example main..dict.Sum[int].
+	Name string `json:"name"`
+	// Address is the virtual address of the dictionary.
+	Address uint64 `json:"address"`
+	// Instance holds the generic name, for example main.Sum, and the type
+	// arguments.
+	Instance *Instantiation `json:"instance"`
+}
+
+// parseInstantiation parses the type arguments out of a type or function
+// name. Nil is returned if the name isn't an instantiation. Only the first
+// argument list is used, so for a closure in a generic function like
+// main.Sum[go.shape.int].func1, the arguments are the ones of main.Sum.
+func parseInstantiation(name string) *Instantiation {
+	start, end := typeArgsBounds(name)
+	if start < 0 {
+		return nil
+	}
+	return &Instantiation{
+		Generic:  name[:start] + name[end+1:],
+		TypeArgs: splitTypeArgs(name[start+1 : end]),
+	}
+}
+
+// typeArgsBounds returns the index of the brackets around the first type
+// argument list or -1 if there is none. Names of unnamed types, like
+// []main.T or map[string]int, don't have an argument list of their own.
+func typeArgsBounds(name string) (int, int) {
+	for _, prefix := range unnamedTypePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return -1, -1
+		}
+	}
+	start := strings.IndexByte(name, '[')
+	if start <= 0 {
+		return -1, -1
+	}
+	depth := 0
+	for i := start; i < len(name); i++ {
+		switch name[i] {
+		case '[', '(', '{':
+			depth++
+		case ']', ')', '}':
+			depth--
+			if depth == 0 {
+				if name[i] != ']' || i == start+1 {
+					return -1, -1
+				}
+				return start, i
+			}
+		}
+	}
+	return -1, -1
+}
+
+// splitTypeArgs splits a type argument list at the commas that are not
+// within another type.
+func splitTypeArgs(list string) []string {
+	var args []string
+	depth, last := 0, 0
+	for i := 0; i < len(list); i++ {
+		switch list[i] {
+		case '[', '(', '{':
+			depth++
+		case ']', ')', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, strings.TrimSpace(list[last:i]))
+				last = i + 1
+			}
+		}
+	}
+	return append(args, strings.TrimSpace(list[last:]))
+}
+
+// dictionaryOwner returns the name of the generic function or type whose
+// dictionary is passed to the function. Methods use the dictionary of the
+// receiver type and closures the one of the enclosing function.
+func dictionaryOwner(name string) string {
+	start, _ := typeArgsBounds(name)
+	if start < 0 {
+		return ""
+	}
+	owner := name[:start]
+	// Remove the receiver punctuation, main.(*List -> main.List.
+	if i := strings.LastIndex(owner, "("); i >= 0 {
+		owner = owner[:i] + strings.TrimPrefix(owner[i+1:], "*")
+	}
+	return owner
+}
+
+// shapeMatches returns true if the type argument can have the shape.
+// Only the shapes that can be derived from the name are known: types
+// with a basic underlying type are matched by name and all pointers share
+// a shape.
+func shapeMatches(shape, arg string) bool {
+	if shape == shapePrefix+arg {
+		return true
+	}
+	return strings.HasPrefix(arg, "*") && shape == shapePrefix+"*uint8"
+}
+
+// setTypeInstances sets the instantiation information for the types and
+// their fields.
+func setTypeInstances(types []*GoType) {
+	byName := make(map[string]*GoType, len(types))
+	for _, t := range types {
+		if _, ok := byName[t.Name]; !ok {
+			byName[t.Name] = t
+		}
+	}
+	set := func(t *GoType) {
+		inst := parseInstantiation(t.Name)
+		if inst == nil {
+			return
+		}
+		inst.ArgTypes = make([]*GoType, len(inst.TypeArgs))
+		for i, a := range inst.TypeArgs {
+			inst.ArgTypes[i] = byName[a]
+		}
+		t.Instance = inst
+	}
+	for _, t := range types {
+		set(t)
+		for _, f := range t.Fields {
+			set(f)
+		}
+	}
+}
+
+// Dictionaries returns the dictionaries of the generic instantiations in
+// the binary and links the functions to the dictionaries they use. The
+// dictionaries are found by their symbols, so none are returned if the
+// binary is stripped.
+//
+// A function is linked to a dictionary if it's the only instantiation of
+// the generic function or type, or if it's the only one whose shapes match
+// the type arguments of the dictionary.
+func (f *GoFile) Dictionaries() ([]*Dictionary, error) {
+	syms, err := f.symbols()
+	if err != nil {
+		return nil, err
+	}
+	pkgs, err := f.allPackages()
+	if err != nil {
+		return nil, err
+	}
+
+	var dicts []*Dictionary
+	for name, sym := range syms {
+		i := strings.Index(name, dictionaryInfix)
+		if i < 0 {
+			continue
+		}
+		inst := parseInstantiation(name[:i] + "." + name[i+len(dictionaryInfix):])
+		if inst == nil {
+			continue
+		}
+		dicts = append(dicts, &Dictionary{Name: name, Address: sym.Value, Instance: inst})
+	}
+	sort.Slice(dicts, func(i, j int) bool { return dicts[i].Name < dicts[j].Name })
+
+	// Group the shape instantiations by the owner of the dictionary.
+	byOwner := make(map[string][]*Function)
+	for _, p := range pkgs {
+		fns := append([]*Function{}, p.Functions...)
+		for _, m := range p.Methods {
+			fns = append(fns, m.Function)
+		}
+		for _, fn := range fns {
+			if fn.Instance == nil {
+				continue
+			}
+			fn.Dictionaries = nil
+			owner := dictionaryOwner(fn.Func.Name)
+			byOwner[owner] = append(byOwner[owner], fn)
+		}
+	}
+
+	for _, d := range dicts {
+		fns := byOwner[d.Instance.Generic]
+		group := shapeGroup(fns, func(fn *Function) bool {
+			return argsMatchShapes(fn.Instance.TypeArgs, d.Instance.TypeArgs)
+		})
+		if group == nil {
+			group = shapeGroup(fns, func(*Function) bool { return true })
+		}
+		for _, fn := range group {
+			fn.Dictionaries = append(fn.Dictionaries, d)
+		}
+	}
+	return dicts, nil
+}
+
+// shapeGroup returns the functions with the only shape for which match
+// returns true. Nil is returned if no or more than one shape matches.
+func shapeGroup(fns []*Function, match func(*Function) bool) []*Function {
+	var shape string
+	var group []*Function
+	for _, fn := range fns {
+		if !match(fn) {
+			continue
+		}
+		key := strings.Join(fn.Instance.TypeArgs, ",")
+		if group != nil && key != shape {
+			return nil
+		}
+		shape = key
+		group = append(group, fn)
+	}
+	return group
+}
+
+func argsMatchShapes(shapes, args []string) bool {
+	if len(shapes) != len(args) {
+		return false
+	}
+	for i := range shapes {
+		if !shapeMatches(shapes[i], args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// symbols returns the symbol table of the file.
+func (f *GoFile) symbols() (map[string]Symbol, error) {
+	switch fh := f.fh.(type) {
+	case *elfFile:
+		return fh.getsymtab()
+	case *peFile:
+		return fh.getsymtab()
+	case *machoFile:
+		return fh.getsymtab(), nil
+	case *wasmFile:
+		return fh.symtab, nil
+	}
+	return nil, ErrSymbolNotFound
+}
@@ -0,0 +1,150 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInstantiation(t *testing.T) {
+	cases := []struct {
+		name    string
+		generic string
+		args    []string
+	}{
+		{"main.List[int]", "main.List", []string{"int"}},
+		{"main.Pair[string,main.List[int]]", "main.Pair", []string{"string", "main.List[int]"}},
+		{"main.Sum[go.shape.int]", "main.Sum", []string{"go.shape.int"}},
+		{"main.(*List[go.shape.*uint8]).Push", "main.(*List).Push", []string{"go.shape.*uint8"}},
+		{"main.Sum[go.shape.int].func1", "main.Sum.func1", []string{"go.shape.int"}},
+		{"example.com/a/b.F[go.shape.struct { A int; B string },go.shape.func(int, string) bool]", "example.com/a/b.F",
+			[]string{"go.shape.struct { A int; B string }", "go.shape.func(int, string) bool"}},
+		{"main.M[map[string]int]", "main.M", []string{"map[string]int"}},
+	}
+	for _, c := range cases {
+		inst := parseInstantiation(c.name)
+		require.NotNil(t, inst, c.name)
+		assert.Equal(t, c.generic, inst.Generic, c.name)
+		assert.Equal(t, c.args, inst.TypeArgs, c.name)
+	}
+
+	for _, name := range []string{"main.main", "[]main.List[int]", "map[string]int", "*main.List[int]", "main.x[]", "main.broken[int"} {
+		assert.Nil(t, parseInstantiation(name), name)
+	}
+
+	assert.True(t, IsShape("go.shape.int"))
+	assert.False(t, IsShape("int"))
+}
+
+func TestDictionaryOwner(t *testing.T) {
+	assert.Equal(t, "main.Sum", dictionaryOwner("main.Sum[go.shape.int]"))
+	assert.Equal(t, "main.Sum", dictionaryOwner("main.Sum[go.shape.int].func1"))
+	assert.Equal(t, "main.List", dictionaryOwner("main.(*List[go.shape.string]).Push"))
+	assert.Equal(t, "main.List", dictionaryOwner("main.List[go.shape.string].Len"))
+	assert.Equal(t, "", dictionaryOwner("main.main"))
+
+	assert.True(t, shapeMatches("go.shape.int", "int"))
+	assert.True(t, shapeMatches("go.shape.*uint8", "*main.T"))
+	assert.False(t, shapeMatches("go.shape.int", "string"))
+}
+
+func TestSetTypeInstances(t *testing.T) {
+	str := &GoType{Kind: reflect.String, Name: "string"}
+	list := &GoType{Kind: reflect.Struct, Name: "main.List[string]"}
+	pair := &GoType{Kind: reflect.Struct, Name: "main.Pair[string,main.List[string]]"}
+	field := *list
+	field.FieldName = "L"
+	pair.Fields = []*GoType{&field}
+	slice := &GoType{Kind: reflect.Slice, Name: "[]main.List[string]", Element: list}
+
+	setTypeInstances([]*GoType{str, list, pair, slice})
+	require.NotNil(t, pair.Instance)
+	assert.Equal(t, "main.Pair", pair.Instance.Generic)
+	assert.Equal(t, []*GoType{str, list}, pair.Instance.ArgTypes)
+	require.NotNil(t, field.Instance)
+	assert.Equal(t, "main.List", field.Instance.Generic)
+	assert.Nil(t, slice.Instance)
+	assert.Nil(t, str.Instance)
+}
+
+const genericsrc = `
+package main
+
+import "fmt"
+
+type List[T any] struct{ items []T }
+
+//go:noinline
+func (l *List[T]) Push(v T) { l.items = append(l.items, v) }
+
+//go:noinline
+func Sum[T int | float64](xs ...T) T {
+	var s T
+	for _, x := range xs {
+		s += x
+	}
+	return s
+}
+
+func main() {
+	l := &List[string]{}
+	l.Push("a")
+	p := &List[*int]{}
+	p.Push(nil)
+	fmt.Println(l, p, Sum(1, 2), Sum(1.5))
+}
+`
+
+func TestDictionaries(t *testing.T) {
+	f, err := Open(buildTestBinary(t, genericsrc))
+	require.NoError(t, err)
+	defer f.Close()
+
+	dicts, err := f.Dictionaries()
+	require.NoError(t, err)
+	names := make(map[string]*Dictionary)
+	for _, d := range dicts {
+		names[d.Name] = d
+	}
+	require.Contains(t, names, "main..dict.Sum[int]")
+	assert.Equal(t, "main.Sum", names["main..dict.Sum[int]"].Instance.Generic)
+	assert.Equal(t, []string{"int"}, names["main..dict.Sum[int]"].Instance.TypeArgs)
+
+	pkgs, err := f.GetPackages()
+	require.NoError(t, err)
+	linked := make(map[string][]string)
+	for _, p := range pkgs {
+		fns := append([]*Function{}, p.Functions...)
+		for _, m := range p.Methods {
+			fns = append(fns, m.Function)
+		}
+		for _, fn := range fns {
+			for _, d := range fn.Dictionaries {
+				linked[fn.Func.Name] = append(linked[fn.Func.Name], d.Name)
+			}
+		}
+	}
+	assert.Equal(t, []string{"main..dict.Sum[int]"}, linked["main.Sum[go.shape.int]"])
+	assert.Equal(t, []string{"main..dict.Sum[float64]"}, linked["main.Sum[go.shape.float64]"])
+	assert.Equal(t, []string{"main..dict.List[string]"}, linked["main.(*List[go.shape.string]).Push"])
+	assert.Equal(t, []string{"main..dict.List[*int]"}, linked["main.(*List[go.shape.*uint8]).Push"])
+}
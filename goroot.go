@@ -331,6 +331,12 @@ func findGoRootPath(f *GoFile) (string, error) {
 		return goroot, nil
 	}
 
+	// The runtime functions of gccgo and TinyGo are different, and their
+	// standard libraries are not compiled from a GOROOT of the gc toolchain.
+	if f.FileInfo.Compiler != CompilerGc {
+		return "", ErrNoGoRootFound
+	}
+
 	// There is no GOROOT function may be inlined (after go1.16)
 	// at this time GOROOT is obtained through time_init function
 	goroot, err := tryFromGOROOT(f)
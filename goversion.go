@@ -21,8 +21,12 @@ package gore
 
 import (
 	"bytes"
+	"encoding/csv"
 	"errors"
+	"fmt"
+	"io"
 	"regexp"
+	"sync"
 
 	"golang.org/x/arch/x86/x86asm"
 
@@ -35,22 +39,97 @@ var goVersionMatcher = regexp.MustCompile(`(go[\d+.]*(beta|rc)?[\d*])`)
 // GoVersion holds information about the compiler version.
 type GoVersion struct {
 	// Name is a string representation of the version.
-	Name string
+	Name string `json:"name"`
 	// SHA is a digest of the git commit for the release.
-	SHA string
+	SHA string `json:"sha,omitempty"`
 	// Timestamp is a string of the timestamp when the commit was created.
-	Timestamp string
+	Timestamp string `json:"timestamp,omitempty"`
 }
 
+// goversionsMu guards goversions, which can be extended by LoadGoVersions.
+var goversionsMu sync.RWMutex
+
 // ResolveGoVersion tries to return the GoVersion for the given tag.
 // For example the tag: go1 will return a GoVersion struct representing version 1.0 of the compiler.
-// If no goversion for the given tag is found, nil is returned.
+// If the tag is not a known release but a well-formed Go 1 version, for
+// example a point release newer than the library or a version with a
+// custom suffix like go1.21.0-bigcorp, a GoVersion with only the name is
+// returned. If the tag is not a valid version, nil is returned.
 func ResolveGoVersion(tag string) *GoVersion {
-	v, ok := goversions[tag]
-	if !ok {
+	if v := knownGoVersion(tag); v != nil {
+		return v
+	}
+	if !isValidGoVersion(tag) {
 		return nil
 	}
-	return v
+	return &GoVersion{Name: tag}
+}
+
+// knownGoVersion returns the release for the tag, or nil if it's not known
+// to the library.
+func knownGoVersion(tag string) *GoVersion {
+	goversionsMu.RLock()
+	defer goversionsMu.RUnlock()
+	return goversions[tag]
+}
+
+// isValidGoVersion returns true if the tag is a well-formed Go 1 version.
+func isValidGoVersion(tag string) bool {
+	v := extern.StripGo(tag)
+	return gover.IsValid(v) && gover.Parse(v).Major == "1"
+}
+
+// newestKnownGoVersion returns the newest known release.
+func newestKnownGoVersion() *GoVersion {
+	goversionsMu.RLock()
+	defer goversionsMu.RUnlock()
+	var ret *GoVersion
+	for _, v := range goversions {
+		if ret == nil || GoVersionCompare(v.Name, ret.Name) > 0 {
+			ret = v
+		}
+	}
+	return ret
+}
+
+// LoadGoVersions adds the releases read from r to the known versions. The
+// format is the one of resources/goversions.csv, one release per line with
+// the tag, the commit and the timestamp, of which only the tag is required.
+// Known releases are replaced. If a tag is not a valid Go version, an error
+// wrapping ErrInvalidGoVersion is returned and no release is added.
+func LoadGoVersions(r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		return fmt.Errorf("error when reading the versions: %w", err)
+	}
+
+	versions := make([]*GoVersion, 0, len(records))
+	for _, record := range records {
+		if len(record) > 3 {
+			return fmt.Errorf("error when reading the versions: too many fields for %s", record[0])
+		}
+		v := &GoVersion{Name: record[0]}
+		if !isValidGoVersion(v.Name) {
+			return fmt.Errorf("%w: %q", ErrInvalidGoVersion, v.Name)
+		}
+		if len(record) > 1 {
+			v.SHA = record[1]
+		}
+		if len(record) > 2 {
+			v.Timestamp = record[2]
+		}
+		versions = append(versions, v)
+	}
+
+	goversionsMu.Lock()
+	defer goversionsMu.Unlock()
+	for _, v := range versions {
+		goversions[v.Name] = v
+	}
+	return nil
 }
 
 // GoVersionCompare compares two version strings.
@@ -69,7 +148,7 @@ func GoVersionCompare(a, b string) int {
 func findGoCompilerVersion(f *GoFile) (*GoVersion, error) {
 	// if DWARF debug info exists, then this can simply be obtained from there
 	if gover, ok := getBuildVersionFromDwarf(f.fh); ok {
-		if ver := ResolveGoVersion(gover); ver != nil {
+		if ver := resolveBaseGoVersion(gover); ver != nil {
 			return ver, nil
 		}
 	}
@@ -91,12 +170,18 @@ func findGoCompilerVersion(f *GoFile) (*GoVersion, error) {
 		return nil, err
 	}
 
+	// A version that is not known is only used if no known version is found
+	// and it's newer than all the known versions, like a new release.
+	var unknown *GoVersion
 	for {
 		version := matchGoVersionString(data)
 		if version == "" {
-			return nil, ErrNoGoVersionFound
+			break
+		}
+		ver := knownGoVersion(version)
+		if ver == nil && unknown == nil && isValidGoVersion(version) && GoVersionCompare(version, newestKnownGoVersion().Name) > 0 {
+			unknown = &GoVersion{Name: version}
 		}
-		ver := ResolveGoVersion(version)
 		// Go before 1.4 does not have the version string, so if we have found
 		// a version string below 1.4beta1 it is a false positive.
 		if ver == nil || GoVersionCompare(ver.Name, "go1.4beta1") < 0 {
@@ -110,7 +195,30 @@ func findGoCompilerVersion(f *GoFile) (*GoVersion, error) {
 		}
 		return ver, nil
 	}
-	return nil, nil
+
+	if unknown != nil {
+		return unknown, nil
+	}
+
+	// Go before 1.4 has no version string.
+	if ver := f.versionFromRuntime(); ver != nil {
+		return ver, nil
+	}
+	return nil, ErrNoGoVersionFound
+}
+
+// versionFromRuntime estimates the version of a binary compiled before
+// Go 1.5 from the runtime functions, see estimateVersionGo12.
+func (f *GoFile) versionFromRuntime() *GoVersion {
+	tab, err := f.PCLNTab()
+	if err != nil {
+		return nil
+	}
+	ver := estimateVersionGo12(tab, f.pclntabBytes, f.FileInfo.ByteOrder)
+	if ver != nil {
+		f.warn("no Go version found, the version %s was estimated from the runtime functions", ver.Name)
+	}
+	return ver
 }
 
 // tryFromSchedInit tries to identify the version of the Go compiler that compiled the code.
@@ -242,16 +350,11 @@ disasm:
 			continue
 		}
 
-		// Likely the version string.
-		ver := string(bstr)
-
-		resolvedVer := ResolveGoVersion(ver)
-		if resolvedVer != nil {
-			return resolvedVer
+		// Likely the version string, which can be followed by the
+		// experiments.
+		if ver := resolveBaseGoVersion(string(bstr)); ver != nil {
+			return ver
 		}
-
-		// An unknown version.
-		return &GoVersion{Name: ver}
 	}
 
 	return nil
@@ -20,6 +20,7 @@ package gore
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -38,8 +39,13 @@ func TestResolvingVersionFromTag(t *testing.T) {
 		{"go1.10.5", false},
 		{"go1.10beta2", false},
 		{"go1.4", false},
+		{"go1.99.3", false},
+		{"go1.99rc1", false},
+		{"go1.21.0-bigcorp", false},
 		{"go1234", true},
 		{"go1.", true},
+		{"go2.0", true},
+		{"go1.22 X:boringcrypto", true},
 	}
 
 	for _, test := range tests {
@@ -54,6 +60,27 @@ func TestResolvingVersionFromTag(t *testing.T) {
 	}
 }
 
+func TestLoadGoVersions(t *testing.T) {
+	t.Cleanup(func() {
+		goversionsMu.Lock()
+		defer goversionsMu.Unlock()
+		delete(goversions, "go1.99.1")
+		delete(goversions, "go1.99.2")
+	})
+
+	err := LoadGoVersions(strings.NewReader("go1.99.1,0123abcd,2030-01-02T03:04:05Z\ngo1.99.2\n"))
+	require.NoError(t, err)
+	assert.Equal(t, &GoVersion{Name: "go1.99.1", SHA: "0123abcd", Timestamp: "2030-01-02T03:04:05Z"}, ResolveGoVersion("go1.99.1"))
+	assert.Equal(t, "go1.99.2", newestKnownGoVersion().Name)
+
+	err = LoadGoVersions(strings.NewReader("go1.99.3\nnot a version\n"))
+	assert.ErrorIs(t, err, ErrInvalidGoVersion)
+	assert.Nil(t, knownGoVersion("go1.99.3"), "no version should be added if one is invalid")
+
+	err = LoadGoVersions(strings.NewReader("go1.99.3,a,b,c\n"))
+	assert.Error(t, err)
+}
+
 func TestMatchGoVersion(t *testing.T) {
 	assert := assert.New(t)
 	padding := "teststringPadding"
@@ -0,0 +1,268 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"strings"
+)
+
+// instrumentationSignal is something in a binary that is only present if it
+// was built with an instrumentation.
+type instrumentationSignal struct {
+	// packages are the packages that are only linked into an instrumented
+	// binary. A package is only a signal if it has functions.
+	packages []string
+	// runtimeFuncs are the runtime functions that are only called by an
+	// instrumented binary. The stubs of the other builds are removed by the
+	// linker.
+	runtimeFuncs []string
+	// symbolPrefixes are the prefixes of the symbols of the instrumentation,
+	// for example the symbols of the C runtime of a sanitizer.
+	symbolPrefixes []string
+	// symbolParts are found anywhere in the name of a symbol.
+	symbolParts []string
+	// symbolRanges are pairs of start and end symbols that are defined in
+	// every binary but only span data in an instrumented binary.
+	symbolRanges [][2]string
+}
+
+var (
+	raceSignal = instrumentationSignal{
+		packages:       []string{"runtime/race"},
+		runtimeFuncs:   []string{"raceinit", "racecall"},
+		symbolPrefixes: []string{"__tsan_"},
+	}
+	msanSignal = instrumentationSignal{
+		packages:       []string{"runtime/msan"},
+		runtimeFuncs:   []string{"msanread", "msanwrite"},
+		symbolPrefixes: []string{"__msan_"},
+	}
+	asanSignal = instrumentationSignal{
+		packages:       []string{"runtime/asan"},
+		runtimeFuncs:   []string{"asanread", "asanwrite"},
+		symbolPrefixes: []string{"__asan_"},
+	}
+	// Since Go 1.20, the counters are placed between runtime.covctrs and
+	// runtime.ecovctrs by the linker, the metadata is in goCover variables of
+	// each package and the runtime writes them with the runtime/coverage
+	// package. Before, the counters are in GoCover variables of each
+	// package. The linker defines runtime.covctrs in every binary, so only a
+	// non-empty range is a signal.
+	coverSignal = instrumentationSignal{
+		packages:       []string{"runtime/coverage", "internal/coverage/cfile"},
+		symbolPrefixes: []string{"go:covmeta"},
+		symbolParts:    []string{".GoCover_", ".GoCover.", ".goCover_"},
+		symbolRanges:   [][2]string{{"runtime.covctrs", "runtime.ecovctrs"}},
+	}
+)
+
+// Instrumentation describes the instrumentation a binary was built with.
+//
+// The race detector, the sanitizers and coverage leave packages and symbols
+// in the binary. Profile-guided optimization only changes how the code is
+// inlined and devirtualized. A devirtualized call is guarded by a
+// comparison of the itab or the function pointer, which looks the same as a
+// type assertion written by hand, so it is not used as evidence and PGO is
+// only reported from the build settings.
+type Instrumentation struct {
+	// Race is true if the binary was built with the race detector, -race.
+	Race bool `json:"race,omitempty"`
+	// MSan is true if the binary was built with the memory sanitizer, -msan.
+	MSan bool `json:"msan,omitempty"`
+	// ASan is true if the binary was built with the address sanitizer,
+	// -asan.
+	ASan bool `json:"asan,omitempty"`
+	// Coverage is true if the binary was built with coverage counters,
+	// -cover.
+	Coverage bool `json:"coverage,omitempty"`
+	// PGO is the profile used for profile-guided optimization, the -pgo
+	// build setting.
+	PGO string `json:"pgo,omitempty"`
+	// Evidence lists what the detections are based on, for example
+	// "race: symbol __tsan_init".
+	Evidence []string `json:"evidence,omitempty"`
+}
+
+// Instrumented returns true if the binary was built with an instrumentation
+// that changes its behaviour. PGO is not counted since it's an optimization.
+func (i *Instrumentation) Instrumented() bool {
+	return i.Race || i.MSan || i.ASan || i.Coverage
+}
+
+// Names returns the names of the instrumentations, as the flags of the go
+// command without the dash.
+func (i *Instrumentation) Names() []string {
+	var ret []string
+	for _, x := range []struct {
+		name string
+		on   bool
+	}{
+		{"race", i.Race},
+		{"msan", i.MSan},
+		{"asan", i.ASan},
+		{"cover", i.Coverage},
+		{"pgo", i.PGO != ""},
+	} {
+		if x.on {
+			ret = append(ret, x.name)
+		}
+	}
+	return ret
+}
+
+// Instrumentation detects if the binary was built with the race detector,
+// the memory or address sanitizer, coverage counters or a PGO profile. The
+// build settings, the packages and the symbols are inspected, so the
+// instrumentations except PGO are also detected if the build information
+// has been removed. An error is returned if neither the packages nor the
+// symbols can be read.
+//
+// The packages and the symbols are only scanned by the first call, the
+// build settings are read by every call.
+func (f *GoFile) Instrumentation() (*Instrumentation, error) {
+	f.scanInstrumentationOnce.Do(func() {
+		f.scannedInstrumentation, f.scanInstrumentationError = f.scanInstrumentation()
+	})
+	if f.scanInstrumentationError != nil {
+		return nil, f.scanInstrumentationError
+	}
+	scanned := f.scannedInstrumentation
+
+	i := &Instrumentation{}
+	if bs := f.BuildInfo.BuildSettings(); bs != nil {
+		for _, s := range []struct {
+			flag    *bool
+			name    string
+			setting bool
+		}{
+			{&i.Race, "race", bs.Race},
+			{&i.MSan, "msan", bs.MSan},
+			{&i.ASan, "asan", bs.ASan},
+			{&i.Coverage, "cover", bs.Cover},
+		} {
+			if s.setting {
+				*s.flag = true
+				i.Evidence = append(i.Evidence, s.name+": build setting -"+s.name)
+			}
+		}
+		if bs.PGO != "" {
+			i.PGO = bs.PGO
+			i.Evidence = append(i.Evidence, "pgo: build setting -pgo="+bs.PGO)
+		}
+	}
+	i.Race = i.Race || scanned.Race
+	i.MSan = i.MSan || scanned.MSan
+	i.ASan = i.ASan || scanned.ASan
+	i.Coverage = i.Coverage || scanned.Coverage
+	i.Evidence = append(i.Evidence, scanned.Evidence...)
+	return i, nil
+}
+
+// scanInstrumentation detects the instrumentation from the packages and the
+// symbols.
+func (f *GoFile) scanInstrumentation() (*Instrumentation, error) {
+	i := &Instrumentation{}
+
+	// The sanitizers are linked from C and their symbols are found even if
+	// the packages can't be enumerated, so only fail if neither is possible.
+	var pkgs []*Package
+	pkgErr := f.initPackages()
+	if pkgErr == nil {
+		for _, class := range [][]*Package{f.stdPkgs, f.generated, f.pkgs, f.vendors, f.unknown} {
+			pkgs = append(pkgs, class...)
+		}
+	}
+	syms, symErr := f.symbols()
+	if pkgErr != nil && symErr != nil {
+		return nil, pkgErr
+	}
+
+	for _, s := range []struct {
+		flag   *bool
+		name   string
+		signal instrumentationSignal
+	}{
+		{&i.Race, "race", raceSignal},
+		{&i.MSan, "msan", msanSignal},
+		{&i.ASan, "asan", asanSignal},
+		{&i.Coverage, "cover", coverSignal},
+	} {
+		for _, evidence := range s.signal.find(pkgs, syms) {
+			*s.flag = true
+			i.Evidence = append(i.Evidence, s.name+": "+evidence)
+		}
+	}
+	return i, nil
+}
+
+// find returns the signals found in the packages and the symbols. Only the
+// first matching symbol in sort order is returned, so the result doesn't
+// depend on the iteration order of the map.
+func (s instrumentationSignal) find(pkgs []*Package, syms map[string]Symbol) []string {
+	var ret []string
+	for _, p := range pkgs {
+		for _, name := range s.packages {
+			if p.Name == name && len(p.Functions)+len(p.Methods) != 0 {
+				ret = append(ret, "package "+name)
+			}
+		}
+		if p.Name != "runtime" {
+			continue
+		}
+		for _, fn := range p.Functions {
+			for _, name := range s.runtimeFuncs {
+				if fn.Name == name {
+					ret = append(ret, "function runtime."+name)
+				}
+			}
+		}
+	}
+
+	match := func(sym string) bool {
+		for _, prefix := range s.symbolPrefixes {
+			if strings.HasPrefix(sym, prefix) {
+				return true
+			}
+		}
+		for _, part := range s.symbolParts {
+			if strings.Contains(sym, part) {
+				return true
+			}
+		}
+		return false
+	}
+	var first string
+	for name := range syms {
+		if match(name) && (first == "" || name < first) {
+			first = name
+		}
+	}
+	if first != "" {
+		ret = append(ret, "symbol "+first)
+	}
+	for _, r := range s.symbolRanges {
+		start, ok := syms[r[0]]
+		if !ok {
+			continue
+		}
+		if end, ok := syms[r[1]]; ok && end.Value > start.Value {
+			ret = append(ret, "symbols "+r[0]+" to "+r[1])
+		}
+	}
+	return ret
+}
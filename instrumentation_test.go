@@ -0,0 +1,112 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"bytes"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentationSignalFind(t *testing.T) {
+	pkgs := []*Package{
+		{Name: "runtime", Functions: []*Function{{Name: "racecall"}, {Name: "main"}}},
+		{Name: "runtime/race"},
+	}
+	syms := map[string]Symbol{"__tsan_write": {}, "__tsan_read": {}, "main.main": {}}
+	assert.Equal(t, []string{"function runtime.racecall", "symbol __tsan_read"}, raceSignal.find(pkgs, syms))
+	assert.Empty(t, msanSignal.find(pkgs, syms))
+
+	syms = map[string]Symbol{"main.GoCover_0_313233": {}}
+	assert.Equal(t, []string{"symbol main.GoCover_0_313233"}, coverSignal.find(nil, syms))
+
+	// The linker defines the counter range in every binary, it's only
+	// a signal if it isn't empty.
+	syms = map[string]Symbol{
+		"runtime.covctrs":  {Name: "runtime.covctrs", Value: 0x5a6728},
+		"runtime.ecovctrs": {Name: "runtime.ecovctrs", Value: 0x5a6728},
+	}
+	assert.Empty(t, coverSignal.find(nil, syms))
+	syms["runtime.ecovctrs"] = Symbol{Name: "runtime.ecovctrs", Value: 0x5a6740}
+	assert.Equal(t, []string{"symbols runtime.covctrs to runtime.ecovctrs"}, coverSignal.find(nil, syms))
+}
+
+func TestInstrumentationBinaries(t *testing.T) {
+	src := "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(\"hello\") }\n"
+
+	f, err := Open(buildTestBinary(t, src))
+	require.NoError(t, err)
+	defer f.Close()
+	i, err := f.Instrumentation()
+	require.NoError(t, err)
+	assert.False(t, i.Instrumented())
+	assert.Empty(t, i.Names())
+	assert.Empty(t, i.Evidence)
+
+	cover, err := Open(buildTestBinary(t, src, "-cover"))
+	require.NoError(t, err)
+	defer cover.Close()
+	i, err = cover.Instrumentation()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cover"}, i.Names())
+	assert.Contains(t, i.Evidence, "cover: symbols runtime.covctrs to runtime.ecovctrs")
+}
+
+func TestFileInstrumentation(t *testing.T) {
+	mod := buildWasmModule(t, []string{"main.main", "__tsan_init"}, []byte("go1.23.2"))
+	f, err := OpenReader(bytes.NewReader(mod))
+	require.NoError(t, err)
+	defer f.Close()
+
+	f.BuildInfo = &BuildInfo{ModInfo: &debug.BuildInfo{
+		Settings: []debug.BuildSetting{
+			{Key: "-cover", Value: "true"},
+			{Key: "-pgo", Value: "/src/default.pgo"},
+		},
+	}}
+	i, err := f.Instrumentation()
+	require.NoError(t, err)
+	assert.True(t, i.Race)
+	assert.True(t, i.Coverage)
+	assert.False(t, i.MSan)
+	assert.False(t, i.ASan)
+	assert.Equal(t, "/src/default.pgo", i.PGO)
+	assert.True(t, i.Instrumented())
+	assert.Equal(t, []string{"race", "cover", "pgo"}, i.Names())
+	assert.Equal(t, []string{
+		"cover: build setting -cover",
+		"pgo: build setting -pgo=/src/default.pgo",
+		"race: symbol __tsan_init",
+	}, i.Evidence)
+
+	// The symbols are only scanned once and the build settings are read
+	// again.
+	scanned := f.scannedInstrumentation
+	require.NotNil(t, scanned)
+	f.BuildInfo = nil
+	i, err = f.Instrumentation()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"race"}, i.Names())
+	assert.Same(t, scanned, f.scannedInstrumentation)
+
+	pgoOnly := &Instrumentation{PGO: "default.pgo"}
+	assert.False(t, pgoOnly.Instrumented())
+}
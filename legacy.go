@@ -0,0 +1,205 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"unicode/utf8"
+
+	"github.com/ZxillyFork/gosym"
+)
+
+// Binaries compiled by Go 1.2 to Go 1.4 use the Go 1.2 PCLN table but have
+// no moduledata structure. The functions are enumerated from the PCLN table
+// and the types are found with the typelinks defined by the linker.
+
+// firstModuledataVersion is the first release with the moduledata structure.
+const firstModuledataVersion = "go1.5beta1"
+
+// minTypelinksRun is the number of types a scanned typelinks table must at
+// least have.
+const minTypelinksRun = 8
+
+var errNoTypelinks = errors.New("no typelinks found")
+
+// hasModuledata returns false if the binary was compiled by a Go release
+// without the moduledata structure. If the version is unknown, the binary is
+// assumed to have one.
+func hasModuledata(fi *FileInfo) bool {
+	return fi.goversion == nil || GoVersionCompare(fi.goversion.Name, firstModuledataVersion) >= 0
+}
+
+// getLinkerSymbol returns a symbol defined by the linker, for example text
+// or pclntab. Since Go 1.4 they are in the runtime package, before they had
+// no package prefix.
+func (f *GoFile) getLinkerSymbol(name string) (Symbol, error) {
+	sym, err := f.fh.getSymbol("runtime." + name)
+	if err == nil {
+		return sym, nil
+	}
+	return f.fh.getSymbol(name)
+}
+
+// getTypesGo12 returns the types of a binary compiled before Go 1.5.
+func (f *GoFile) getTypesGo12() (map[uint64]*GoType, error) {
+	addr, n, err := f.typelinksGo12()
+	if err != nil {
+		return nil, err
+	}
+	return getLegacyTypes(f.FileInfo, f.fh, addr, n)
+}
+
+// typelinksGo12 returns the address and the number of entries of the
+// typelinks. They are located with the symbols, the section the linker puts
+// them in, or if both have been removed by scanning for the table.
+func (f *GoFile) typelinksGo12() (uint64, uint64, error) {
+	wordSize := uint64(f.FileInfo.WordSize)
+	start, err := f.getLinkerSymbol("typelink")
+	if err == nil {
+		end, err := f.getLinkerSymbol("etypelink")
+		if err == nil && end.Value >= start.Value {
+			return start.Value, (end.Value - start.Value) / wordSize, nil
+		}
+	}
+
+	for _, name := range []string{".typelink", "__typelink"} {
+		addr, data, err := f.fh.getSectionData(name)
+		if err == nil {
+			return addr, uint64(len(data)) / wordSize, nil
+		}
+	}
+
+	var sections []string
+	switch f.fh.(type) {
+	case *elfFile:
+		sections = []string{".rodata", ".text"}
+	case *peFile:
+		sections = []string{".rdata", ".text", ".data"}
+	case *machoFile:
+		sections = []string{"__rodata", "__text"}
+	}
+	for _, name := range sections {
+		base, data, err := f.fh.getSectionData(name)
+		if err != nil {
+			continue
+		}
+		if off, n := scanTypelinks(f.FileInfo, data, base); n != 0 {
+			return base + off, n, nil
+		}
+	}
+	return 0, 0, errNoTypelinks
+}
+
+// scanTypelinks searches the section for the typelinks. The table is the
+// longest run of pointers to types in the section, sorted by the type
+// string. The offset and the number of entries are returned, or zero
+// entries if no table is found.
+func scanTypelinks(fi *FileInfo, data []byte, base uint64) (uint64, uint64) {
+	wordSize := fi.WordSize
+	var bestOff, bestLen, runOff, runLen uint64
+	var prev string
+	for i := 0; i+wordSize <= len(data); i += wordSize {
+		name, ok := legacyTypeString(fi, data, base, readWord(data[i:], fi))
+		switch {
+		case ok && runLen != 0 && name >= prev:
+			runLen++
+		case ok:
+			runOff, runLen = uint64(i), 1
+		default:
+			runLen = 0
+		}
+		prev = name
+		if runLen > bestLen {
+			bestOff, bestLen = runOff, runLen
+		}
+	}
+	if bestLen < minTypelinksRun {
+		return 0, 0
+	}
+	return bestOff, bestLen
+}
+
+// legacyTypeString returns the string of the type at the address if it
+// looks like a valid type located in the section.
+func legacyTypeString(fi *FileInfo, data []byte, base, addr uint64) (string, bool) {
+	end := uint64(typeOffset(fi, _typeFieldEnd))
+	if addr < base || addr-base+end > uint64(len(data)) {
+		return "", false
+	}
+	typ := data[addr-base:]
+
+	kind := reflect.Kind(typ[typeOffset(fi, _typeFieldKind)] & kindMask)
+	if kind == reflect.Invalid || kind > reflect.UnsafePointer {
+		return "", false
+	}
+	for _, align := range typ[fi.WordSize+5 : fi.WordSize+7] {
+		if align == 0 || align > 16 || align&(align-1) != 0 {
+			return "", false
+		}
+	}
+
+	hdr := readWord(typ[typeOffset(fi, _typeFieldStr):], fi)
+	if hdr < base || hdr-base+uint64(2*fi.WordSize) > uint64(len(data)) {
+		return "", false
+	}
+	ptr := readWord(data[hdr-base:], fi)
+	n := readWord(data[hdr-base+uint64(fi.WordSize):], fi)
+	if n == 0 || n > 1<<12 || ptr < base || ptr-base+n > uint64(len(data)) {
+		return "", false
+	}
+	str := data[ptr-base : ptr-base+n]
+	if !utf8.Valid(str) || bytes.IndexByte(str, 0) >= 0 {
+		return "", false
+	}
+	return string(str), true
+}
+
+// estimateVersionGo12 returns the earliest release of the Go version that
+// compiled a binary without moduledata or a version string. The version is
+// estimated from the runtime functions. Nil is returned if the binary has a
+// newer runtime.
+//
+// Go 1.3 replaced the segmented stacks with contiguous stacks, and Go 1.4
+// converted the scheduler to Go.
+func estimateVersionGo12(tab *gosym.Table, pclntab []byte, order binary.ByteOrder) *GoVersion {
+	if len(pclntab) < 4 || order.Uint32(pclntab) != gopclntab12magic {
+		return nil
+	}
+	if tab.LookupFunc("runtime.moduledataverify") != nil {
+		return nil
+	}
+	hasFunc := func(names ...string) bool {
+		for _, name := range names {
+			if tab.LookupFunc(name) != nil {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case hasFunc("runtime.gopark"):
+		return ResolveGoVersion("go1.4")
+	case hasFunc("runtime.copystack", "copystack", "runtime.shrinkstack"):
+		return ResolveGoVersion("go1.3")
+	default:
+		return ResolveGoVersion("go1.2")
+	}
+}
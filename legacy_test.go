@@ -0,0 +1,135 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/ZxillyFork/gosym"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypeOffsetGo12(t *testing.T) {
+	for _, test := range []struct {
+		version  string
+		wordSize int
+		str, end int64
+	}{
+		{"go1.2.2", intSize64, 32, 56},
+		{"go1.3.3", intSize64, 32, 64},
+		{"go1.4.2", intSize64, 40, 72},
+		{"go1.4.2", intSize32, 24, 40},
+		{"go1.5.4", intSize64, 40, 72},
+	} {
+		t.Run(fmt.Sprintf("%s/%d", test.version, test.wordSize), func(t *testing.T) {
+			fi := &FileInfo{WordSize: test.wordSize, goversion: &GoVersion{Name: test.version}}
+			assert.Equal(t, test.str, typeOffset(fi, _typeFieldStr))
+			assert.Equal(t, test.end, typeOffset(fi, _typeFieldEnd))
+		})
+	}
+
+	fi := &FileInfo{WordSize: intSize64, goversion: &GoVersion{Name: "go1.4.2"}}
+	assert.Equal(t, int64(15), typeOffset(fi, _typeFieldKind))
+	assert.Equal(t, int64(12), typeOffset(fi, _typeFieldFlag))
+}
+
+func TestScanTypelinks(t *testing.T) {
+	const (
+		base     = 0x1000
+		nTypes   = 10
+		linksOff = 0x10
+		typesOff = 0x100
+		strsOff  = 0x800
+		dataOff  = 0xa00
+	)
+	fi := &FileInfo{WordSize: intSize64, ByteOrder: binary.LittleEndian, goversion: &GoVersion{Name: "go1.4.2"}}
+	data := make([]byte, 0xc00)
+	put := binary.LittleEndian.PutUint64
+
+	for i := 0; i < nTypes; i++ {
+		typ := typesOff + i*0x60
+		put(data[linksOff+i*8:], uint64(base+typ))
+		put(data[typ:], 8)
+		data[typ+13], data[typ+14] = 8, 8
+		data[typ+15] = uint8(reflect.Int)
+		put(data[typ+40:], uint64(base+strsOff+i*16))
+
+		name := fmt.Sprintf("main.T%d", i)
+		put(data[strsOff+i*16:], uint64(base+dataOff+i*16))
+		put(data[strsOff+i*16+8:], uint64(len(name)))
+		copy(data[dataOff+i*16:], name)
+	}
+
+	name, ok := legacyTypeString(fi, data, base, base+typesOff)
+	require.True(t, ok)
+	assert.Equal(t, "main.T0", name)
+	_, ok = legacyTypeString(fi, data, base, base+strsOff)
+	assert.False(t, ok)
+
+	off, n := scanTypelinks(fi, data, base)
+	assert.Equal(t, uint64(linksOff), off)
+	assert.Equal(t, uint64(nTypes), n)
+
+	// The table is sorted by the type string, so a run that isn't sorted
+	// is not a table.
+	put(data[linksOff:], uint64(base+typesOff+(nTypes-1)*0x60))
+	off, n = scanTypelinks(fi, data, base)
+	assert.Equal(t, uint64(linksOff+8), off)
+	assert.Equal(t, uint64(nTypes-1), n)
+
+	_, n = scanTypelinks(fi, data[:linksOff+(minTypelinksRun-1)*8], base)
+	assert.Zero(t, n)
+}
+
+func TestEstimateVersionGo12(t *testing.T) {
+	pclntab := binary.LittleEndian.AppendUint32(nil, gopclntab12magic)
+	table := func(names ...string) *gosym.Table {
+		tab := &gosym.Table{}
+		for _, name := range names {
+			tab.Funcs = append(tab.Funcs, gosym.Func{Sym: &gosym.Sym{Name: name}})
+		}
+		return tab
+	}
+
+	for _, test := range []struct {
+		funcs    []string
+		expected string
+	}{
+		{[]string{"runtime.main", "runtime.oldstack"}, "go1.2"},
+		{[]string{"runtime.main", "runtime.shrinkstack"}, "go1.3"},
+		{[]string{"runtime.main", "runtime.gopark", "runtime.shrinkstack"}, "go1.4"},
+	} {
+		ver := estimateVersionGo12(table(test.funcs...), pclntab, binary.LittleEndian)
+		require.NotNil(t, ver)
+		assert.Equal(t, test.expected, ver.Name)
+	}
+
+	assert.Nil(t, estimateVersionGo12(table("runtime.gopark", "runtime.moduledataverify"), pclntab, binary.LittleEndian))
+	assert.Nil(t, estimateVersionGo12(table("runtime.gopark"), binary.LittleEndian.AppendUint32(nil, gopclntab116magic), binary.LittleEndian))
+}
+
+func TestHasModuledata(t *testing.T) {
+	assert.True(t, hasModuledata(&FileInfo{}))
+	assert.True(t, hasModuledata(&FileInfo{goversion: &GoVersion{Name: "go1.5"}}))
+	assert.False(t, hasModuledata(&FileInfo{goversion: &GoVersion{Name: "go1.4.3"}}))
+	assert.False(t, hasModuledata(&FileInfo{goversion: &GoVersion{Name: "go1.2"}}))
+}
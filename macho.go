@@ -23,6 +23,7 @@ import (
 	"compress/zlib"
 	"debug/dwarf"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"slices"
@@ -170,7 +171,11 @@ func (m *machoFile) getFileInfo() *FileInfo {
 }
 
 func (m *machoFile) getPCLNTABData() (uint64, []byte, error) {
-	return m.getSectionData("__gopclntab")
+	addr, data, err := m.getSectionData("__gopclntab")
+	if errors.Is(err, ErrSectionDoesNotExist) {
+		return 0, nil, ErrNoPCLNTab
+	}
+	return addr, data, err
 }
 
 func (m *machoFile) moduledataSection() string {
@@ -21,7 +21,12 @@ import (
 	"debug/buildinfo"
 	"errors"
 	"fmt"
+	"net/url"
+	"path"
 	"runtime/debug"
+	"sort"
+	"strings"
+	"unicode"
 )
 
 var (
@@ -36,6 +41,9 @@ type BuildInfo struct {
 	// ModInfo holds information about the Go modules in this file.
 	// Can be nil.
 	ModInfo *debug.BuildInfo
+	// Inferred is true if ModInfo was reconstructed by InferBuildInfo
+	// instead of read from the file.
+	Inferred bool
 }
 
 func (f *GoFile) extractBuildInfo() (*BuildInfo, error) {
@@ -45,9 +53,253 @@ func (f *GoFile) extractBuildInfo() (*BuildInfo, error) {
 	}
 
 	result := &BuildInfo{
-		Compiler: ResolveGoVersion(info.GoVersion),
+		Compiler: resolveBaseGoVersion(info.GoVersion),
 		ModInfo:  info,
 	}
 
 	return result, nil
 }
+
+// goExperiments returns the experiments listed in the version string, for
+// example "go1.24 X:noswissmap", and in the GOEXPERIMENT build setting.
+func goExperiments(info *debug.BuildInfo) []string {
+	var exps []string
+	if _, x, ok := strings.Cut(info.GoVersion, " X:"); ok {
+		exps = append(exps, strings.Split(x, ",")...)
+	}
+	for _, s := range info.Settings {
+		if s.Key == "GOEXPERIMENT" && s.Value != "" {
+			exps = append(exps, strings.Split(s.Value, ",")...)
+		}
+	}
+	return exps
+}
+
+// InferBuildInfo reconstructs the module information from the file paths of
+// the packages that are not part of the standard library. It is intended
+// for files where the build information can't be read, for example files
+// built before Go 1.13 or files where it has been stripped. The returned
+// BuildInfo is marked as inferred.
+//
+// The packages are grouped into modules based on their file paths:
+//
+//   - packages in the module cache, pkg@vX.Y.Z/..., also give the version.
+//   - packages in a GOPATH, src/host/owner/repo/..., and packages in a vendor
+//     folder are grouped by the repository root of the import path. The
+//     version is only known for gopkg.in paths where the major version is
+//     part of the path.
+//
+// The main module is only set if it can be derived from the main package's
+// file path in the same way.
+func (f *GoFile) InferBuildInfo() (*BuildInfo, error) {
+	mainPkgs, err := f.GetPackages()
+	if err != nil {
+		return nil, err
+	}
+	vendors, err := f.GetVendors()
+	if err != nil {
+		return nil, err
+	}
+	unknown, err := f.GetUnknown()
+	if err != nil {
+		return nil, err
+	}
+
+	// Vendored packages in a GOPATH project can be classified as part of
+	// the main package, so all classes are used.
+	var pkgs []*Package
+	for _, class := range [][]*Package{vendors, mainPkgs, unknown} {
+		pkgs = append(pkgs, class...)
+	}
+	bi := &debug.BuildInfo{Deps: inferModules(pkgs)}
+	for _, p := range mainPkgs {
+		if p.Name != "main" {
+			continue
+		}
+		if mod, pkgPath, ok := inferMainModule(p); ok {
+			bi.Main = *mod
+			if bi.Main.Version == "" {
+				bi.Main.Version = "(devel)"
+			}
+			bi.Path = pkgPath
+		}
+	}
+	if bi.Main.Path != "" {
+		// Packages of the main module found in the vendor packages.
+		for i, dep := range bi.Deps {
+			if dep.Path == bi.Main.Path {
+				bi.Deps = append(bi.Deps[:i], bi.Deps[i+1:]...)
+				break
+			}
+		}
+	}
+
+	result := &BuildInfo{ModInfo: bi, Inferred: true}
+	if v, err := f.GetCompilerVersion(); err == nil {
+		result.Compiler = v
+		bi.GoVersion = v.Name
+	}
+	return result, nil
+}
+
+// inferModules groups the packages by module. If the module of a package is
+// seen with and without a version, the version is kept.
+func inferModules(pkgs []*Package) []*debug.Module {
+	mods := make(map[string]*debug.Module)
+	for _, p := range pkgs {
+		mod, ok := inferModule(p)
+		if !ok {
+			continue
+		}
+		if m, ok := mods[mod.Path]; ok && m.Version != "" {
+			continue
+		}
+		mods[mod.Path] = mod
+	}
+
+	result := make([]*debug.Module, 0, len(mods))
+	for _, m := range mods {
+		result = append(result, m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result
+}
+
+// inferModule returns the module the package belongs to.
+func inferModule(p *Package) (*debug.Module, bool) {
+	if mod, ver, ok := moduleFromPath(p.Name, p.Filepath); ok {
+		return &debug.Module{Path: mod, Version: ver}, true
+	}
+
+	// Dots in the last path element are escaped in symbol names.
+	importPath, err := url.PathUnescape(p.Name)
+	if err != nil {
+		importPath = p.Name
+	}
+	if i := strings.LastIndex(importPath, "vendor/"); i != -1 {
+		// Pre-module vendoring has the vendor folder in the import path.
+		importPath = importPath[i+len("vendor/"):]
+	}
+	return repoModule(importPath)
+}
+
+// inferMainModule returns the module and the import path of the main package.
+// The import path is not in the symbols so it has to be derived from the file
+// path, either from the module cache if it was installed with
+// "go install pkg@version" or from the GOPATH.
+func inferMainModule(p *Package) (*debug.Module, string, bool) {
+	if i := strings.LastIndex(p.Filepath, "/pkg/mod/"); i != -1 {
+		fp := p.Filepath[i+len("/pkg/mod/"):]
+		if j := strings.Index(fp, "@v"); j != -1 {
+			mod := &debug.Module{Path: unescapeModulePath(fp[:j])}
+			var subdir string
+			mod.Version, subdir, _ = strings.Cut(fp[j+1:], "/")
+			return mod, path.Join(mod.Path, subdir), true
+		}
+	}
+	i := strings.LastIndex(p.Filepath, "/src/")
+	if i == -1 {
+		return nil, "", false
+	}
+	pkgPath := p.Filepath[i+len("/src/"):]
+	mod, ok := repoModule(pkgPath)
+	return mod, pkgPath, ok
+}
+
+// repoModule returns the module for the repository root of the import path.
+func repoModule(importPath string) (*debug.Module, bool) {
+	root, ok := repoRoot(importPath)
+	if !ok {
+		return nil, false
+	}
+	mod := &debug.Module{Path: root}
+	if strings.HasPrefix(root, "gopkg.in/") {
+		if i := strings.LastIndex(root, ".v"); i != -1 {
+			mod.Version = root[i+1:]
+		}
+	}
+	return mod, true
+}
+
+// repoRoot returns the repository root of an import path for the code hosting
+// sites where the structure of the path is known.
+func repoRoot(importPath string) (string, bool) {
+	parts := strings.Split(importPath, "/")
+	n := 0
+	switch parts[0] {
+	case "github.com", "gitlab.com", "bitbucket.org", "golang.org":
+		// host/owner/repo or golang.org/x/repo
+		n = 3
+	case "gopkg.in":
+		// gopkg.in/pkg.v1 or gopkg.in/user/pkg.v1
+		n = 2
+		if len(parts) > 2 && !strings.Contains(parts[1], ".v") {
+			n = 3
+		}
+	case "google.golang.org", "go.uber.org", "go.etcd.io", "k8s.io", "cloud.google.com", "go.opentelemetry.io":
+		n = 2
+	default:
+		return "", false
+	}
+	if len(parts) < n {
+		return "", false
+	}
+	return strings.Join(parts[:n], "/"), true
+}
+
+// moduleFromPath extracts the module path and version from the file path of
+// a package in the module cache, for example:
+//
+//	/home/user/go/pkg/mod/github.com/!burnt!sushi/toml@v1.2.0/internal
+//
+// The module path is derived from the import path of the package by removing
+// the directories below the module root.
+func moduleFromPath(importPath, filepath string) (string, string, bool) {
+	i := strings.Index(filepath, "@v")
+	if i == -1 {
+		return "", "", false
+	}
+	version, subdir, _ := strings.Cut(filepath[i+1:], "/")
+
+	mod := importPath
+	if subdir != "" {
+		n := strings.Count(subdir, "/") + 1
+		for ; n > 0; n-- {
+			mod = path.Dir(mod)
+		}
+		if mod == "." {
+			return "", "", false
+		}
+	}
+
+	// Check the import path against the escaped module path in the file path.
+	root := unescapeModulePath(filepath[:i])
+	if root != mod && !strings.HasSuffix(root, "/"+mod) {
+		return "", "", false
+	}
+	return mod, version, true
+}
+
+// unescapeModulePath reverses the escaping used by the module cache, where
+// upper case letters are written as "!" followed by the lower case letter.
+func unescapeModulePath(p string) string {
+	if !strings.Contains(p, "!") {
+		return p
+	}
+	var b strings.Builder
+	upper := false
+	for _, r := range p {
+		if r == '!' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -19,9 +19,13 @@ package gore
 
 import (
 	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
 	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -86,3 +90,113 @@ func TestBuildInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestModuleFromPath(t *testing.T) {
+	tests := []struct {
+		pkg, filepath string
+		mod, version  string
+		ok            bool
+	}{
+		{"github.com/BurntSushi/toml/internal", "/home/user/go/pkg/mod/github.com/!burnt!sushi/toml@v1.2.0/internal", "github.com/BurntSushi/toml", "v1.2.0", true},
+		{"golang.org/x/net/http2", "golang.org/x/net@v0.30.0/http2", "golang.org/x/net", "v0.30.0", true},
+		{"example.com/m", "/go/pkg/mod/example.com/m@v0.0.0-20240101000000-abcdef123456", "example.com/m", "v0.0.0-20240101000000-abcdef123456", true},
+		{"github.com/a/b", "/home/user/src/github.com/a/b", "", "", false},
+		{"example.com/other", "/go/pkg/mod/example.com/m@v1.0.0", "", "", false},
+		{"foo/bar", "/go/pkg/mod/example.com/xfoo@v1.0.0/bar", "", "", false},
+	}
+	for _, test := range tests {
+		mod, version, ok := moduleFromPath(test.pkg, test.filepath)
+		assert.Equal(t, test.ok, ok, test.filepath)
+		assert.Equal(t, test.mod, mod, test.filepath)
+		assert.Equal(t, test.version, version, test.filepath)
+	}
+}
+
+func TestInferModules(t *testing.T) {
+	pkgs := []*Package{
+		{Name: "github.com/BurntSushi/toml", Filepath: "/go/pkg/mod/github.com/!burnt!sushi/toml@v1.2.0"},
+		{Name: "github.com/BurntSushi/toml/internal", Filepath: "/go/pkg/mod/github.com/!burnt!sushi/toml@v1.2.0/internal"},
+		{Name: "github.com/pkg/errors", Filepath: "/home/user/go/src/github.com/pkg/errors"},
+		{Name: "golang.org/x/net/http2/hpack", Filepath: "/home/user/go/src/golang.org/x/net/http2/hpack"},
+		{Name: "github.com/me/app/vendor/gopkg.in/yaml%2ev2", Filepath: "/home/user/go/src/github.com/me/app/vendor/gopkg.in/yaml.v2"},
+		{Name: "example.com/unknown/pkg", Filepath: "/somewhere/unknown/pkg"},
+	}
+	assert.Equal(t, []*debug.Module{
+		{Path: "github.com/BurntSushi/toml", Version: "v1.2.0"},
+		{Path: "github.com/pkg/errors"},
+		{Path: "golang.org/x/net"},
+		{Path: "gopkg.in/yaml.v2", Version: "v2"},
+	}, inferModules(pkgs))
+}
+
+func TestInferMainModule(t *testing.T) {
+	tests := []struct {
+		filepath string
+		mod      *debug.Module
+		pkgPath  string
+		ok       bool
+	}{
+		{"/home/user/go/pkg/mod/github.com/!me/tool@v1.0.0/cmd/tool", &debug.Module{Path: "github.com/Me/tool", Version: "v1.0.0"}, "github.com/Me/tool/cmd/tool", true},
+		{"/home/user/go/src/github.com/me/app/cmd/app", &debug.Module{Path: "github.com/me/app"}, "github.com/me/app/cmd/app", true},
+		{"/home/user/projects/app", nil, "", false},
+	}
+	for _, test := range tests {
+		mod, pkgPath, ok := inferMainModule(&Package{Name: "main", Filepath: test.filepath})
+		assert.Equal(t, test.ok, ok, test.filepath)
+		assert.Equal(t, test.mod, mod, test.filepath)
+		assert.Equal(t, test.pkgPath, pkgPath, test.filepath)
+	}
+}
+
+func TestInferBuildInfoGOPATH(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("No go tool chain found: " + err.Error())
+	}
+	r := require.New(t)
+
+	gopath := t.TempDir()
+	files := map[string]string{
+		"src/github.com/me/app/main.go": `package main
+
+import (
+	"fmt"
+
+	"github.com/foo/bar/sub"
+	"gopkg.in/yaml.v2"
+)
+
+func main() {
+	fmt.Println(sub.Hello(), yaml.Marshal())
+}
+`,
+		"src/github.com/foo/bar/sub/sub.go":                     "package sub\n\n//go:noinline\nfunc Hello() string { return \"hello\" }\n",
+		"src/github.com/me/app/vendor/gopkg.in/yaml.v2/yaml.go": "package yaml\n\n//go:noinline\nfunc Marshal() string { return \"yaml\" }\n",
+	}
+	for name, src := range files {
+		p := filepath.Join(gopath, name)
+		r.NoError(os.MkdirAll(filepath.Dir(p), 0755))
+		r.NoError(os.WriteFile(p, []byte(src), 0644))
+	}
+
+	exe := filepath.Join(gopath, "app")
+	cmd := exec.Command(goBin, "build", "-o", exe, ".")
+	cmd.Dir = filepath.Join(gopath, "src", "github.com", "me", "app")
+	cmd.Env = append(os.Environ(), "GO111MODULE=off", "GOPATH="+gopath, "GOOS=linux", "GOARCH=amd64", "CGO_ENABLED=0")
+	out, err := cmd.CombinedOutput()
+	r.NoError(err, "building test executable failed: "+string(out))
+
+	f, err := Open(exe)
+	r.NoError(err)
+	defer f.Close()
+
+	bi, err := f.InferBuildInfo()
+	r.NoError(err)
+	r.True(bi.Inferred)
+	r.Equal("github.com/me/app", bi.ModInfo.Path)
+	r.Equal(debug.Module{Path: "github.com/me/app", Version: "(devel)"}, bi.ModInfo.Main)
+	r.Equal([]*debug.Module{
+		{Path: "github.com/foo/bar"},
+		{Path: "gopkg.in/yaml.v2", Version: "v2"},
+	}, bi.ModInfo.Deps)
+}
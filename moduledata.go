@@ -265,84 +265,210 @@ func pickVersionedModuleData(info *FileInfo) (modulable, error) {
 }
 
 func extractModuledata(f *GoFile) (moduledata, error) {
+	if f.FileInfo.Compiler != CompilerGc || !hasModuledata(f.FileInfo) {
+		return moduledata{}, ErrNoModuledata
+	}
 	vmd, err := pickVersionedModuleData(f.FileInfo)
+	if err != nil {
+		if f.FileInfo.goversion != nil && !newerThanKnownLayouts(f.FileInfo.goversion.Name) {
+			return moduledata{}, err
+		}
+		if f.versionError != nil {
+			err = f.versionError
+		}
+		return probeModuledata(f, err)
+	}
+
+	textStart, textData, err := f.fh.getCodeSection()
 	if err != nil {
 		return moduledata{}, err
 	}
+	textEnd := textStart + uint64(len(textData))
 
-	vmdSize := binary.Size(vmd)
+	// Take a simple validation step to ensure that the moduledata is valid.
+	return findModuledata(f, vmd, func(md moduledata) bool {
+		return md.TextAddr <= md.TextAddr+md.TextLen && textStart <= md.TextAddr && md.TextAddr < textEnd
+	})
+}
 
-	// pre define these variables to follow the goto requirements
-	var off int
-	var magic []byte
-	var tabAddr uint64
+// newerThanKnownLayouts returns true if the version is newer than the
+// newest version with a known moduledata layout.
+func newerThanKnownLayouts(version string) bool {
+	return GoVersionCompare(version, fmt.Sprintf("go1.%d", newestModuleDataVersion+1)) >= 0
+}
 
-	secAddr, secData, err := f.fh.getSectionData(f.fh.moduledataSection())
+// probeModuledata is used when the Go version is not known or newer than the
+// versions with a known layout. The layouts that match the PCLN table version
+// are tried from the newest to the oldest and the first one that passes a
+// strict validation is used. Which layout matched is reported as a warning.
+func probeModuledata(f *GoFile, cause error) (moduledata, error) {
+	bits := 64
+	if f.FileInfo.WordSize == intSize32 {
+		bits = 32
+	}
+
+	textStart, textData, err := f.fh.getCodeSection()
 	if err != nil {
 		return moduledata{}, err
 	}
+	textEnd := textStart + uint64(len(textData))
 
-	// if we can get the moduledata addr from the symbol, we have no need to search
-	sym, err := f.fh.getSymbol("runtime.firstmoduledata")
-	if err == nil {
-		off = int(sym.Value - secAddr)
-		goto load
+	lo, hi := moduledataCandidates(f)
+	for v := hi; v >= lo; v-- {
+		vmd, err := selectModuleData(v, bits)
+		if err != nil {
+			continue
+		}
+		md, err := findModuledata(f, vmd, func(md moduledata) bool {
+			return validModuledata(f, md, v, textStart, textEnd)
+		})
+		if err != nil {
+			continue
+		}
+		version := f.goVersionName()
+		if version == "" {
+			version = "an unknown Go version"
+		}
+		f.warn("the moduledata layout of go1.%d is used for %s", v, version)
+		return md, nil
 	}
+	return moduledata{}, fmt.Errorf("no known moduledata layout matches: %w", cause)
+}
 
-	err = f.initPclntab()
-	if err != nil {
-		return moduledata{}, err
+// moduledataCandidates returns the range of minor versions with a moduledata
+// layout that can be used with the file's PCLN table.
+func moduledataCandidates(f *GoFile) (int, int) {
+	lo, hi := 5, newestModuleDataVersion
+	if f.initPclntab() != nil || len(f.pclntabBytes) < 4 {
+		return lo, hi
+	}
+	switch f.FileInfo.ByteOrder.Uint32(f.pclntabBytes) {
+	case gopclntab120magic:
+		lo = 20
+	case gopclntab118magic:
+		lo, hi = 18, 19
+	case gopclntab116magic:
+		lo, hi = 16, 17
+	case gopclntab12magic:
+		hi = 15
+	}
+	return lo, hi
+}
+
+// validModuledata checks that the text section is within the code section,
+// that the types are in the file and that the function table is sorted.
+// The layout version, v, determines the size of the function table entries.
+func validModuledata(f *GoFile, md moduledata, v int, textStart, textEnd uint64) bool {
+	if md.TextLen == 0 || md.TextAddr < textStart || md.TextAddr+md.TextLen > textEnd || md.TextAddr+md.TextLen < md.TextAddr {
+		return false
 	}
-	tabAddr = f.pclntabAddr
 
-	magic = buildPclnTabAddrBinary(f.FileInfo.WordSize, f.FileInfo.ByteOrder, tabAddr)
+	// The types are stored in the file since Go 1.7.
+	if v >= 7 {
+		if md.TypesLen == 0 || md.TypesAddr+md.TypesLen < md.TypesAddr {
+			return false
+		}
+		if _, _, err := f.fh.getSectionDataFromAddress(md.TypesAddr); err != nil {
+			return false
+		}
+		if _, _, err := f.fh.getSectionDataFromAddress(md.TypesAddr + md.TypesLen - 1); err != nil {
+			return false
+		}
+	}
 
-search:
-	off = bytes.Index(secData, magic)
-load:
-	if off == -1 {
-		return moduledata{}, errors.New("could not find moduledata")
+	// Since Go 1.18 the function table entries are two 32-bit offsets from
+	// the start of the text section, before that two pointer sized values.
+	entrySize := uint64(2 * f.FileInfo.WordSize)
+	if v >= 18 {
+		entrySize = 8
+	}
+	if md.FuncTabLen == 0 || md.FuncTabLen > md.TextLen {
+		return false
 	}
-	if len(secData) < off+vmdSize {
-		return moduledata{}, fmt.Errorf("offset %d is out of bounds %d", off, len(secData))
+	base, data, err := f.fh.getSectionDataFromAddress(md.FuncTabAddr)
+	if err != nil || md.FuncTabAddr-base+md.FuncTabLen*entrySize > uint64(len(data)) {
+		return false
 	}
+	tab := data[md.FuncTabAddr-base:]
+	var prev uint64
+	for i := uint64(0); i < md.FuncTabLen; i++ {
+		var entry uint64
+		if v >= 18 {
+			entry = uint64(f.FileInfo.ByteOrder.Uint32(tab[i*entrySize:]))
+		} else {
+			entry = pclntabWord(tab, int(i*entrySize), f.FileInfo.WordSize, f.FileInfo.ByteOrder)
+		}
+		if entry < prev {
+			return false
+		}
+		prev = entry
+	}
+	return true
+}
 
-	data := secData[off : off+vmdSize]
+// findModuledata locates the moduledata structure and reads it with the
+// layout. The structure is located with the "runtime.firstmoduledata"
+// symbol if it is present and valid, otherwise the section where it is
+// stored is searched for the address of the PCLN table. Candidates that
+// are not valid are skipped.
+func findModuledata(f *GoFile, vmd modulable, valid func(moduledata) bool) (moduledata, error) {
+	vmdSize := binary.Size(vmd)
 
-	// Read the module struct from the file.
-	r := bytes.NewReader(data)
-	err = binary.Read(r, f.FileInfo.ByteOrder, vmd)
-	if err != nil {
-		return moduledata{}, fmt.Errorf("error when reading module data from file: %w", err)
+	// If we can get the moduledata addr from the symbol, we have no need to search.
+	if sym, err := f.fh.getSymbol("runtime.firstmoduledata"); err == nil {
+		// The structure isn't always stored in the section that is searched.
+		base, data, err := f.fh.getSectionDataFromAddress(sym.Value)
+		if err == nil && sym.Value-base+uint64(vmdSize) <= uint64(len(data)) {
+			md, err := readModuledata(f, vmd, data[sym.Value-base:])
+			if err == nil && valid(md) {
+				return md, nil
+			}
+		}
 	}
 
-	// Convert the read struct to the type we return to the caller.
-	md := vmd.toModuledata()
-
-	// Take a simple validation step to ensure that the moduledata is valid.
-	text := md.TextAddr
-	etext := md.TextAddr + md.TextLen
+	if err := f.initPclntab(); err != nil {
+		return moduledata{}, err
+	}
+	magic := buildPclnTabAddrBinary(f.FileInfo.WordSize, f.FileInfo.ByteOrder, f.pclntabAddr)
 
-	textSectAddr, textSect, err := f.fh.getCodeSection()
+	_, secData, err := f.fh.getSectionData(f.fh.moduledataSection())
 	if err != nil {
 		return moduledata{}, err
 	}
-	if text > etext {
-		goto invalidMD
+	for {
+		off := bytes.Index(secData, magic)
+		if off == -1 {
+			return moduledata{}, errors.New("could not find moduledata")
+		}
+		if len(secData) < off+vmdSize {
+			return moduledata{}, fmt.Errorf("offset %d is out of bounds %d", off, len(secData))
+		}
+		md, err := readModuledata(f, vmd, secData[off:])
+		if err != nil {
+			return moduledata{}, err
+		}
+		if valid(md) {
+			return md, nil
+		}
+		secData = secData[off+1:]
 	}
+}
 
-	if !(textSectAddr <= text && text < textSectAddr+uint64(len(textSect))) {
-		goto invalidMD
+// readModuledata reads the structure at the start of the data with the layout.
+func readModuledata(f *GoFile, vmd modulable, data []byte) (moduledata, error) {
+	// Read the module struct from the file.
+	r := bytes.NewReader(data[:binary.Size(vmd)])
+	err := binary.Read(r, f.FileInfo.ByteOrder, vmd)
+	if err != nil {
+		return moduledata{}, fmt.Errorf("error when reading module data from file: %w", err)
 	}
 
+	// Convert the read struct to the type we return to the caller.
+	md := vmd.toModuledata()
+
 	// Add the file handler.
 	md.fh = f.fh
-
 	return md, nil
-
-invalidMD:
-	secData = secData[off+1:]
-	goto search
 }
 
 func readUIntTo64(r io.Reader, byteOrder binary.ByteOrder, is32bit bool) (addr uint64, err error) {
@@ -2263,3 +2263,6 @@ func selectModuleData(v int, bits int) (modulable, error) {
 		return nil, fmt.Errorf("unsupported version %d and bits %d", v, bits)
 	}
 }
+
+// newestModuleDataVersion is the newest minor version with a known layout.
+const newestModuleDataVersion = 23
@@ -18,9 +18,14 @@
 package gore
 
 import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
 	"path/filepath"
+	"reflect"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -91,3 +96,88 @@ func TestModuledata(t *testing.T) {
 		})
 	}
 }
+
+// moduledataOverride serves a moduledata structure from an address outside
+// of the file.
+type moduledataOverride struct {
+	fileHandler
+	addr uint64
+	data []byte
+}
+
+func (m *moduledataOverride) getSymbol(name string) (Symbol, error) {
+	if name == "runtime.firstmoduledata" {
+		return Symbol{Name: name, Value: m.addr}, nil
+	}
+	return m.fileHandler.getSymbol(name)
+}
+
+func (m *moduledataOverride) getSectionDataFromAddress(a uint64) (uint64, []byte, error) {
+	if m.addr <= a && a < m.addr+uint64(len(m.data)) {
+		return m.addr, m.data, nil
+	}
+	return m.fileHandler.getSectionDataFromAddress(a)
+}
+
+func TestProbeModuledata(t *testing.T) {
+	exe := buildTestBinary(t, testresourcesrc)
+	f, err := Open(exe)
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, f.initPclntab())
+
+	sym := func(name string) uint64 {
+		s, err := f.fh.getSymbol(name)
+		require.NoError(t, err)
+		return s.Value
+	}
+	text, etext := sym("runtime.text"), sym("runtime.etext")
+	types, etypes := sym("runtime.types"), sym("runtime.etypes")
+	word := func(i int) uint64 { return binary.LittleEndian.Uint64(f.pclntabBytes[8+8*i:]) }
+	nfunc, functab := word(0), word(7)
+
+	// Write the structure with the newest known layout.
+	vmd, err := selectModuleData(newestModuleDataVersion, 64)
+	require.NoError(t, err)
+	v := reflect.ValueOf(vmd).Elem()
+	for name, val := range map[string]uint64{
+		"PcHeader": f.pclntabAddr,
+		"Text":     text,
+		"Etext":    etext,
+		"Types":    types,
+		"Etypes":   etypes,
+		"Ftab":     f.pclntabAddr + functab,
+		"Ftablen":  nfunc + 1,
+	} {
+		v.FieldByName(name).SetUint(val)
+	}
+	buf := &bytes.Buffer{}
+	require.NoError(t, binary.Write(buf, binary.LittleEndian, vmd))
+
+	f.fh = &moduledataOverride{fileHandler: f.fh, addr: 0x10, data: buf.Bytes()}
+	f.FileInfo.goversion = &GoVersion{Name: "go1.99.1"}
+	_, err = f.Moduledata()
+	require.NoError(t, err)
+	md := f.moduledata
+	assert.Equal(t, text, md.Text().Address)
+	assert.Equal(t, etext-text, md.Text().Length)
+	assert.Equal(t, types, md.Types().Address)
+	require.Len(t, f.Warnings(), 1)
+	assert.Contains(t, f.Warnings()[0], fmt.Sprintf("go1.%d", newestModuleDataVersion))
+	assert.Contains(t, f.Warnings()[0], f.goVersionName())
+
+	textStart, textData, err := f.fh.getCodeSection()
+	require.NoError(t, err)
+	textEnd := textStart + uint64(len(textData))
+	assert.True(t, validModuledata(f, md, newestModuleDataVersion, textStart, textEnd))
+
+	bad := md
+	bad.TextLen = textEnd
+	assert.False(t, validModuledata(f, bad, newestModuleDataVersion, textStart, textEnd), "text")
+	bad = md
+	bad.TypesLen = 1 << 40
+	assert.False(t, validModuledata(f, bad, newestModuleDataVersion, textStart, textEnd), "types")
+	bad = md
+	bad.FuncTabAddr = md.TextAddr
+	assert.False(t, validModuledata(f, bad, newestModuleDataVersion, textStart, textEnd), "functab")
+}
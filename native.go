@@ -0,0 +1,282 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"debug/dwarf"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ZxillyFork/gosym"
+)
+
+// Binaries compiled by gccgo and TinyGo don't have a PCLN table. Their
+// functions are recovered from the symbols and the DWARF data.
+
+var errNoNativeFunctions = errors.New("no functions found in the symbols or the DWARF data")
+
+// nativeFunc is a function of a binary without a PCLN table.
+type nativeFunc struct {
+	// name is the demangled name, for example github.com/pkg.T.Method.
+	name  string
+	entry uint64
+	end   uint64
+	// file, line and endLine are the source lines from the DWARF data.
+	file    string
+	line    int
+	endLine int
+}
+
+// nativeNaming describes how a compiler names the functions.
+type nativeNaming struct {
+	// demangle decodes a symbol name.
+	demangle func(string) string
+	// isFunction returns false for the symbols that are not functions of a
+	// Go package.
+	isFunction func(string) bool
+	// split splits a demangled name into the package, the receiver type
+	// and the name.
+	split func(string) (string, string, string)
+}
+
+// compiler returns the compiler that produced the binary. A binary with
+// build information or a PCLN table is compiled by gc. The DWARF data and
+// the symbols are slow to parse, so they are only searched for the other
+// compilers if neither is found.
+func (f *GoFile) compiler() string {
+	if f.BuildInfo != nil {
+		return CompilerGc
+	}
+	if _, data, err := f.fh.getPCLNTABData(); err == nil {
+		if knownPCLNTabMagic(data, f.FileInfo.ByteOrder) {
+			return CompilerGc
+		}
+		if _, ok := newerPCLNTab(data, f.FileInfo); ok {
+			return CompilerGc
+		}
+	}
+	switch {
+	case f.isTinyGo():
+		return CompilerTinyGo
+	case f.isGccgo():
+		return CompilerGccgo
+	}
+	return CompilerGc
+}
+
+// getDwarf returns the DWARF data of the file. It's only parsed once.
+func (f *GoFile) getDwarf() (*dwarf.Data, error) {
+	f.dwarfOnce.Do(func() {
+		f.dwarfData, f.dwarfError = f.fh.getDwarf()
+	})
+	return f.dwarfData, f.dwarfError
+}
+
+// hasDwarfProducer returns true if a compile unit of the DWARF data is
+// produced by a compiler with the prefix.
+func (f *GoFile) hasDwarfProducer(prefix string) bool {
+	data, err := f.getDwarf()
+	if err != nil {
+		return false
+	}
+	r := data.Reader()
+	for {
+		entry, err := r.Next()
+		if err != nil || entry == nil {
+			return false
+		}
+		if producer, ok := entry.Val(dwarf.AttrProducer).(string); ok && strings.HasPrefix(producer, prefix) {
+			return true
+		}
+		r.SkipChildren()
+	}
+}
+
+// nativeFunctions returns the functions of the binary. The symbols and the
+// DWARF data are combined, the name of a symbol is used if the function
+// has one.
+func (f *GoFile) nativeFunctions(c nativeNaming) ([]nativeFunc, error) {
+	textStart, text, err := f.fh.getCodeSection()
+	if err != nil {
+		return nil, err
+	}
+	textEnd := textStart + uint64(len(text))
+
+	funcs := make(map[uint64]*nativeFunc)
+	if data, err := f.getDwarf(); err == nil {
+		for _, fn := range dwarfFunctions(data, c.demangle) {
+			fn := fn
+			if fn.entry >= textStart && fn.entry < textEnd {
+				funcs[fn.entry] = &fn
+			}
+		}
+	}
+
+	if syms, err := f.symbols(); err == nil {
+		for name, sym := range syms {
+			if sym.Value < textStart || sym.Value >= textEnd {
+				continue
+			}
+			name = c.demangle(name)
+			if !c.isFunction(name) {
+				continue
+			}
+			fn, ok := funcs[sym.Value]
+			if !ok {
+				fn = &nativeFunc{entry: sym.Value, end: sym.Value + sym.Size}
+				funcs[sym.Value] = fn
+			}
+			fn.name = name
+		}
+	}
+
+	ret := make([]nativeFunc, 0, len(funcs))
+	for _, fn := range funcs {
+		if c.isFunction(fn.name) {
+			ret = append(ret, *fn)
+		}
+	}
+	if len(ret) == 0 {
+		return nil, errNoNativeFunctions
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].entry < ret[j].entry })
+	return ret, nil
+}
+
+// dwarfFunctions returns the functions described by the DWARF data with
+// their source lines. The end line is the last line in the line table for
+// the function that is in the same file.
+func dwarfFunctions(data *dwarf.Data, demangle func(string) string) []nativeFunc {
+	var ret []nativeFunc
+	r := data.Reader()
+	for {
+		cu, err := r.Next()
+		if err != nil || cu == nil {
+			break
+		}
+		if cu.Tag != dwarf.TagCompileUnit {
+			r.SkipChildren()
+			continue
+		}
+
+		var files []*dwarf.LineFile
+		lr, err := data.LineReader(cu)
+		if err == nil && lr != nil {
+			files = lr.Files()
+		}
+
+		var funcs []nativeFunc
+		for depth := 1; cu.Children && depth > 0; {
+			entry, err := r.Next()
+			if err != nil || entry == nil {
+				break
+			}
+			if entry.Tag == 0 {
+				depth--
+				continue
+			}
+			if entry.Children {
+				depth++
+			}
+			if entry.Tag != dwarf.TagSubprogram {
+				continue
+			}
+			ranges, err := data.Ranges(entry)
+			if err != nil || len(ranges) == 0 {
+				continue
+			}
+			name, _ := entry.Val(dwarf.AttrLinkageName).(string)
+			if name == "" {
+				name, _ = entry.Val(dwarf.AttrName).(string)
+			}
+			fn := nativeFunc{name: demangle(name), entry: ranges[0][0], end: ranges[0][1]}
+			if i, ok := entry.Val(dwarf.AttrDeclFile).(int64); ok && i >= 0 && int(i) < len(files) && files[i] != nil {
+				fn.file = files[i].Name
+			}
+			if line, ok := entry.Val(dwarf.AttrDeclLine).(int64); ok {
+				fn.line = int(line)
+				fn.endLine = int(line)
+			}
+			funcs = append(funcs, fn)
+		}
+
+		if lr != nil && len(funcs) != 0 {
+			sort.Slice(funcs, func(i, j int) bool { return funcs[i].entry < funcs[j].entry })
+			var le dwarf.LineEntry
+			for lr.Next(&le) == nil {
+				i := sort.Search(len(funcs), func(i int) bool { return funcs[i].entry > le.Address }) - 1
+				if i < 0 || le.Address >= funcs[i].end || le.File == nil || le.File.Name != funcs[i].file {
+					continue
+				}
+				if le.Line > funcs[i].endLine {
+					funcs[i].endLine = le.Line
+				}
+			}
+		}
+		ret = append(ret, funcs...)
+	}
+	return ret
+}
+
+// enumNativePackages groups the functions of a binary without a PCLN table
+// by package.
+func (f *GoFile) enumNativePackages(c nativeNaming) error {
+	funcs, err := f.nativeFunctions(c)
+	if err != nil {
+		return err
+	}
+
+	f.nativeFuncs = make(map[uint64]nativeFunc, len(funcs))
+	packages := make(map[string]*Package)
+	for _, fn := range funcs {
+		f.nativeFuncs[fn.entry] = fn
+
+		pkgName, receiver, name := c.split(fn.name)
+		p, ok := packages[pkgName]
+		if !ok {
+			p = &Package{
+				Functions: make([]*Function, 0),
+				Methods:   make([]*Method, 0),
+			}
+			packages[pkgName] = p
+		}
+		if p.Filepath == "" && fn.file != "" {
+			p.Filepath = path.Dir(fn.file)
+		}
+
+		function := &Function{
+			Name:        name,
+			Offset:      fn.entry,
+			End:         fn.end,
+			PackageName: pkgName,
+			Func: &gosym.Func{
+				Entry: fn.entry,
+				End:   fn.end,
+				Sym:   &gosym.Sym{Value: fn.entry, Type: 'T', Name: fn.name},
+			},
+		}
+		if receiver != "" {
+			p.Methods = append(p.Methods, &Method{Function: function, Receiver: receiver})
+		} else {
+			p.Functions = append(p.Functions, function)
+		}
+	}
+	return f.classifyPackages(packages)
+}
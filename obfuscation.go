@@ -0,0 +1,562 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/arch/x86/x86asm"
+)
+
+// Obfuscator is a tool used to obfuscate Go binaries.
+type Obfuscator int
+
+const (
+	// ObfuscatorNone means no obfuscation was detected.
+	ObfuscatorNone Obfuscator = iota
+	// ObfuscatorUnknown means the binary looks obfuscated or tampered with
+	// but the tool is not known.
+	ObfuscatorUnknown
+	// ObfuscatorGarble is garble, https://github.com/burrowers/garble.
+	ObfuscatorGarble
+	// ObfuscatorGobfuscate is gobfuscate, https://github.com/unixpickle/gobfuscate.
+	ObfuscatorGobfuscate
+)
+
+// String returns the name of the obfuscator.
+func (o Obfuscator) String() string {
+	switch o {
+	case ObfuscatorNone:
+		return "none"
+	case ObfuscatorGarble:
+		return "garble"
+	case ObfuscatorGobfuscate:
+		return "gobfuscate"
+	default:
+		return "unknown"
+	}
+}
+
+// EvidenceKind is the kind of an obfuscation evidence.
+type EvidenceKind int
+
+const (
+	// EvidenceHashedIdentifiers is package or function names that look like
+	// the hashes garble uses.
+	EvidenceHashedIdentifiers EvidenceKind = iota
+	// EvidenceHexIdentifiers is package or function names that look like the
+	// hex encoded hashes gobfuscate uses.
+	EvidenceHexIdentifiers
+	// EvidenceBuildVersion is a missing or modified runtime.buildVersion.
+	EvidenceBuildVersion
+	// EvidenceLiteralObfuscation is closures that decrypt data, used to
+	// hide string literals.
+	EvidenceLiteralObfuscation
+	// EvidencePCLNTabHeader is a PCLN table header that doesn't match the
+	// binary.
+	EvidencePCLNTabHeader
+	// EvidenceRenamedRuntime is runtime functions found outside of the
+	// runtime package.
+	EvidenceRenamedRuntime
+)
+
+// String returns a short name of the kind.
+func (k EvidenceKind) String() string {
+	switch k {
+	case EvidenceHashedIdentifiers:
+		return "hashed identifiers"
+	case EvidenceHexIdentifiers:
+		return "hex identifiers"
+	case EvidenceBuildVersion:
+		return "build version"
+	case EvidenceLiteralObfuscation:
+		return "literal obfuscation"
+	case EvidencePCLNTabHeader:
+		return "pclntab header"
+	case EvidenceRenamedRuntime:
+		return "renamed runtime"
+	default:
+		return "unknown"
+	}
+}
+
+// ObfuscationEvidence is an observation that indicates obfuscation.
+type ObfuscationEvidence struct {
+	// Kind of the evidence.
+	Kind EvidenceKind
+	// Description of what was observed.
+	Description string
+	// Weight is how strongly the evidence indicates obfuscation, between 0
+	// and 1.
+	Weight float64
+	// Examples holds a few of the observed identifiers, if any.
+	Examples []string
+}
+
+// ObfuscationReport is the result of the obfuscation detection.
+type ObfuscationReport struct {
+	// Obfuscator is the detected obfuscator.
+	Obfuscator Obfuscator
+	// Confidence is the combined weight of all the evidence, between 0 and 1.
+	Confidence float64
+	// Evidence is the list of observations.
+	Evidence []*ObfuscationEvidence
+}
+
+const (
+	// obfuscationThreshold is the confidence needed to report an unknown
+	// obfuscator when no tool specific evidence was found.
+	obfuscationThreshold = 0.5
+	// maxEvidenceExamples is the number of examples kept per evidence.
+	maxEvidenceExamples = 5
+)
+
+// Obfuscation looks for signs of obfuscation and tampering. The following
+// is checked:
+//
+//   - Package and function names that look like the hashes used by garble
+//     or gobfuscate.
+//   - A missing or modified runtime.buildVersion.
+//   - Closures that decrypt data, used by garble to hide literals. This is
+//     only checked for x86 binaries.
+//   - A PCLN table magic that doesn't match the Go version, or a header that
+//     doesn't match the architecture or is corrupted.
+//   - Runtime functions found outside of the runtime package.
+//
+// For binaries not compiled by gc, only the names are checked.
+func (f *GoFile) Obfuscation() (*ObfuscationReport, error) {
+	// Only gc binaries have a PCLN table and a runtime.buildVersion, and
+	// the runtime and the closures are named differently by the other
+	// compilers. Only the names are checked for them.
+	gc := f.FileInfo.Compiler == CompilerGc
+	if gc {
+		if err := f.initPclntab(); err != nil && f.pclntabBytes == nil {
+			return nil, err
+		}
+	}
+
+	report := &ObfuscationReport{}
+	add := func(e *ObfuscationEvidence) {
+		if e != nil {
+			report.Evidence = append(report.Evidence, e)
+		}
+	}
+
+	if gc {
+		version := f.buildVersion()
+		add(buildVersionEvidence(version, f.BuildInfo != nil))
+		add(pclntabHeaderEvidence(f.pclntabBytes, f.FileInfo, version))
+		if f.pclntabRecovered {
+			add(&ObfuscationEvidence{
+				Kind:        EvidencePCLNTabHeader,
+				Description: "the pclntab header is corrupted, the table was located by its structure",
+				Weight:      0.7,
+			})
+		}
+	}
+
+	pkgs, err := f.allPackages()
+	switch {
+	case err != nil && !gc:
+		return nil, err
+	case err != nil:
+		add(&ObfuscationEvidence{
+			Kind:        EvidencePCLNTabHeader,
+			Description: "the function table could not be parsed: " + err.Error(),
+			Weight:      0.3,
+		})
+	default:
+		var named []*Package
+		for _, p := range pkgs {
+			if !IsStandardLibrary(p.Name) && p.Name != "" {
+				named = append(named, p)
+			}
+		}
+		garble, hex := identifierEvidence(named)
+		add(garble)
+		add(hex)
+		if gc {
+			add(renamedRuntimeEvidence(pkgs))
+			add(f.literalEvidence(named))
+		}
+	}
+
+	keep := 1.0
+	for _, e := range report.Evidence {
+		keep *= 1 - e.Weight
+		switch e.Kind {
+		case EvidenceHashedIdentifiers:
+			report.Obfuscator = ObfuscatorGarble
+		case EvidenceHexIdentifiers:
+			if report.Obfuscator == ObfuscatorNone {
+				report.Obfuscator = ObfuscatorGobfuscate
+			}
+		}
+	}
+	report.Confidence = 1 - keep
+	if report.Obfuscator == ObfuscatorNone && report.Confidence >= obfuscationThreshold {
+		report.Obfuscator = ObfuscatorUnknown
+	}
+	return report, nil
+}
+
+// buildVersion returns the content of runtime.buildVersion. If the symbol
+// is not available, the read-only data is searched for a version string.
+func (f *GoFile) buildVersion() string {
+	if sym, err := f.fh.getSymbol("runtime.buildVersion"); err == nil {
+		ws := uint64(f.FileInfo.WordSize)
+		if hdr, err := f.Bytes(sym.Value, 2*ws); err == nil {
+			ptr, size := readWord(hdr, f.FileInfo), readWord(hdr[ws:], f.FileInfo)
+			if size == 0 {
+				return ""
+			}
+			if data, err := f.Bytes(ptr, size); err == nil {
+				return string(data)
+			}
+		}
+	}
+
+	data, err := f.fh.getRData()
+	if errors.Is(err, ErrSectionDoesNotExist) {
+		_, data, err = f.fh.getCodeSection()
+	}
+	if err != nil {
+		return ""
+	}
+	return matchGoVersionString(data)
+}
+
+// readWord reads a pointer sized value.
+func readWord(b []byte, fi *FileInfo) uint64 {
+	if fi.WordSize == intSize32 {
+		return uint64(fi.ByteOrder.Uint32(b))
+	}
+	return fi.ByteOrder.Uint64(b)
+}
+
+func buildVersionEvidence(version string, hasBuildInfo bool) *ObfuscationEvidence {
+	switch {
+	case version == "":
+		// Go before 1.4 has no version string and without build information
+		// the binary may be that old.
+		w := 0.2
+		if hasBuildInfo {
+			w = 0.6
+		}
+		return &ObfuscationEvidence{
+			Kind:        EvidenceBuildVersion,
+			Description: "runtime.buildVersion is missing",
+			Weight:      w,
+		}
+	case !strings.HasPrefix(version, "go") && !strings.HasPrefix(version, "devel"):
+		return &ObfuscationEvidence{
+			Kind:        EvidenceBuildVersion,
+			Description: fmt.Sprintf("runtime.buildVersion is %q", version),
+			Weight:      0.6,
+			Examples:    []string{version},
+		}
+	}
+	return nil
+}
+
+// expectedPCLNTabMagic returns the magic the linker of the Go version writes.
+func expectedPCLNTabMagic(version string) (uint32, bool) {
+	v := strings.Fields(version)
+	if len(v) == 0 || !strings.HasPrefix(v[0], "go1.") {
+		return 0, false
+	}
+	switch {
+	case GoVersionCompare(v[0], "go1.2beta1") < 0:
+		return 0, false
+	case GoVersionCompare(v[0], "go1.16beta1") < 0:
+		return gopclntab12magic, true
+	case GoVersionCompare(v[0], "go1.18beta1") < 0:
+		return gopclntab116magic, true
+	case GoVersionCompare(v[0], "go1.20rc1") < 0:
+		return gopclntab118magic, true
+	}
+	return gopclntab120magic, true
+}
+
+// pcQuantum is the instruction size unit used by the PCLN table.
+var pcQuantum = map[string]byte{
+	ArchAMD64: 1,
+	Arch386:   1,
+	ArchARM:   4,
+	ArchARM64: 4,
+	ArchMIPS:  4,
+}
+
+func pclntabHeaderEvidence(tab []byte, fi *FileInfo, version string) *ObfuscationEvidence {
+	if len(tab) < 8 {
+		return nil
+	}
+	var problems []string
+	magic := fi.ByteOrder.Uint32(tab)
+	switch magic {
+	case gopclntab12magic, gopclntab116magic, gopclntab118magic, gopclntab120magic:
+		if want, ok := expectedPCLNTabMagic(version); ok && want != magic {
+			problems = append(problems, fmt.Sprintf("magic 0x%x doesn't match %s", magic, version))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown magic 0x%x", magic))
+	}
+	if !validPCLNTabHeader(tab) {
+		problems = append(problems, "malformed header")
+	}
+	// The architecture is empty if the machine in the file header is not
+	// known, the header is then not compared with it.
+	if q, ok := pcQuantum[fi.Arch]; ok {
+		if tab[6] != q {
+			problems = append(problems, fmt.Sprintf("pc quantum %d doesn't match %s", tab[6], fi.Arch))
+		}
+		if int(tab[7]) != fi.WordSize {
+			problems = append(problems, fmt.Sprintf("pointer size %d doesn't match the word size %d", tab[7], fi.WordSize))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ObfuscationEvidence{
+		Kind:        EvidencePCLNTabHeader,
+		Description: "the pclntab header has been modified: " + strings.Join(problems, ", "),
+		Weight:      0.7,
+	}
+}
+
+// identifierEvidence looks for package and function names hashed by garble
+// or gobfuscate.
+func identifierEvidence(pkgs []*Package) (garble, hex *ObfuscationEvidence) {
+	var total int
+	var hashed, hexed []string
+	check := func(name string) {
+		if name == "" {
+			return
+		}
+		total++
+		switch {
+		case isHexHash(name):
+			hexed = append(hexed, name)
+		case isGarbleHash(name):
+			hashed = append(hashed, name)
+		}
+	}
+
+	for _, p := range pkgs {
+		for _, elem := range strings.Split(p.Name, "/") {
+			check(elem)
+		}
+		for _, fn := range p.Functions {
+			check(identifierOf(fn.Name))
+		}
+		for _, m := range p.Methods {
+			check(identifierOf(strings.Trim(m.Receiver, "(*)")))
+			check(identifierOf(m.Name))
+		}
+	}
+
+	newEvidence := func(kind EvidenceKind, names []string, what string) *ObfuscationEvidence {
+		if len(names) < 3 {
+			return nil
+		}
+		ratio := float64(len(names)) / float64(total)
+		if ratio < 0.25 {
+			return nil
+		}
+		w := 0.6
+		if ratio >= 0.5 {
+			w = 0.9
+		}
+		return &ObfuscationEvidence{
+			Kind:        kind,
+			Description: fmt.Sprintf("%d of %d package and function names look like %s", len(names), total, what),
+			Weight:      w,
+			Examples:    names[:min(len(names), maxEvidenceExamples)],
+		}
+	}
+	return newEvidence(EvidenceHashedIdentifiers, hashed, "garble hashes"),
+		newEvidence(EvidenceHexIdentifiers, hexed, "hex encoded hashes")
+}
+
+// identifierOf returns the first identifier of a function base name. For
+// example "main.func1" gives "main" and "Map[...]" gives "Map".
+func identifierOf(name string) string {
+	if i := strings.IndexAny(name, ".["); i != -1 {
+		name = name[:i]
+	}
+	return name
+}
+
+// isGarbleHash returns true if the identifier looks like a base64 encoded
+// hash. Garble keeps the case of the first letter, so hashes look like
+// identifiers where the character classes change far more often than in
+// names written by a human.
+func isGarbleHash(s string) bool {
+	if len(s) < 5 || len(s) > 16 {
+		return false
+	}
+	class := func(c byte) int {
+		switch {
+		case 'a' <= c && c <= 'z':
+			return 0
+		case 'A' <= c && c <= 'Z':
+			return 1
+		case '0' <= c && c <= '9':
+			return 2
+		case c == '_':
+			return 3
+		}
+		return -1
+	}
+	if class(s[0]) < 0 || class(s[0]) == 2 {
+		return false
+	}
+	changes := 0
+	for i := 1; i < len(s); i++ {
+		c := class(s[i])
+		if c < 0 {
+			return false
+		}
+		if c != class(s[i-1]) {
+			changes++
+		}
+	}
+	return changes >= 3 && changes*2 >= len(s)
+}
+
+// isHexHash returns true if the identifier is a long hex string, optionally
+// prefixed with an underscore to make it a valid identifier.
+func isHexHash(s string) bool {
+	s = strings.TrimPrefix(s, "_")
+	if len(s) < 16 {
+		return false
+	}
+	var digit, letter bool
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case '0' <= c && c <= '9':
+			digit = true
+		case 'a' <= c && c <= 'f':
+			letter = true
+		default:
+			return false
+		}
+	}
+	return digit && letter
+}
+
+// runtimeFunctions are functions every Go binary has in the runtime package.
+var runtimeFunctions = []string{"morestack", "goexit", "gopanic", "schedinit", "mallocgc", "newproc", "rt0_go"}
+
+// renamedRuntimeEvidence looks for the runtime functions in other packages
+// if the runtime package is missing.
+func renamedRuntimeEvidence(pkgs []*Package) *ObfuscationEvidence {
+	var hosts []string
+	for _, p := range pkgs {
+		if p.Name == "runtime" {
+			return nil
+		}
+		found := 0
+		for _, fn := range p.Functions {
+			for _, name := range runtimeFunctions {
+				if fn.Name == name {
+					found++
+				}
+			}
+		}
+		if found >= 3 {
+			hosts = append(hosts, p.Name)
+		}
+	}
+	desc := "the runtime package is missing"
+	w := 0.5
+	if len(hosts) != 0 {
+		desc = fmt.Sprintf("the runtime package has been renamed to %s", strings.Join(hosts, ", "))
+		w = 0.9
+	}
+	return &ObfuscationEvidence{
+		Kind:        EvidenceRenamedRuntime,
+		Description: desc,
+		Weight:      w,
+		Examples:    hosts,
+	}
+}
+
+// literalEvidence looks for closures that XOR data with a key. Garble hides
+// literals by replacing them with calls to such closures.
+func (f *GoFile) literalEvidence(pkgs []*Package) *ObfuscationEvidence {
+	var mode int
+	switch f.FileInfo.Arch {
+	case ArchAMD64:
+		mode = 64
+	case Arch386:
+		mode = 32
+	default:
+		return nil
+	}
+
+	var closures int
+	var decrypters []string
+	for _, p := range pkgs {
+		for _, fn := range p.Functions {
+			if !strings.Contains(fn.Name, ".func") {
+				continue
+			}
+			closures++
+			code, err := f.Bytes(fn.Offset, fn.End-fn.Offset)
+			if err != nil {
+				continue
+			}
+			if xorsData(code, mode) {
+				decrypters = append(decrypters, p.Name+"."+fn.Name)
+			}
+		}
+	}
+	if len(decrypters) < 5 || len(decrypters)*10 < closures*3 {
+		return nil
+	}
+	return &ObfuscationEvidence{
+		Kind:        EvidenceLiteralObfuscation,
+		Description: fmt.Sprintf("%d of %d closures decrypt data with XOR", len(decrypters), closures),
+		Weight:      0.5,
+		Examples:    decrypters[:min(len(decrypters), maxEvidenceExamples)],
+	}
+}
+
+// xorsData returns true if the code has an XOR that is not used to clear a
+// register.
+func xorsData(code []byte, mode int) bool {
+	for len(code) > 0 {
+		inst, err := x86asm.Decode(code, mode)
+		if err != nil {
+			code = code[1:]
+			continue
+		}
+		code = code[inst.Len:]
+		if inst.Op != x86asm.XOR && inst.Op != x86asm.PXOR && inst.Op != x86asm.XORPS {
+			continue
+		}
+		if inst.Args[0] != nil && inst.Args[0] == inst.Args[1] {
+			continue
+		}
+		return true
+	}
+	return false
+}
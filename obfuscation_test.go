@@ -0,0 +1,187 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentifierHashes(t *testing.T) {
+	for _, name := range []string{"Ib8dQWkn", "jC2klNq", "aX9_bQ3z", "Fq7Lm2Xp"} {
+		assert.True(t, isGarbleHash(name), name)
+	}
+	for _, name := range []string{"main", "getData", "HTTPServer", "readUint32", "MarshalJSON", "x86asm", "sha256", "func1"} {
+		assert.False(t, isGarbleHash(name), name)
+	}
+
+	assert.True(t, isHexHash("_5d41402abc4b2a76b9719d911017c592"))
+	assert.True(t, isHexHash("a06ed5a1b1c2d3e4"))
+	assert.False(t, isHexHash("deadbeefcafebabe"))
+	assert.False(t, isHexHash("a06ed5a1"))
+
+	assert.Equal(t, "main", identifierOf("main.func1"))
+	assert.Equal(t, "Map", identifierOf("Map[...]"))
+}
+
+func TestIdentifierEvidence(t *testing.T) {
+	fns := func(names ...string) []*Function {
+		var r []*Function
+		for _, n := range names {
+			r = append(r, &Function{Name: n})
+		}
+		return r
+	}
+
+	garble, hex := identifierEvidence([]*Package{
+		{Name: "main", Functions: fns("main", "Ib8dQWkn", "jC2klNq.func1")},
+		{Name: "aX9_bQ3z", Functions: fns("Wk3pZr9a", "Fq7Lm2Xp")},
+	})
+	require.NotNil(t, garble)
+	assert.Nil(t, hex)
+	assert.Equal(t, 0.9, garble.Weight)
+	assert.Contains(t, garble.Examples, "aX9_bQ3z")
+
+	garble, hex = identifierEvidence([]*Package{
+		{Name: "github.com/user/app", Functions: fns("getData", "parseConfig", "run")},
+	})
+	assert.Nil(t, garble)
+	assert.Nil(t, hex)
+
+	garble, hex = identifierEvidence([]*Package{
+		{Name: "_5d41402abc4b2a76b9719d911017c592", Functions: fns("_7d793037a0760186574b0282f2f435e7", "_9e107d9d372bb6826bd81d3542a419d6")},
+	})
+	assert.Nil(t, garble)
+	require.NotNil(t, hex)
+}
+
+func TestRenamedRuntimeEvidence(t *testing.T) {
+	fns := func(names ...string) []*Function {
+		var r []*Function
+		for _, n := range names {
+			r = append(r, &Function{Name: n})
+		}
+		return r
+	}
+	assert.Nil(t, renamedRuntimeEvidence([]*Package{{Name: "runtime"}, {Name: "main"}}))
+
+	e := renamedRuntimeEvidence([]*Package{
+		{Name: "rtx", Functions: fns("morestack", "goexit", "schedinit", "other")},
+		{Name: "main"},
+	})
+	require.NotNil(t, e)
+	assert.Equal(t, []string{"rtx"}, e.Examples)
+	assert.Equal(t, 0.9, e.Weight)
+}
+
+func TestPCLNTabHeaderEvidence(t *testing.T) {
+	fi := &FileInfo{Arch: ArchAMD64, WordSize: intSize64, ByteOrder: binary.LittleEndian}
+	header := func(magic uint32, quantum, ptrSize byte) []byte {
+		b := make([]byte, 16)
+		binary.LittleEndian.PutUint32(b, magic)
+		b[6], b[7] = quantum, ptrSize
+		return b
+	}
+
+	assert.Nil(t, pclntabHeaderEvidence(header(gopclntab120magic, 1, 8), fi, "go1.22.1"))
+	assert.Nil(t, pclntabHeaderEvidence(header(gopclntab12magic, 1, 8), fi, "go1.10"))
+	assert.Nil(t, pclntabHeaderEvidence(header(gopclntab118magic, 1, 8), fi, ""))
+	assert.NotNil(t, pclntabHeaderEvidence(header(gopclntab116magic, 1, 8), fi, "go1.22.1"))
+	assert.NotNil(t, pclntabHeaderEvidence(header(0xdeadbeef, 1, 8), fi, ""))
+	assert.NotNil(t, pclntabHeaderEvidence(header(gopclntab120magic, 4, 8), fi, ""))
+	assert.NotNil(t, pclntabHeaderEvidence(header(gopclntab120magic, 1, 4), fi, ""))
+
+	// The header isn't compared with an unknown architecture.
+	unknown := &FileInfo{WordSize: intSize64, ByteOrder: binary.LittleEndian}
+	assert.Nil(t, pclntabHeaderEvidence(header(gopclntab120magic, 4, 8), unknown, ""))
+	assert.Nil(t, pclntabHeaderEvidence(header(gopclntab120magic, 2, 8), unknown, ""))
+}
+
+func TestBuildVersionEvidence(t *testing.T) {
+	assert.Nil(t, buildVersionEvidence("go1.22.1", true))
+	assert.Nil(t, buildVersionEvidence("devel go1.23-abc", true))
+	assert.Equal(t, 0.6, buildVersionEvidence("", true).Weight)
+	assert.Equal(t, 0.2, buildVersionEvidence("", false).Weight)
+	assert.NotNil(t, buildVersionEvidence("unknown", false))
+}
+
+func TestObfuscation(t *testing.T) {
+	exe := buildTestBinary(t, testresourcesrc)
+
+	t.Run("clean", func(t *testing.T) {
+		f, err := Open(exe)
+		require.NoError(t, err)
+		defer f.Close()
+
+		report, err := f.Obfuscation()
+		require.NoError(t, err)
+		assert.Equal(t, ObfuscatorNone, report.Obfuscator)
+		assert.Empty(t, report.Evidence)
+		assert.Zero(t, report.Confidence)
+	})
+
+	t.Run("windows arm64", func(t *testing.T) {
+		f, err := Open(buildTestBinaryFor(t, "windows", "arm64", testresourcesrc))
+		require.NoError(t, err)
+		defer f.Close()
+
+		report, err := f.Obfuscation()
+		require.NoError(t, err)
+		assert.Equal(t, ObfuscatorNone, report.Obfuscator)
+		assert.Empty(t, report.Evidence)
+	})
+
+	t.Run("tinygo", func(t *testing.T) {
+		mod := buildWasmModule(t, []string{"main.main", "runtime.initAll"}, nil)
+		f, err := OpenReader(bytes.NewReader(mod))
+		require.NoError(t, err)
+		defer f.Close()
+		require.Equal(t, CompilerTinyGo, f.FileInfo.Compiler)
+
+		report, err := f.Obfuscation()
+		require.NoError(t, err)
+		assert.Equal(t, ObfuscatorNone, report.Obfuscator)
+		assert.Empty(t, report.Evidence)
+	})
+
+	t.Run("tampered magic", func(t *testing.T) {
+		data, err := os.ReadFile(exe)
+		require.NoError(t, err)
+		ef, err := elf.NewFile(bytes.NewReader(data))
+		require.NoError(t, err)
+		sec := ef.Section(".gopclntab")
+		require.NotNil(t, sec)
+		binary.LittleEndian.PutUint32(data[sec.Offset:], gopclntab116magic)
+
+		f, err := OpenReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		defer f.Close()
+
+		report, err := f.Obfuscation()
+		require.NoError(t, err)
+		require.NotEmpty(t, report.Evidence)
+		assert.Equal(t, EvidencePCLNTabHeader, report.Evidence[0].Kind)
+		assert.Equal(t, ObfuscatorUnknown, report.Obfuscator)
+	})
+}
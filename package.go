@@ -57,8 +57,7 @@ func (f *GoFile) GetSourceFiles(p *Package) []*SourceFile {
 
 	// Sort functions and methods by source file.
 	for _, fn := range p.Functions {
-		fileName, _, _ := f.pclntab.PCToLine(fn.Offset)
-		start, end := findSourceLines(fn.Offset, fn.End, f.pclntab)
+		fileName, start, end := f.SourceInfo(fn)
 
 		e := FileEntry{Name: fn.Name, Start: start, End: end}
 
@@ -67,8 +66,7 @@ func (f *GoFile) GetSourceFiles(p *Package) []*SourceFile {
 		tmp[fileName] = sf
 	}
 	for _, m := range p.Methods {
-		fileName, _, _ := f.pclntab.PCToLine(m.Offset)
-		start, end := findSourceLines(m.Offset, m.End, f.pclntab)
+		fileName, start, end := f.SourceInfo(m.Function)
 
 		e := FileEntry{Name: fmt.Sprintf("%s%s", m.Receiver, m.Name), Start: start, End: end}
 
@@ -108,6 +106,22 @@ const (
 	ClassGenerated
 )
 
+// String returns a short lower case name for the package class.
+func (c PackageClass) String() string {
+	switch c {
+	case ClassSTD:
+		return "std"
+	case ClassMain:
+		return "main"
+	case ClassVendor:
+		return "vendor"
+	case ClassGenerated:
+		return "generated"
+	default:
+		return "unknown"
+	}
+}
+
 // PackageClassifier classifies a package to the correct class type.
 type PackageClassifier interface {
 	// Classify performs the classification.
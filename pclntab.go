@@ -45,10 +45,7 @@ MagicLoop:
 		}
 		for off != -1 {
 			if off != 0 {
-				buf := secData[off:]
-				if len(buf) < 16 || buf[4] != 0 || buf[5] != 0 ||
-					(buf[6] != 1 && buf[6] != 2 && buf[6] != 4) || // pc quantum
-					(buf[7] != 4 && buf[7] != 8) { // pointer size
+				if !validPCLNTabHeader(secData[off:]) {
 					// Header doesn't match.
 					if off-1 <= 0 {
 						continue MagicLoop
@@ -64,3 +61,242 @@ MagicLoop:
 	}
 	return nil, ErrNoPCLNTab
 }
+
+// validPCLNTabHeader returns true if the buffer starts with a PCLN table
+// header: a magic followed by two zero bytes, the pc quantum and the pointer
+// size.
+func validPCLNTabHeader(buf []byte) bool {
+	return len(buf) >= 16 && buf[4] == 0 && buf[5] == 0 &&
+		(buf[6] == 1 || buf[6] == 2 || buf[6] == 4) && // pc quantum
+		(buf[7] == 4 || buf[7] == 8) // pointer size
+}
+
+// knownPCLNTabMagic returns true if the data starts with one of the known
+// PCLN table magics and a valid header.
+func knownPCLNTabMagic(data []byte, order binary.ByteOrder) bool {
+	if !validPCLNTabHeader(data) {
+		return false
+	}
+	switch order.Uint32(data) {
+	case gopclntab12magic, gopclntab116magic, gopclntab118magic, gopclntab120magic:
+		return true
+	}
+	return false
+}
+
+// newerPCLNTab checks if the data is a PCLN table with a magic that is not
+// known but looks like one used by a Go release newer than the supported
+// ones. The magics have been counting down from 0xfffffffb and restarted at
+// 0xfffffff0 in Go 1.18, so a newer release is expected to use a magic
+// between gopclntab120magic and gopclntab116magic. If the table validates
+// with the newest known layout, a copy with the Go 1.20 magic is returned.
+func newerPCLNTab(data []byte, fi *FileInfo) ([]byte, bool) {
+	if !validPCLNTabHeader(data) {
+		return nil, false
+	}
+	magic := fi.ByteOrder.Uint32(data)
+	if magic <= gopclntab120magic || magic >= gopclntab116magic {
+		return nil, false
+	}
+	if !validatePCLNTab118(data, int(data[7]), fi.ByteOrder) {
+		return nil, false
+	}
+	tab := make([]byte, len(data))
+	copy(tab, data)
+	fi.ByteOrder.PutUint32(tab, gopclntab120magic)
+	return tab, true
+}
+
+const (
+	// pclntabRecoverAlign is the alignment used when searching for a table
+	// with a corrupted header.
+	pclntabRecoverAlign = 4
+	// pclntabRecoverChecks is the number of functions whose _func entry is
+	// validated.
+	pclntabRecoverChecks = 16
+)
+
+// pclntabLayout describes where the fields used for the structural
+// validation are located for a group of PCLN table versions.
+type pclntabLayout struct {
+	magic    uint32
+	validate func(tab []byte, ws int, order binary.ByteOrder) bool
+}
+
+// recoverPCLNTab looks for a PCLN table in the section without trusting the
+// header. Every aligned offset is validated by checking that the function
+// table is sorted, that the function entries point back to the table and
+// that the function names and the file table are strings. On success a copy
+// of the table is returned with the header repaired, together with the
+// offset of the table in the section.
+//
+// The magic of the recovered table is the one for the newest layout that
+// matches. If the Go version is known, the magic for the version is used
+// instead when its layout matches.
+func recoverPCLNTab(secData []byte, fi *FileInfo, version string) (int, []byte, error) {
+	ws := fi.WordSize
+	if ws != intSize32 && ws != intSize64 {
+		return 0, nil, ErrNoPCLNTab
+	}
+	quantum, ok := pcQuantum[fi.Arch]
+	if !ok {
+		quantum = 1
+	}
+
+	layouts := []pclntabLayout{
+		{gopclntab120magic, validatePCLNTab118},
+		{gopclntab116magic, validatePCLNTab116},
+		{gopclntab12magic, validatePCLNTab12},
+	}
+	if want, ok := expectedPCLNTabMagic(version); ok {
+		for i, l := range layouts {
+			if l.magic == want || (want == gopclntab118magic && l.magic == gopclntab120magic) {
+				layouts = []pclntabLayout{{want, layouts[i].validate}}
+				break
+			}
+		}
+	}
+
+	for off := 0; off+8+8*ws <= len(secData); off += pclntabRecoverAlign {
+		tab := secData[off:]
+		for _, l := range layouts {
+			if !l.validate(tab, ws, fi.ByteOrder) {
+				continue
+			}
+			repaired := make([]byte, len(tab))
+			copy(repaired, tab)
+			fi.ByteOrder.PutUint32(repaired, l.magic)
+			repaired[4], repaired[5] = 0, 0
+			repaired[6], repaired[7] = quantum, byte(ws)
+			return off, repaired, nil
+		}
+	}
+	return 0, nil, ErrNoPCLNTab
+}
+
+// pclntabWord reads a pointer sized value at the offset.
+func pclntabWord(b []byte, off, ws int, order binary.ByteOrder) uint64 {
+	if ws == intSize32 {
+		return uint64(order.Uint32(b[off:]))
+	}
+	return order.Uint64(b[off:])
+}
+
+// isPCLNTabString returns true if a non-empty, printable and NUL terminated
+// string starts at the offset. If delimited is true, the previous byte must
+// also be a NUL, as it is for strings in a string table.
+func isPCLNTabString(b []byte, off uint64, delimited bool) bool {
+	if off >= uint64(len(b)) || (delimited && off > 0 && b[off-1] != 0) {
+		return false
+	}
+	end := bytes.IndexByte(b[off:], 0)
+	if end <= 0 {
+		return false
+	}
+	for _, c := range b[off : off+uint64(end)] {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// validatePCLNTab118 validates the layout used by Go 1.18 and later.
+func validatePCLNTab118(tab []byte, ws int, order binary.ByteOrder) bool {
+	hdr := 8 + 8*ws
+	if len(tab) < hdr {
+		return false
+	}
+	word := func(i int) uint64 { return pclntabWord(tab, 8+i*ws, ws, order) }
+	size := uint64(len(tab))
+	nfunc, nfiles := word(0), word(1)
+	funcname, cu, filetab, pctab, functab := word(3), word(4), word(5), word(6), word(7)
+	if nfunc == 0 || nfunc > size/8 || nfiles == 0 || nfiles > size ||
+		funcname < uint64(hdr) || funcname > cu || cu > filetab || filetab > pctab || pctab > functab ||
+		functab > size || functab+(nfunc*2+1)*4 > size {
+		return false
+	}
+	if !isPCLNTabString(tab[filetab:pctab], 0, true) {
+		return false
+	}
+	names := tab[funcname:cu]
+	ft := tab[functab:]
+	var prev uint32
+	for i := uint64(0); i < nfunc; i++ {
+		entry := order.Uint32(ft[i*8:])
+		if entry < prev {
+			return false
+		}
+		prev = entry
+		if i >= pclntabRecoverChecks {
+			continue
+		}
+		funcoff := uint64(order.Uint32(ft[i*8+4:]))
+		if funcoff+8 > uint64(len(ft)) || order.Uint32(ft[funcoff:]) != entry {
+			return false
+		}
+		if !isPCLNTabString(names, uint64(order.Uint32(ft[funcoff+4:])), true) {
+			return false
+		}
+	}
+	return order.Uint32(ft[nfunc*8:]) >= prev
+}
+
+// validatePCLNTab116 validates the layout used by Go 1.16 and 1.17.
+func validatePCLNTab116(tab []byte, ws int, order binary.ByteOrder) bool {
+	hdr := 8 + 7*ws
+	if len(tab) < hdr {
+		return false
+	}
+	word := func(i int) uint64 { return pclntabWord(tab, 8+i*ws, ws, order) }
+	size := uint64(len(tab))
+	nfunc, nfiles := word(0), word(1)
+	funcname, cu, filetab, pctab, pcln := word(2), word(3), word(4), word(5), word(6)
+	if nfunc == 0 || nfunc > size/8 || nfiles == 0 || nfiles > size ||
+		funcname < uint64(hdr) || funcname > cu || cu > filetab || filetab > pctab || pctab > pcln ||
+		pcln > size || pcln+(nfunc*2+1)*uint64(ws) > size {
+		return false
+	}
+	if !isPCLNTabString(tab[filetab:pctab], 0, true) {
+		return false
+	}
+	return validateFunctab(tab[pcln:], 0, tab[funcname:cu], true, nfunc, ws, order)
+}
+
+// validatePCLNTab12 validates the layout used by Go 1.2 to 1.15.
+func validatePCLNTab12(tab []byte, ws int, order binary.ByteOrder) bool {
+	size := uint64(len(tab))
+	nfunc := pclntabWord(tab, 8, ws, order)
+	if nfunc == 0 || nfunc > size/8 || 8+uint64(ws)+(nfunc*2+1)*uint64(ws) > size {
+		return false
+	}
+	// Function offsets and name offsets are relative to the table start.
+	return validateFunctab(tab, 8+uint64(ws), tab, false, nfunc, ws, order)
+}
+
+// validateFunctab validates a function table of pointer sized entry and
+// function offset pairs that starts at the offset in functab. The function
+// offsets are relative to the start of functab. Before Go 1.16, the names
+// are not in a string table of their own.
+func validateFunctab(functab []byte, start uint64, names []byte, nameTable bool, nfunc uint64, ws int, order binary.ByteOrder) bool {
+	pair := uint64(2 * ws)
+	var prev uint64
+	for i := uint64(0); i < nfunc; i++ {
+		entry := pclntabWord(functab, int(start+i*pair), ws, order)
+		if entry < prev || entry == 0 {
+			return false
+		}
+		prev = entry
+		if i >= pclntabRecoverChecks {
+			continue
+		}
+		funcoff := pclntabWord(functab, int(start+i*pair)+ws, ws, order)
+		if funcoff >= uint64(len(functab)) || funcoff+uint64(ws)+4 > uint64(len(functab)) || pclntabWord(functab, int(funcoff), ws, order) != entry {
+			return false
+		}
+		if !isPCLNTabString(names, uint64(order.Uint32(functab[funcoff+uint64(ws):])), nameTable) {
+			return false
+		}
+	}
+	return pclntabWord(functab, int(start+nfunc*pair), ws, order) >= prev
+}
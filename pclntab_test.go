@@ -18,9 +18,15 @@
 package gore
 
 import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -47,3 +53,137 @@ func TestGo116PCLNTab(t *testing.T) {
 	}
 
 }
+
+func TestRecoverPCLNTab(t *testing.T) {
+	exe := buildTestBinary(t, testresourcesrc)
+	data, err := os.ReadFile(exe)
+	require.NoError(t, err)
+	ef, err := elf.NewFile(bytes.NewReader(data))
+	require.NoError(t, err)
+	sec := ef.Section(".gopclntab")
+	require.NotNil(t, sec)
+	orig, err := sec.Data()
+	require.NoError(t, err)
+
+	// Overwrite the whole header, not just the magic.
+	corrupt := func(b []byte) {
+		copy(b, []byte{0xde, 0xad, 0xbe, 0xef, 0x11, 0x22, 0x33, 0x44})
+	}
+
+	t.Run("search", func(t *testing.T) {
+		fi := &FileInfo{Arch: ArchAMD64, WordSize: intSize64, ByteOrder: binary.LittleEndian}
+		secData := append(bytes.Repeat([]byte{0xff, 0x00, 0x01, 0x00}, 1024), orig...)
+		corrupt(secData[4096:])
+
+		_, err := searchSectionForTab(secData, binary.LittleEndian)
+		require.ErrorIs(t, err, ErrNoPCLNTab)
+
+		// Without a known version the newest magic for the layout is used.
+		off, tab, err := recoverPCLNTab(secData, fi, "")
+		require.NoError(t, err)
+		assert.Equal(t, 4096, off)
+		assert.Equal(t, orig, tab)
+		// The section itself is not modified.
+		assert.Equal(t, byte(0xde), secData[4096])
+
+		binary.LittleEndian.PutUint32(orig, gopclntab118magic)
+		_, tab, err = recoverPCLNTab(secData, fi, "go1.18.2")
+		require.NoError(t, err)
+		assert.Equal(t, orig, tab)
+		binary.LittleEndian.PutUint32(orig, gopclntab120magic)
+
+		_, _, err = recoverPCLNTab(secData[:4096], fi, "")
+		assert.ErrorIs(t, err, ErrNoPCLNTab)
+	})
+
+	t.Run("open", func(t *testing.T) {
+		corrupt(data[sec.Offset:])
+		f, err := OpenReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		defer f.Close()
+
+		pkgs, err := f.GetPackages()
+		require.NoError(t, err)
+		var found bool
+		for _, p := range pkgs {
+			for _, fn := range p.Functions {
+				found = found || fn.Name == "getData"
+			}
+		}
+		assert.True(t, found)
+		assert.True(t, f.pclntabRecovered)
+
+		report, err := f.Obfuscation()
+		require.NoError(t, err)
+		assert.Equal(t, ObfuscatorUnknown, report.Obfuscator)
+	})
+}
+
+func TestRecoverPCLNTabNotGo(t *testing.T) {
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("No C compiler found: " + err.Error())
+	}
+	exe := buildTestBinary(t, testresourcesrc)
+	ef, err := elf.Open(exe)
+	require.NoError(t, err)
+	tab, err := ef.Section(".gopclntab").Data()
+	ef.Close()
+	require.NoError(t, err)
+
+	// A C program whose read-only data holds a PCLN table with a corrupted
+	// header. It has no other sign of Go, so the table must not be
+	// recovered.
+	copy(tab, []byte{0xde, 0xad, 0xbe, 0xef, 0x11, 0x22, 0x33, 0x44})
+	tmpdir := t.TempDir()
+	tabFile := filepath.Join(tmpdir, "tab.bin")
+	require.NoError(t, os.WriteFile(tabFile, tab, 0o644))
+	src := "__asm__(\".section .rodata\\n.balign 8\\n.incbin \\\"" + tabFile + "\\\"\\n.previous\");\n" +
+		"int main(void) { return 0; }\n"
+	require.NoError(t, os.WriteFile(filepath.Join(tmpdir, "main.c"), []byte(src), 0o644))
+	out, err := exec.Command(cc, "-o", filepath.Join(tmpdir, "c"), filepath.Join(tmpdir, "main.c")).CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	f, err := Open(filepath.Join(tmpdir, "c"))
+	require.NoError(t, err)
+	defer f.Close()
+	assert.False(t, f.hasGoEvidence())
+	_, err = f.PCLNTab()
+	assert.ErrorIs(t, err, ErrNoPCLNTab)
+	assert.False(t, f.pclntabRecovered)
+}
+
+func TestNewerPCLNTab(t *testing.T) {
+	exe := buildTestBinary(t, testresourcesrc)
+	data, err := os.ReadFile(exe)
+	require.NoError(t, err)
+	ef, err := elf.NewFile(bytes.NewReader(data))
+	require.NoError(t, err)
+	sec := ef.Section(".gopclntab")
+	require.NotNil(t, sec)
+	orig, err := sec.Data()
+	require.NoError(t, err)
+
+	fi := &FileInfo{Arch: ArchAMD64, WordSize: intSize64, ByteOrder: binary.LittleEndian}
+	tab := append([]byte{}, orig...)
+	binary.LittleEndian.PutUint32(tab, 0xfffffff2)
+	got, ok := newerPCLNTab(tab, fi)
+	require.True(t, ok)
+	assert.Equal(t, orig, got)
+
+	for _, magic := range []uint32{gopclntab120magic, gopclntab116magic, 0xdeadbeef} {
+		binary.LittleEndian.PutUint32(tab, magic)
+		_, ok = newerPCLNTab(tab, fi)
+		assert.False(t, ok, "%#x", magic)
+	}
+
+	binary.LittleEndian.PutUint32(data[sec.Offset:], 0xfffffff2)
+	f, err := OpenReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.GetPackages()
+	require.NoError(t, err)
+	assert.False(t, f.pclntabRecovered)
+	require.Len(t, f.Warnings(), 1)
+	assert.Contains(t, f.Warnings()[0], "0xfffffff2")
+}
@@ -203,13 +203,22 @@ func (p *peFile) getSectionData(name string) (uint64, []byte, error) {
 }
 
 func (p *peFile) getFileInfo() *FileInfo {
-	fi := &FileInfo{ByteOrder: binary.LittleEndian, OS: "windows"}
-	if p.file.Machine == pe.IMAGE_FILE_MACHINE_I386 {
+	fi := &FileInfo{ByteOrder: binary.LittleEndian, OS: "windows", WordSize: intSize64}
+	if _, ok := p.file.OptionalHeader.(*pe.OptionalHeader32); ok {
+		fi.WordSize = intSize32
+	}
+	// The architecture is left empty for the other machines.
+	switch p.file.Machine {
+	case pe.IMAGE_FILE_MACHINE_I386:
 		fi.WordSize = intSize32
 		fi.Arch = Arch386
-	} else {
-		fi.WordSize = intSize64
+	case pe.IMAGE_FILE_MACHINE_AMD64:
 		fi.Arch = ArchAMD64
+	case pe.IMAGE_FILE_MACHINE_ARMNT:
+		fi.WordSize = intSize32
+		fi.Arch = ArchARM
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		fi.Arch = ArchARM64
 	}
 	return fi
 }
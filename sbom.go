@@ -0,0 +1,391 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SBOMFormat is the format of a software bill of materials.
+type SBOMFormat uint8
+
+const (
+	// SBOMCycloneDX is the CycloneDX 1.5 JSON format.
+	SBOMCycloneDX SBOMFormat = iota
+	// SBOMSPDX is the SPDX 2.3 JSON format.
+	SBOMSPDX
+)
+
+// ErrUnknownSBOMFormat is returned if the requested SBOM format is not supported.
+var ErrUnknownSBOMFormat = errors.New("unknown SBOM format")
+
+// sbomNow returns the creation time of the SBOM. Replaced in tests.
+var sbomNow = time.Now
+
+// WriteSBOM writes a software bill of materials for the binary to w.
+//
+// The components are the modules listed in the build information, with
+// replacements applied and the go.sum hashes converted to SHA-256 digests.
+// The Go toolchain is included as the "std" component and the build settings
+// are added as properties of the main component. If the binary has no build
+// information, the modules are inferred from the package paths by
+// InferBuildInfo and the SBOM notes that the components are inferred.
+func (f *GoFile) WriteSBOM(w io.Writer, format SBOMFormat) error {
+	bom, err := f.sbom()
+	if err != nil {
+		return err
+	}
+
+	var doc any
+	switch format {
+	case SBOMCycloneDX:
+		doc = bom.cycloneDX()
+	case SBOMSPDX:
+		doc = bom.spdx()
+	default:
+		return ErrUnknownSBOMFormat
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// sbomComponent is a module included in the binary.
+type sbomComponent struct {
+	path    string
+	version string
+	// sha256 is the hex encoded digest of the module's go.sum hash.
+	sha256 string
+	// replaces is the module@version that was replaced by this module.
+	replaces string
+}
+
+// purl returns the package URL of the component.
+func (c *sbomComponent) purl() string {
+	p := "pkg:golang/" + c.path
+	if c.version != "" && c.version != "(devel)" {
+		p += "@" + strings.ReplaceAll(c.version, "+", "%2B")
+	}
+	return p
+}
+
+// sbomDocument holds the information used to produce the SBOM in any format.
+type sbomDocument struct {
+	main      *sbomComponent
+	toolchain *sbomComponent
+	deps      []*sbomComponent
+	// settings are the build settings as key and value pairs.
+	settings [][2]string
+	// inferred is true if the modules were inferred from the package paths.
+	inferred bool
+	created  time.Time
+}
+
+func (f *GoFile) sbom() (*sbomDocument, error) {
+	doc := &sbomDocument{created: sbomNow().UTC()}
+
+	if v, err := f.GetCompilerVersion(); err == nil {
+		doc.toolchain = &sbomComponent{path: "std", version: v.Name}
+	}
+
+	info := f.BuildInfo
+	if info == nil || info.ModInfo == nil {
+		var err error
+		if info, err = f.InferBuildInfo(); err != nil {
+			return nil, err
+		}
+	}
+	doc.inferred = info.Inferred
+
+	bi := info.ModInfo
+	doc.main = &sbomComponent{path: bi.Main.Path, version: bi.Main.Version}
+	if doc.main.path == "" {
+		doc.main.path = bi.Path
+	}
+	if doc.main.path == "" {
+		doc.main.path = "main"
+	}
+	if doc.toolchain == nil && bi.GoVersion != "" {
+		doc.toolchain = &sbomComponent{path: "std", version: strings.Fields(bi.GoVersion)[0]}
+	}
+	for _, dep := range bi.Deps {
+		c := &sbomComponent{path: dep.Path, version: dep.Version, sha256: goSumToSHA256(dep.Sum)}
+		if r := dep.Replace; r != nil {
+			c.replaces = dep.Path + "@" + dep.Version
+			c.sha256 = goSumToSHA256(r.Sum)
+			// Modules replaced by a local directory keep their path
+			// since the directory is not a module path.
+			if r.Version != "" {
+				c.path = r.Path
+			}
+			c.version = r.Version
+		}
+		doc.deps = append(doc.deps, c)
+	}
+	for _, s := range bi.Settings {
+		doc.settings = append(doc.settings, [2]string{s.Key, s.Value})
+	}
+
+	sort.Slice(doc.deps, func(i, j int) bool {
+		return doc.deps[i].path < doc.deps[j].path
+	})
+	return doc, nil
+}
+
+// goSumToSHA256 converts a go.sum hash in the "h1:" format to a hex encoded
+// SHA-256 digest. An empty string is returned for other hashes.
+func goSumToSHA256(sum string) string {
+	b64, ok := strings.CutPrefix(sum, "h1:")
+	if !ok {
+		return ""
+	}
+	digest, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil || len(digest) != 32 {
+		return ""
+	}
+	return hex.EncodeToString(digest)
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+type cdxBOM struct {
+	BOMFormat    string           `json:"bomFormat"`
+	SpecVersion  string           `json:"specVersion"`
+	SerialNumber string           `json:"serialNumber"`
+	Version      int              `json:"version"`
+	Metadata     cdxMetadata      `json:"metadata"`
+	Components   []*cdxComponent  `json:"components"`
+	Dependencies []*cdxDependency `json:"dependencies"`
+}
+
+type cdxMetadata struct {
+	Timestamp  string         `json:"timestamp"`
+	Tools      cdxTools       `json:"tools"`
+	Component  *cdxComponent  `json:"component"`
+	Properties []*cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxTools struct {
+	Components []*cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	Type       string         `json:"type"`
+	BOMRef     string         `json:"bom-ref,omitempty"`
+	Name       string         `json:"name"`
+	Version    string         `json:"version,omitempty"`
+	PURL       string         `json:"purl,omitempty"`
+	Hashes     []*cdxHash     `json:"hashes,omitempty"`
+	Properties []*cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+func (d *sbomDocument) cycloneDX() *cdxBOM {
+	component := func(c *sbomComponent, typ string) *cdxComponent {
+		cc := &cdxComponent{Type: typ, BOMRef: c.purl(), Name: c.path, Version: c.version, PURL: c.purl()}
+		if c.sha256 != "" {
+			cc.Hashes = []*cdxHash{{Alg: "SHA-256", Content: c.sha256}}
+		}
+		if c.replaces != "" {
+			cc.Properties = append(cc.Properties, &cdxProperty{Name: "gore:replaces", Value: c.replaces})
+		}
+		return cc
+	}
+
+	main := component(d.main, "application")
+	for _, s := range d.settings {
+		main.Properties = append(main.Properties, &cdxProperty{Name: "gore:build:" + s[0], Value: s[1]})
+	}
+
+	bom := &cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + newUUID(),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: d.created.Format(time.RFC3339),
+			Tools:     cdxTools{Components: []*cdxComponent{{Type: "application", Name: "gore"}}},
+			Component: main,
+		},
+		Components: []*cdxComponent{},
+	}
+	if d.inferred {
+		bom.Metadata.Properties = []*cdxProperty{{Name: "gore:inferred", Value: "true"}}
+	}
+
+	deps := &cdxDependency{Ref: main.BOMRef}
+	all := d.deps
+	if d.toolchain != nil {
+		all = append([]*sbomComponent{d.toolchain}, all...)
+	}
+	for _, c := range all {
+		cc := component(c, "library")
+		bom.Components = append(bom.Components, cc)
+		deps.DependsOn = append(deps.DependsOn, cc.BOMRef)
+		bom.Dependencies = append(bom.Dependencies, &cdxDependency{Ref: cc.BOMRef})
+	}
+	bom.Dependencies = append([]*cdxDependency{deps}, bom.Dependencies...)
+	return bom
+}
+
+type spdxDocument struct {
+	SPDXVersion       string              `json:"spdxVersion"`
+	DataLicense       string              `json:"dataLicense"`
+	SPDXID            string              `json:"SPDXID"`
+	Name              string              `json:"name"`
+	DocumentNamespace string              `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo    `json:"creationInfo"`
+	Packages          []*spdxPackage      `json:"packages"`
+	Relationships     []*spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+	Comment  string   `json:"comment,omitempty"`
+}
+
+type spdxPackage struct {
+	Name             string             `json:"name"`
+	SPDXID           string             `json:"SPDXID"`
+	VersionInfo      string             `json:"versionInfo,omitempty"`
+	DownloadLocation string             `json:"downloadLocation"`
+	FilesAnalyzed    bool               `json:"filesAnalyzed"`
+	Checksums        []*spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs     []*spdxExternalRef `json:"externalRefs,omitempty"`
+	Comment          string             `json:"comment,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+func (d *sbomDocument) spdx() *spdxDocument {
+	var n int
+	pkg := func(c *sbomComponent) *spdxPackage {
+		n++
+		p := &spdxPackage{
+			Name:             c.path,
+			SPDXID:           fmt.Sprintf("SPDXRef-Package-%d", n),
+			VersionInfo:      c.version,
+			DownloadLocation: "NOASSERTION",
+			ExternalRefs: []*spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  c.purl(),
+			}},
+		}
+		if c.sha256 != "" {
+			p.Checksums = []*spdxChecksum{{Algorithm: "SHA256", ChecksumValue: c.sha256}}
+		}
+		if c.replaces != "" {
+			p.Comment = "Replaces " + c.replaces
+		}
+		return p
+	}
+
+	main := pkg(d.main)
+	var settings []string
+	for _, s := range d.settings {
+		settings = append(settings, s[0]+"="+s[1])
+	}
+	if len(settings) != 0 {
+		main.Comment = "Build settings: " + strings.Join(settings, " ")
+	}
+
+	doc := &spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              d.main.path,
+		DocumentNamespace: "https://spdx.org/spdxdocs/gore-" + newUUID(),
+		CreationInfo: spdxCreationInfo{
+			Created:  d.created.Format(time.RFC3339),
+			Creators: []string{"Tool: gore"},
+		},
+		Packages: []*spdxPackage{main},
+		Relationships: []*spdxRelationship{{
+			SPDXElementID:      "SPDXRef-DOCUMENT",
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: main.SPDXID,
+		}},
+	}
+
+	if d.inferred {
+		doc.CreationInfo.Comment = "The modules were inferred from the package paths."
+	}
+
+	all := d.deps
+	if d.toolchain != nil {
+		all = append([]*sbomComponent{d.toolchain}, all...)
+	}
+	for _, c := range all {
+		p := pkg(c)
+		doc.Packages = append(doc.Packages, p)
+		doc.Relationships = append(doc.Relationships, &spdxRelationship{
+			SPDXElementID:      main.SPDXID,
+			RelationshipType:   "DEPENDS_ON",
+			RelatedSPDXElement: p.SPDXID,
+		})
+	}
+	return doc
+}
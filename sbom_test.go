@@ -0,0 +1,121 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoSumToSHA256(t *testing.T) {
+	assert.Equal(t,
+		"a8f0e2a1b2b5e2c7b2bb5e2c3c3c8f0a21c5b3c2ff1e8a8e8b4b4e2c5d0b0d03",
+		goSumToSHA256("h1:qPDiobK14seyu14sPDyPCiHFs8L/HoqOi0tOLF0LDQM="))
+	assert.Empty(t, goSumToSHA256("h2:qPDiobK14seyu14sPDyPCiHFs8L/HoqOi0tOLF0LDQM="))
+	assert.Empty(t, goSumToSHA256("h1:tooshort"))
+}
+
+func TestSBOMFormats(t *testing.T) {
+	doc := &sbomDocument{
+		main:      &sbomComponent{path: "example.com/app", version: "(devel)"},
+		toolchain: &sbomComponent{path: "std", version: "go1.22.1"},
+		deps: []*sbomComponent{
+			{path: "example.com/a", version: "v1.0.0+incompatible", sha256: "00ff"},
+			{path: "example.com/fork", version: "v1.1.0", replaces: "example.com/b@v1.0.0"},
+		},
+		settings: [][2]string{{"CGO_ENABLED", "0"}, {"vcs.revision", "abc"}},
+		created:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	t.Run("cyclonedx", func(t *testing.T) {
+		assert := assert.New(t)
+		bom := doc.cycloneDX()
+		assert.Equal("CycloneDX", bom.BOMFormat)
+		assert.Equal("2024-01-02T03:04:05Z", bom.Metadata.Timestamp)
+		assert.Equal("pkg:golang/example.com/app", bom.Metadata.Component.PURL)
+		assert.Contains(bom.Metadata.Component.Properties, &cdxProperty{Name: "gore:build:vcs.revision", Value: "abc"})
+
+		require.Len(t, bom.Components, 3)
+		assert.Equal("pkg:golang/std@go1.22.1", bom.Components[0].PURL)
+		assert.Equal("pkg:golang/example.com/a@v1.0.0%2Bincompatible", bom.Components[1].PURL)
+		assert.Equal([]*cdxHash{{Alg: "SHA-256", Content: "00ff"}}, bom.Components[1].Hashes)
+		assert.Equal([]*cdxProperty{{Name: "gore:replaces", Value: "example.com/b@v1.0.0"}}, bom.Components[2].Properties)
+
+		require.Len(t, bom.Dependencies, 4)
+		assert.Equal(bom.Metadata.Component.BOMRef, bom.Dependencies[0].Ref)
+		assert.Len(bom.Dependencies[0].DependsOn, 3)
+	})
+
+	t.Run("spdx", func(t *testing.T) {
+		assert := assert.New(t)
+		spdx := doc.spdx()
+		assert.Equal("SPDX-2.3", spdx.SPDXVersion)
+		require.Len(t, spdx.Packages, 4)
+		assert.Equal("example.com/app", spdx.Packages[0].Name)
+		assert.Equal("Build settings: CGO_ENABLED=0 vcs.revision=abc", spdx.Packages[0].Comment)
+		assert.Equal([]*spdxChecksum{{Algorithm: "SHA256", ChecksumValue: "00ff"}}, spdx.Packages[2].Checksums)
+		assert.Equal("Replaces example.com/b@v1.0.0", spdx.Packages[3].Comment)
+
+		require.Len(t, spdx.Relationships, 4)
+		assert.Equal("DESCRIBES", spdx.Relationships[0].RelationshipType)
+		for _, r := range spdx.Relationships[1:] {
+			assert.Equal("DEPENDS_ON", r.RelationshipType)
+			assert.Equal(spdx.Packages[0].SPDXID, r.SPDXElementID)
+		}
+	})
+
+	t.Run("inferred", func(t *testing.T) {
+		inferred := *doc
+		inferred.inferred = true
+		assert.Equal(t, []*cdxProperty{{Name: "gore:inferred", Value: "true"}}, inferred.cycloneDX().Metadata.Properties)
+		assert.NotEmpty(t, inferred.spdx().CreationInfo.Comment)
+		assert.Nil(t, doc.cycloneDX().Metadata.Properties)
+	})
+}
+
+func TestWriteSBOM(t *testing.T) {
+	r := require.New(t)
+
+	f, err := Open(buildTestBinary(t, testresourcesrc))
+	r.NoError(err)
+	defer f.Close()
+
+	buf := &bytes.Buffer{}
+	r.NoError(f.WriteSBOM(buf, SBOMCycloneDX))
+
+	var bom cdxBOM
+	r.NoError(json.Unmarshal(buf.Bytes(), &bom))
+	r.Equal("github.com/goretk/gore/testbin", bom.Metadata.Component.Name)
+	r.Contains(bom.Metadata.Component.Properties, &cdxProperty{Name: "gore:build:GOOS", Value: "linux"})
+	r.Contains(bom.Metadata.Component.Properties, &cdxProperty{Name: "gore:build:CGO_ENABLED", Value: "0"})
+	r.NotEmpty(bom.Components)
+	r.Equal("std", bom.Components[0].Name)
+
+	buf.Reset()
+	r.NoError(f.WriteSBOM(buf, SBOMSPDX))
+	var spdx spdxDocument
+	r.NoError(json.Unmarshal(buf.Bytes(), &spdx))
+	r.Equal("github.com/goretk/gore/testbin", spdx.Name)
+
+	r.ErrorIs(f.WriteSBOM(buf, SBOMFormat(99)), ErrUnknownSBOMFormat)
+}
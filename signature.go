@@ -0,0 +1,225 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// SignatureDB is a set of function signatures for one Go version and
+// architecture. It can be generated with "go run ./gen signatures" and
+// stored as JSON, optionally gzip compressed.
+type SignatureDB struct {
+	// GoVersion is the version of the compiler the signatures were
+	// generated with, for example go1.22.1.
+	GoVersion string `json:"goVersion"`
+	// Arch is the architecture, as reported in FileInfo.
+	Arch string `json:"arch"`
+	// Signatures is the list of signatures.
+	Signatures []*Signature `json:"signatures"`
+}
+
+// Signature identifies the code of a function.
+type Signature struct {
+	// Name is the full name of the function, for example strings.Index or
+	// bytes.(*Buffer).Write.
+	Name string `json:"name"`
+	// Size is the length of the normalized code.
+	Size int `json:"size"`
+	// Hash is the hash of the normalized code, see FunctionHash.
+	Hash string `json:"hash"`
+}
+
+// SignatureMatch is a function that matched a signature.
+type SignatureMatch struct {
+	// Function is the matched function.
+	Function *Function
+	// Name is the name of the signature.
+	Name string
+	// GoVersion is the version of the signature database.
+	GoVersion string
+}
+
+// NewSignatureDB generates signatures for the functions in the packages for
+// which include returns true. If include is nil, the standard library
+// packages are used. Functions with code that is shared with another function
+// are left out since they can't be told apart.
+func NewSignatureDB(f *GoFile, include func(p *Package) bool) (*SignatureDB, error) {
+	if include == nil {
+		include = func(p *Package) bool { return IsStandardLibrary(p.Name) }
+	}
+	pkgs, err := f.allPackages()
+	if err != nil {
+		return nil, err
+	}
+	var selected []*Package
+	for _, p := range pkgs {
+		if include(p) {
+			selected = append(selected, p)
+		}
+	}
+
+	byHash := make(map[string]*Signature)
+	dup := make(map[string]bool)
+	for name, fn := range packageFunctions(selected) {
+		code, err := f.Bytes(fn.Offset, fn.End-fn.Offset)
+		if err != nil {
+			continue
+		}
+		norm := normalizeCode(code, f.FileInfo)
+		if len(norm) == 0 {
+			continue
+		}
+		h := hashCode(norm)
+		if _, ok := byHash[h]; ok {
+			dup[h] = true
+			continue
+		}
+		byHash[h] = &Signature{Name: name, Size: len(norm), Hash: h}
+	}
+
+	db := &SignatureDB{GoVersion: f.goVersionName(), Arch: f.FileInfo.Arch}
+	for h, sig := range byHash {
+		if !dup[h] {
+			db.Signatures = append(db.Signatures, sig)
+		}
+	}
+	sort.Slice(db.Signatures, func(i, j int) bool {
+		return db.Signatures[i].Name < db.Signatures[j].Name
+	})
+	return db, nil
+}
+
+// ReadSignatureDB reads a signature database. The data can be gzip
+// compressed.
+func ReadSignatureDB(r io.Reader) (*SignatureDB, error) {
+	br := bufio.NewReader(r)
+	var in io.Reader = br
+	if magic, err := br.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		in = zr
+	}
+	db := &SignatureDB{}
+	if err := json.NewDecoder(in).Decode(db); err != nil {
+		return nil, fmt.Errorf("failed to parse signature database: %w", err)
+	}
+	return db, nil
+}
+
+// Write writes the database as gzip compressed JSON.
+func (db *SignatureDB) Write(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(db); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// MatchSignatures hashes the functions in the file and looks them up in the
+// signature databases. Only databases for the file's architecture are used.
+// If the Go version of the file is known and a database for it is given,
+// the other databases are skipped.
+//
+// Every function that matches a signature with a name different from its
+// own is annotated with the name in RecoveredName. Hashes that match
+// signatures with different names are ignored.
+func (f *GoFile) MatchSignatures(dbs ...*SignatureDB) ([]*SignatureMatch, error) {
+	pkgs, err := f.allPackages()
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []*SignatureDB
+	version := f.goVersionName()
+	for _, db := range dbs {
+		if db.Arch != f.FileInfo.Arch {
+			continue
+		}
+		if version != "" && db.GoVersion == version {
+			selected = []*SignatureDB{db}
+			break
+		}
+		selected = append(selected, db)
+	}
+
+	type entry struct {
+		name    string
+		version string
+	}
+	known := make(map[string]entry)
+	ambiguous := make(map[string]bool)
+	for _, db := range selected {
+		for _, sig := range db.Signatures {
+			if e, ok := known[sig.Hash]; ok && e.name != sig.Name {
+				ambiguous[sig.Hash] = true
+				continue
+			}
+			known[sig.Hash] = entry{name: sig.Name, version: db.GoVersion}
+		}
+	}
+
+	var matches []*SignatureMatch
+	for _, p := range pkgs {
+		fns := append([]*Function{}, p.Functions...)
+		for _, m := range p.Methods {
+			fns = append(fns, m.Function)
+		}
+		for _, fn := range fns {
+			h, err := f.FunctionHash(fn)
+			if err != nil || ambiguous[h] {
+				continue
+			}
+			e, ok := known[h]
+			if !ok {
+				continue
+			}
+			matches = append(matches, &SignatureMatch{Function: fn, Name: e.name, GoVersion: e.version})
+			if e.name != functionName(fn) {
+				fn.RecoveredName = e.name
+			}
+		}
+	}
+	return matches, nil
+}
+
+// goVersionName returns the name of the Go version the file was compiled
+// with, or an empty string if it is not known. The version recorded in the
+// build information is preferred since it is also known for versions newer
+// than the ones in the version table.
+func (f *GoFile) goVersionName() string {
+	if f.BuildInfo != nil && f.BuildInfo.ModInfo != nil {
+		if v := strings.Fields(f.BuildInfo.ModInfo.GoVersion); len(v) != 0 {
+			return v[0]
+		}
+	}
+	if v, err := f.GetCompilerVersion(); err == nil {
+		return v.Name
+	}
+	return ""
+}
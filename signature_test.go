@@ -0,0 +1,94 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignatureDBReadWrite(t *testing.T) {
+	db := &SignatureDB{
+		GoVersion:  "go1.22.1",
+		Arch:       ArchAMD64,
+		Signatures: []*Signature{{Name: "strings.Index", Size: 42, Hash: "00ff"}},
+	}
+	buf := &bytes.Buffer{}
+	require.NoError(t, db.Write(buf))
+	got, err := ReadSignatureDB(buf)
+	require.NoError(t, err)
+	assert.Equal(t, db, got)
+
+	got, err = ReadSignatureDB(strings.NewReader(`{"goVersion":"go1.21.0","arch":"arm64","signatures":[]}`))
+	require.NoError(t, err)
+	assert.Equal(t, "go1.21.0", got.GoVersion)
+
+	_, err = ReadSignatureDB(strings.NewReader("not json"))
+	assert.Error(t, err)
+}
+
+func TestMatchSignatures(t *testing.T) {
+	exe := buildTestBinary(t, testresourcesrc)
+	ref, err := Open(exe)
+	require.NoError(t, err)
+	defer ref.Close()
+
+	db, err := NewSignatureDB(ref, nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, db.Signatures)
+	assert.Equal(t, ArchAMD64, db.Arch)
+	names := make(map[string]bool)
+	for _, s := range db.Signatures {
+		assert.False(t, names[s.Name], "duplicate signature %s", s.Name)
+		names[s.Name] = true
+		assert.False(t, strings.HasPrefix(s.Name, "main."), s.Name)
+	}
+	assert.True(t, names["fmt.Fprintln"])
+
+	// Wipe the names like a stripping tool would.
+	f, err := Open(exe)
+	require.NoError(t, err)
+	defer f.Close()
+	std, err := f.GetSTDLib()
+	require.NoError(t, err)
+	var wiped *Function
+	for _, p := range std {
+		for _, fn := range p.Functions {
+			if fn.Func.Name == "fmt.Fprintln" {
+				wiped = fn
+			}
+			fn.Func.Name = "x.y"
+		}
+	}
+	require.NotNil(t, wiped)
+
+	other := &SignatureDB{GoVersion: db.GoVersion, Arch: ArchARM64, Signatures: []*Signature{{Name: "wrong", Hash: db.Signatures[0].Hash}}}
+	matches, err := f.MatchSignatures(other, db)
+	require.NoError(t, err)
+	// Functions outside of the standard library can have the same code.
+	assert.GreaterOrEqual(t, len(matches), len(db.Signatures))
+	assert.Equal(t, "fmt.Fprintln", wiped.RecoveredName)
+	for _, m := range matches {
+		assert.Equal(t, db.GoVersion, m.GoVersion)
+		assert.NotEqual(t, "wrong", m.Name)
+	}
+}
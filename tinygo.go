@@ -0,0 +1,469 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Binaries compiled by TinyGo don't have the PCLN table, moduledata or the
+// type structures of the gc toolchain. The functions are recovered from the
+// symbols and the DWARF data. TinyGo names its type descriptors after the
+// type they describe, so the types are recovered from the symbol names.
+
+// tinygoProducer is the DWARF producer of TinyGo.
+const tinygoProducer = "TinyGo"
+
+// tinygoTypePrefix is the prefix of the symbols of the type descriptors.
+// The rest of the name is the type code built by the compiler, for example
+// slice:named:main.T.
+const tinygoTypePrefix = "reflect/types.type:"
+
+// tinygoKinds maps the kinds of the TinyGo reflect package to the kinds of
+// the gc reflect package. TinyGo puts the kinds without pointers first.
+var tinygoKinds = [...]reflect.Kind{
+	reflect.Invalid, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16,
+	reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16,
+	reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Float32,
+	reflect.Float64, reflect.Complex64, reflect.Complex128, reflect.String,
+	reflect.UnsafePointer, reflect.Chan, reflect.Interface, reflect.Pointer,
+	reflect.Slice, reflect.Array, reflect.Func, reflect.Map, reflect.Struct,
+}
+
+// tinygoKindMask masks the kind in the first byte of a type descriptor.
+const tinygoKindMask = 0x1f
+
+var errInvalidTypeCode = errors.New("invalid type code")
+
+var tinygoNaming = nativeNaming{
+	demangle:   func(name string) string { return name },
+	isFunction: isTinyGoFunction,
+	split:      splitTinyGoName,
+}
+
+// isTinyGo returns true if the binary was compiled by TinyGo. Either the
+// DWARF data is produced by TinyGo or the binary has the symbols of the
+// TinyGo runtime.
+func (f *GoFile) isTinyGo() bool {
+	if f.hasDwarfProducer(tinygoProducer) {
+		return true
+	}
+	syms, err := f.symbols()
+	if err != nil {
+		return false
+	}
+	if _, ok := syms["runtime.initAll"]; ok {
+		return true
+	}
+	for name := range syms {
+		if strings.HasPrefix(name, "tinygo_") || strings.HasPrefix(name, tinygoTypePrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitTinyGoName splits a function name into the package, the receiver
+// type and the name. TinyGo names the methods like go/ssa, the package is
+// part of the receiver, for example (*main.T).Method. Closures are appended
+// with a dollar sign and a number, for example main.main$1.
+func splitTinyGoName(name string) (string, string, string) {
+	if end := receiverEnd(name); end > 0 {
+		recv := name[1:end]
+		ptr := strings.HasPrefix(recv, "*")
+		pkg, typ := splitTinyGoPackage(strings.TrimPrefix(recv, "*"))
+		if ptr {
+			typ = "(*" + typ + ")"
+		}
+		return pkg, typ, name[end+2:]
+	}
+	pkg, rest := splitTinyGoPackage(name)
+	return pkg, "", rest
+}
+
+// receiverEnd returns the index of the parenthesis that closes the receiver
+// of a method name, or -1 if the name is not a method.
+func receiverEnd(name string) int {
+	if !strings.HasPrefix(name, "(") {
+		return -1
+	}
+	depth := 0
+	for i, c := range name {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				if strings.HasPrefix(name[i+1:], ".") {
+					return i
+				}
+				return -1
+			}
+		}
+	}
+	return -1
+}
+
+// splitTinyGoPackage splits a qualified name into the package path and the
+// name. The type arguments of a generic are ignored, they can be qualified.
+func splitTinyGoPackage(name string) (string, string) {
+	end := strings.IndexByte(name, '[')
+	if end < 0 {
+		end = len(name)
+	}
+	start := strings.LastIndex(name[:end], "/") + 1
+	dot := strings.Index(name[start:end], ".")
+	if dot < 0 {
+		return "", name
+	}
+	return name[:start+dot], name[start+dot+1:]
+}
+
+// isTinyGoFunction returns false for the symbols of the C and assembly parts
+// of the runtime, the intrinsics and the functions the compiler generates
+// for the interface calls, which are named after a type code.
+func isTinyGoFunction(name string) bool {
+	if name == "" || name[0] == '_' || name[0] == '.' || strings.HasPrefix(name, "llvm.") {
+		return false
+	}
+	if end := receiverEnd(name); end > 0 {
+		name = name[1:end]
+	}
+	dot := strings.Index(name, ".")
+	return dot > 0 && !strings.ContainsAny(name[:dot], ":{")
+}
+
+// getTinyGoTypes returns the types described by the type descriptors of a
+// TinyGo binary. They are found by their symbols, so no types are returned
+// if the binary is stripped. WebAssembly modules have no symbols for their
+// data, so they have no types either.
+func (f *GoFile) getTinyGoTypes() (map[uint64]*GoType, error) {
+	syms, err := f.symbols()
+	if err != nil {
+		return nil, err
+	}
+
+	types := make(map[uint64]*GoType)
+	var named []*GoType
+	for name, sym := range syms {
+		code, ok := strings.CutPrefix(name, tinygoTypePrefix)
+		if !ok {
+			continue
+		}
+		// Skip the other data of a type, like its method set.
+		if i := strings.LastIndexByte(code, '$'); i >= 0 && code[i:] != "$local" {
+			continue
+		}
+		typ, err := parseTinyGoTypeCode(code)
+		if err != nil {
+			f.warn("failed to parse the TinyGo type code %q: %v", code, err)
+			continue
+		}
+		typ.Addr = sym.Value
+		types[sym.Value] = typ
+		if typ.PackagePath != "" {
+			named = append(named, typ)
+		}
+	}
+	if len(types) == 0 {
+		return nil, errNoTypeDescriptors
+	}
+
+	// The kind and the underlying type of a named type are only in the
+	// type descriptor.
+	for _, typ := range named {
+		f.resolveTinyGoNamedType(typ, types)
+	}
+	return types, nil
+}
+
+// resolveTinyGoNamedType reads the kind of a named type from its type
+// descriptor and copies the fields, methods and element types of the
+// underlying type if its descriptor is known. A named type descriptor
+// starts with the kind byte followed by the number of methods, the pointer
+// to the pointer type and the pointer to the underlying type, each aligned
+// to a word.
+func (f *GoFile) resolveTinyGoNamedType(typ *GoType, types map[uint64]*GoType) {
+	wordSize := uint64(f.FileInfo.WordSize)
+	base, data, err := f.fh.getSectionDataFromAddress(typ.Addr)
+	if err != nil || typ.Addr-base+3*wordSize > uint64(len(data)) {
+		return
+	}
+	desc := data[typ.Addr-base:]
+	kind := int(desc[0] & tinygoKindMask)
+	if kind >= len(tinygoKinds) {
+		return
+	}
+	typ.Kind = tinygoKinds[kind]
+
+	underlying, ok := types[readWord(desc[2*wordSize:], f.FileInfo)]
+	if !ok || underlying.Kind != typ.Kind {
+		return
+	}
+	switch typ.Kind {
+	case reflect.Struct:
+		typ.Fields = underlying.Fields
+	case reflect.Interface:
+		typ.Methods = underlying.Methods
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Chan, reflect.Map:
+		typ.Element, typ.Key = underlying.Element, underlying.Key
+		typ.Length, typ.ChanDir = underlying.Length, underlying.ChanDir
+	case reflect.Func:
+		typ.FuncArgs, typ.FuncReturnVals = underlying.FuncArgs, underlying.FuncReturnVals
+	}
+}
+
+// parseTinyGoTypeCode parses the type code the TinyGo compiler names a type
+// descriptor after.
+func parseTinyGoTypeCode(code string) (*GoType, error) {
+	p := &typeCodeParser{code: code}
+	typ := p.parseType()
+	if p.err == nil && p.pos != len(p.code) {
+		p.fail()
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return typ, nil
+}
+
+type typeCodeParser struct {
+	code string
+	pos  int
+	err  error
+}
+
+func (p *typeCodeParser) fail() {
+	if p.err == nil {
+		p.err = fmt.Errorf("%w at offset %d", errInvalidTypeCode, p.pos)
+	}
+}
+
+// consume skips the prefix if the code continues with it.
+func (p *typeCodeParser) consume(prefix string) bool {
+	if strings.HasPrefix(p.code[p.pos:], prefix) {
+		p.pos += len(prefix)
+		return true
+	}
+	return false
+}
+
+func (p *typeCodeParser) expect(prefix string) {
+	if !p.consume(prefix) {
+		p.fail()
+	}
+}
+
+// until returns the text up to the first of the stop characters that is not
+// within brackets.
+func (p *typeCodeParser) until(stop string) string {
+	start, depth := p.pos, 0
+	for ; p.pos < len(p.code); p.pos++ {
+		c := p.code[p.pos]
+		switch {
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case depth == 0 && strings.IndexByte(stop, c) >= 0:
+			return p.code[start:p.pos]
+		}
+	}
+	return p.code[start:]
+}
+
+func (p *typeCodeParser) parseType() *GoType {
+	if p.err != nil {
+		return &GoType{}
+	}
+	kind := p.until(":")
+	p.expect(":")
+	typ := &GoType{}
+	switch kind {
+	case "basic":
+		name := p.until(",}`")
+		typ.Kind = basicKind(name)
+		if typ.Kind == reflect.Invalid {
+			p.fail()
+		}
+		typ.Name = typ.Kind.String()
+		if typ.Kind == reflect.UnsafePointer {
+			typ.Name = "unsafe.Pointer"
+		}
+	case "named":
+		// Types declared in a function have a suffix.
+		name := strings.TrimSuffix(p.until(",}`"), "$local")
+		pkgPath, typeName := splitTinyGoPackage(name)
+		if pkgPath == "" {
+			// Predeclared types like error.
+			typ.Name = typeName
+			typ.Kind = reflect.Interface
+			break
+		}
+		typ.PackagePath = pkgPath
+		typ.Name = path.Base(pkgPath) + "." + typeName
+	case "pointer":
+		typ.Kind = reflect.Pointer
+		typ.Element = p.parseType()
+	case "slice":
+		typ.Kind = reflect.Slice
+		typ.Element = p.parseType()
+	case "array":
+		typ.Kind = reflect.Array
+		n, err := strconv.Atoi(p.until(":"))
+		if err != nil {
+			p.fail()
+		}
+		p.expect(":")
+		typ.Length = n
+		typ.Element = p.parseType()
+	case "chan":
+		typ.Kind = reflect.Chan
+		switch {
+		case p.consume("sr:"):
+			typ.ChanDir = ChanBoth
+		case p.consume("s:"):
+			typ.ChanDir = ChanSend
+		case p.consume("r:"):
+			typ.ChanDir = ChanRecv
+		default:
+			typ.ChanDir = ChanBoth
+		}
+		typ.Element = p.parseType()
+	case "map":
+		typ.Kind = reflect.Map
+		p.expect("{")
+		typ.Key = p.parseType()
+		p.expect(",")
+		typ.Element = p.parseType()
+		p.expect("}")
+	case "func":
+		typ.Kind = reflect.Func
+		typ.FuncArgs = p.parseList()
+		typ.FuncReturnVals = p.parseList()
+	case "struct":
+		typ.Kind = reflect.Struct
+		p.expect("{")
+		for p.err == nil && !p.consume("}") {
+			if len(typ.Fields) != 0 {
+				p.expect(",")
+			}
+			anon := p.consume("#")
+			name := p.until(":")
+			p.expect(":")
+			field := p.parseType()
+			field.FieldName, field.FieldAnon = name, anon
+			if p.consume("`") {
+				field.FieldTag = p.until("`")
+				p.expect("`")
+			}
+			typ.Fields = append(typ.Fields, field)
+		}
+	case "interface":
+		typ.Kind = reflect.Interface
+		p.expect("{")
+		for p.err == nil && !p.consume("}") {
+			if len(typ.Methods) != 0 {
+				p.expect(",")
+			}
+			name := p.until(":")
+			p.expect(":")
+			typ.Methods = append(typ.Methods, &TypeMethod{Name: name, Type: p.parseType()})
+		}
+	default:
+		p.fail()
+	}
+	if typ.Name == "" && p.err == nil {
+		typ.Name = typeCodeName(typ)
+	}
+	return typ
+}
+
+// parseList parses the types of the parameters or the results of a function.
+func (p *typeCodeParser) parseList() []*GoType {
+	p.expect("{")
+	var ret []*GoType
+	for p.err == nil && !p.consume("}") {
+		if len(ret) != 0 {
+			p.expect(",")
+		}
+		ret = append(ret, p.parseType())
+	}
+	return ret
+}
+
+// typeCodeName returns the name of an unnamed type like the type string of
+// the gc toolchain.
+func typeCodeName(typ *GoType) string {
+	switch typ.Kind {
+	case reflect.Struct:
+		if len(typ.Fields) == 0 {
+			return "struct {}"
+		}
+		fields := make([]string, len(typ.Fields))
+		for i, field := range typ.Fields {
+			fields[i] = field.String()
+			if !field.FieldAnon {
+				fields[i] = field.FieldName + " " + fields[i]
+			}
+			if field.FieldTag != "" {
+				fields[i] += " " + strconv.Quote(field.FieldTag)
+			}
+		}
+		return "struct { " + strings.Join(fields, "; ") + " }"
+	case reflect.Interface:
+		if len(typ.Methods) == 0 {
+			return "interface {}"
+		}
+		methods := make([]string, len(typ.Methods))
+		for i, m := range typ.Methods {
+			methods[i] = m.Name + strings.TrimPrefix(m.Type.String(), "func")
+		}
+		return "interface { " + strings.Join(methods, "; ") + " }"
+	default:
+		return typ.String()
+	}
+}
+
+// basicKind returns the kind of a predeclared type, or reflect.Invalid if the
+// name is unknown.
+func basicKind(name string) reflect.Kind {
+	switch name {
+	case "Pointer", "unsafe.Pointer":
+		return reflect.UnsafePointer
+	case "byte":
+		return reflect.Uint8
+	case "rune":
+		return reflect.Int32
+	}
+	for k := reflect.Bool; k <= reflect.Complex128; k++ {
+		if k.String() == name {
+			return k
+		}
+	}
+	if name == reflect.String.String() {
+		return reflect.String
+	}
+	return reflect.Invalid
+}
@@ -0,0 +1,95 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitTinyGoName(t *testing.T) {
+	for _, test := range []struct {
+		name, pkg, receiver, fn string
+	}{
+		{"main.main", "main", "", "main"},
+		{"main.main$1", "main", "", "main$1"},
+		{"(*main.T).Method", "main", "(*T)", "Method"},
+		{"(main.T).Method", "main", "T", "Method"},
+		{"(*github.com/user/pkg.List[github.com/user/x.T]).Push", "github.com/user/pkg", "(*List[github.com/user/x.T])", "Push"},
+		{"github.com/user/pkg.Map[github.com/user/x.T]", "github.com/user/pkg", "", "Map[github.com/user/x.T]"},
+		{"noPackage", "", "", "noPackage"},
+	} {
+		pkg, receiver, fn := splitTinyGoName(test.name)
+		assert.Equal(t, test.pkg, pkg, test.name)
+		assert.Equal(t, test.receiver, receiver, test.name)
+		assert.Equal(t, test.fn, fn, test.name)
+	}
+}
+
+func TestIsTinyGoFunction(t *testing.T) {
+	assert.True(t, isTinyGoFunction("main.main"))
+	assert.True(t, isTinyGoFunction("runtime.alloc"))
+	assert.True(t, isTinyGoFunction("(*main.T).Method"))
+	assert.False(t, isTinyGoFunction("tinygo_scanCurrentStack"))
+	assert.False(t, isTinyGoFunction("memcpy"))
+	assert.False(t, isTinyGoFunction("llvm.memcpy.p0.p0.i32"))
+	assert.False(t, isTinyGoFunction("__wasm_call_ctors"))
+	assert.False(t, isTinyGoFunction("interface:{String:func:{}{basic:string}}.String$invoke"))
+	assert.False(t, isTinyGoFunction(""))
+}
+
+func TestParseTinyGoTypeCode(t *testing.T) {
+	for code, expected := range map[string]string{
+		"basic:int":                                        "int",
+		"basic:unsafe.Pointer":                             "unsafe.Pointer",
+		"named:main.T":                                     "main.T",
+		"named:github.com/user/pkg.T$local":                "pkg.T",
+		"pointer:named:main.T":                             "*main.T",
+		"slice:basic:uint8":                                "[]uint8",
+		"array:4:basic:string":                             "[4]string",
+		"chan:r:basic:int":                                 "<-chan int",
+		"map:{basic:string,slice:basic:int}":               "map[string][]int",
+		"func:{basic:int,named:error}{}":                   "func(int, error)",
+		"func:{}{basic:string,named:error}":                "func() (string, error)",
+		"struct:{}":                                        "struct {}",
+		"interface:{}":                                     "interface {}",
+		"interface:{String:func:{}{basic:string}}":         "interface { String() string }",
+		"struct:{A:basic:int`json:\"a\"`,#T:named:main.T}": "struct { A int \"json:\\\"a\\\"\"; main.T }",
+	} {
+		typ, err := parseTinyGoTypeCode(code)
+		require.NoError(t, err, code)
+		assert.Equal(t, expected, typ.Name, code)
+	}
+
+	typ, err := parseTinyGoTypeCode("struct:{A:basic:int`json:\"a\"`,#T:named:github.com/user/pkg.T}")
+	require.NoError(t, err)
+	assert.Equal(t, reflect.Struct, typ.Kind)
+	require.Len(t, typ.Fields, 2)
+	assert.Equal(t, "A", typ.Fields[0].FieldName)
+	assert.Equal(t, `json:"a"`, typ.Fields[0].FieldTag)
+	assert.True(t, typ.Fields[1].FieldAnon)
+	assert.Equal(t, "github.com/user/pkg", typ.Fields[1].PackagePath)
+
+	for _, code := range []string{"", "basic:float", "slice:", "map:{basic:int}", "array:x:basic:int", "basic:int,"} {
+		_, err := parseTinyGoTypeCode(code)
+		assert.ErrorIs(t, err, errInvalidTypeCode, code)
+	}
+}
@@ -0,0 +1,216 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"slices"
+	"strings"
+)
+
+// boringCryptoPrefix is the prefix of the BoringCrypto module symbols.
+const boringCryptoPrefix = "_goboringcrypto_"
+
+// fips140SnapshotPrefix is the prefix of the packages of a frozen Go
+// Cryptographic Module. The version follows the prefix, for example
+// crypto/internal/fips140/v1.0.0/sha256.
+const fips140SnapshotPrefix = "crypto/internal/fips140/v"
+
+// microsoftExperiments are the GOEXPERIMENT settings that select a crypto
+// backend of the Microsoft build of Go.
+var microsoftExperiments = []string{"systemcrypto", "opensslcrypto", "cngcrypto", "darwincrypto"}
+
+// microsoftPackages are the packages of the crypto backends of the Microsoft
+// build of Go.
+var microsoftPackages = []string{
+	"crypto/internal/backend",
+	"github.com/microsoft/go-crypto-winnative",
+	"github.com/microsoft/go-crypto-darwin",
+	"github.com/golang-fips/openssl",
+}
+
+// Toolchain describes the flavour of the Go toolchain that compiled the
+// binary.
+type Toolchain struct {
+	// Version is the Go release the toolchain is based on. It's nil if the
+	// release is unknown.
+	Version *GoVersion `json:"version,omitempty"`
+	// Raw is the version string recorded in the binary, for example
+	// "go1.22.5-bigcorp X:boringcrypto".
+	Raw string `json:"raw,omitempty"`
+	// Suffix is the custom suffix a vendor appended to the release, for
+	// example "bigcorp".
+	Suffix string `json:"suffix,omitempty"`
+	// Experiments holds the GOEXPERIMENT settings that differ from the
+	// defaults of the release.
+	Experiments []string `json:"experiments,omitempty"`
+	// BoringCrypto is true if the crypto packages use the BoringCrypto
+	// module, GOEXPERIMENT=boringcrypto.
+	BoringCrypto bool `json:"boringcrypto,omitempty"`
+	// FIPS140 is the version of the Go Cryptographic Module selected with
+	// GOFIPS140, for example v1.0.0 or latest. It's empty if no module was
+	// selected.
+	FIPS140 string `json:"fips140,omitempty"`
+	// Microsoft is true if the binary was compiled by the Microsoft build
+	// of Go.
+	Microsoft bool `json:"microsoft,omitempty"`
+}
+
+// ParseToolchain parses a version string as it is recorded by the
+// toolchain. A custom suffix is separated from the release by a dash and the
+// experiments are listed after " X:", for example
+// "go1.22.5-bigcorp X:boringcrypto,loopvar".
+func ParseToolchain(raw string) *Toolchain {
+	t := &Toolchain{Raw: raw}
+	version, exps, ok := strings.Cut(raw, " X:")
+	if ok {
+		t.addExperiments(exps)
+	}
+	version, t.Suffix, _ = strings.Cut(strings.TrimSpace(version), "-")
+	t.Version = ResolveGoVersion(version)
+	if strings.Contains(strings.ToLower(t.Suffix), "microsoft") {
+		t.Microsoft = true
+	}
+	return t
+}
+
+// resolveBaseGoVersion returns the release of a version string that can have
+// a custom suffix or experiments.
+func resolveBaseGoVersion(raw string) *GoVersion {
+	return ParseToolchain(raw).Version
+}
+
+func (t *Toolchain) addExperiments(exps string) {
+	for _, e := range strings.Split(exps, ",") {
+		if e = strings.TrimSpace(e); e == "" || slices.Contains(t.Experiments, e) {
+			continue
+		}
+		t.Experiments = append(t.Experiments, e)
+		switch {
+		case e == "boringcrypto":
+			t.BoringCrypto = true
+		case slices.Contains(microsoftExperiments, e):
+			t.Microsoft = true
+		}
+	}
+}
+
+// inspectPackages detects the flavour from the packages linked into the
+// binary.
+func (t *Toolchain) inspectPackages(pkgs []*Package) {
+	for _, p := range pkgs {
+		if rest, ok := strings.CutPrefix(p.Name, fips140SnapshotPrefix); ok {
+			version, _, _ := strings.Cut(rest, "/")
+			t.FIPS140 = "v" + version
+		}
+		// The backends are vendored in the standard library.
+		pkgName := strings.TrimPrefix(p.Name, "vendor/")
+		for _, name := range microsoftPackages {
+			if pkgName == name || strings.HasPrefix(pkgName, name+"/") {
+				t.Microsoft = true
+			}
+		}
+		// The package is a stub unless the binary is linked with the
+		// BoringCrypto module, which is called with cgo.
+		if p.Name == "crypto/internal/boring" {
+			for _, fn := range p.Functions {
+				if strings.Contains(fn.Name, boringCryptoPrefix) {
+					t.BoringCrypto = true
+				}
+			}
+		}
+	}
+}
+
+// Variant returns true if the toolchain is not an unmodified Go release.
+func (t *Toolchain) Variant() bool {
+	return t.Suffix != "" || t.BoringCrypto || t.FIPS140 != "" || t.Microsoft
+}
+
+// String returns the release followed by the flavour, for example
+// "go1.22.5-bigcorp (Microsoft, BoringCrypto)".
+func (t *Toolchain) String() string {
+	name := "unknown"
+	if t.Version != nil {
+		name = t.Version.Name
+	}
+	if t.Suffix != "" {
+		name += "-" + t.Suffix
+	}
+	var flavours []string
+	if t.Microsoft {
+		flavours = append(flavours, "Microsoft")
+	}
+	if t.BoringCrypto {
+		flavours = append(flavours, "BoringCrypto")
+	}
+	if t.FIPS140 != "" {
+		flavours = append(flavours, "FIPS 140 "+t.FIPS140)
+	}
+	if len(flavours) == 0 {
+		return name
+	}
+	return name + " (" + strings.Join(flavours, ", ") + ")"
+}
+
+// Toolchain returns the description of the toolchain that compiled the
+// binary. It's based on the version string and the build settings of the
+// build information, the packages and the symbols. If the release can't be
+// determined, the version of the returned Toolchain is nil.
+func (f *GoFile) Toolchain() *Toolchain {
+	var raw string
+	if f.BuildInfo != nil && f.BuildInfo.ModInfo != nil {
+		raw = f.BuildInfo.ModInfo.GoVersion
+	}
+	if raw == "" {
+		if v, err := f.GetCompilerVersion(); err == nil {
+			raw = v.Name
+		}
+	}
+	t := ParseToolchain(raw)
+	if t.Version == nil && raw != "" {
+		t.Version, _ = f.GetCompilerVersion()
+	}
+
+	if f.BuildInfo != nil && f.BuildInfo.ModInfo != nil {
+		for _, s := range f.BuildInfo.ModInfo.Settings {
+			switch s.Key {
+			case "GOEXPERIMENT":
+				t.addExperiments(s.Value)
+			case "GOFIPS140":
+				if s.Value != "" && s.Value != "off" {
+					t.FIPS140 = s.Value
+				}
+			}
+		}
+	}
+
+	if err := f.initPackages(); err == nil {
+		for _, class := range [][]*Package{f.stdPkgs, f.generated, f.pkgs, f.vendors, f.unknown} {
+			t.inspectPackages(class)
+		}
+	}
+	if syms, err := f.symbols(); err == nil {
+		for name := range syms {
+			if strings.HasPrefix(name, boringCryptoPrefix) {
+				t.BoringCrypto = true
+				break
+			}
+		}
+	}
+	return t
+}
@@ -0,0 +1,119 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"bytes"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseToolchain(t *testing.T) {
+	for _, test := range []struct {
+		raw         string
+		version     string
+		suffix      string
+		experiments []string
+		boring      bool
+		microsoft   bool
+		str         string
+	}{
+		{raw: "go1.21.5", version: "go1.21.5", str: "go1.21.5"},
+		{raw: "go1.21.0-bigcorp", version: "go1.21.0", suffix: "bigcorp", str: "go1.21.0-bigcorp"},
+		{raw: "go1.22.5 X:boringcrypto", version: "go1.22.5", experiments: []string{"boringcrypto"}, boring: true, str: "go1.22.5 (BoringCrypto)"},
+		{raw: "go1.22.5-microsoft X:systemcrypto,loopvar", version: "go1.22.5", suffix: "microsoft", experiments: []string{"systemcrypto", "loopvar"}, microsoft: true, str: "go1.22.5-microsoft (Microsoft)"},
+		{raw: "go1.21.5 X:opensslcrypto", version: "go1.21.5", experiments: []string{"opensslcrypto"}, microsoft: true, str: "go1.21.5 (Microsoft)"},
+		{raw: "go9.99.9-custom", suffix: "custom", str: "unknown-custom"},
+	} {
+		tc := ParseToolchain(test.raw)
+		assert.Equal(t, test.raw, tc.Raw)
+		if test.version == "" {
+			assert.Nil(t, tc.Version, test.raw)
+		} else if assert.NotNil(t, tc.Version, test.raw) {
+			assert.Equal(t, test.version, tc.Version.Name, test.raw)
+		}
+		assert.Equal(t, test.suffix, tc.Suffix, test.raw)
+		assert.Equal(t, test.experiments, tc.Experiments, test.raw)
+		assert.Equal(t, test.boring, tc.BoringCrypto, test.raw)
+		assert.Equal(t, test.microsoft, tc.Microsoft, test.raw)
+		assert.Equal(t, test.str, tc.String(), test.raw)
+		assert.Equal(t, test.suffix != "" || test.boring || test.microsoft, tc.Variant(), test.raw)
+	}
+}
+
+func TestResolveBaseGoVersion(t *testing.T) {
+	for raw, expected := range map[string]string{
+		"go1.21.5":                "go1.21.5",
+		"go1.21.5-bigcorp":        "go1.21.5",
+		"go1.22.5 X:boringcrypto": "go1.22.5",
+	} {
+		v := resolveBaseGoVersion(raw)
+		require.NotNil(t, v, raw)
+		assert.Equal(t, expected, v.Name)
+	}
+	assert.Nil(t, resolveBaseGoVersion("devel"))
+}
+
+func TestToolchainInspectPackages(t *testing.T) {
+	tc := &Toolchain{}
+	tc.inspectPackages([]*Package{
+		{Name: "crypto/internal/boring", Functions: []*Function{{Name: "NewSHA256"}}},
+		{Name: "crypto/internal/fips140/v1.0.0-c2097c7c/sha256"},
+	})
+	assert.False(t, tc.BoringCrypto)
+	assert.False(t, tc.Microsoft)
+	assert.Equal(t, "v1.0.0-c2097c7c", tc.FIPS140)
+
+	tc.inspectPackages([]*Package{
+		{Name: "crypto/internal/boring", Functions: []*Function{{Name: "_Cfunc__goboringcrypto_SHA256_Init"}}},
+		{Name: "vendor/github.com/golang-fips/openssl/v2"},
+	})
+	assert.True(t, tc.BoringCrypto)
+	assert.True(t, tc.Microsoft)
+}
+
+func TestFileToolchain(t *testing.T) {
+	mod := buildWasmModule(t, []string{"main.main", "_goboringcrypto_SHA1_Init"}, []byte("go1.23.2"))
+	f, err := OpenReader(bytes.NewReader(mod))
+	require.NoError(t, err)
+	defer f.Close()
+
+	f.BuildInfo = &BuildInfo{ModInfo: &debug.BuildInfo{
+		GoVersion: "go1.23.2-bigcorp",
+		Settings: []debug.BuildSetting{
+			{Key: "GOEXPERIMENT", Value: "boringcrypto"},
+			{Key: "GOFIPS140", Value: "v1.0.0"},
+		},
+	}}
+	tc := f.Toolchain()
+	require.NotNil(t, tc.Version)
+	assert.Equal(t, "go1.23.2", tc.Version.Name)
+	assert.Equal(t, "bigcorp", tc.Suffix)
+	assert.Equal(t, []string{"boringcrypto"}, tc.Experiments)
+	assert.True(t, tc.BoringCrypto)
+	assert.Equal(t, "v1.0.0", tc.FIPS140)
+	assert.Equal(t, "go1.23.2-bigcorp (BoringCrypto, FIPS 140 v1.0.0)", tc.String())
+
+	f.BuildInfo = nil
+	tc = f.Toolchain()
+	assert.True(t, tc.BoringCrypto)
+	assert.Empty(t, tc.Suffix)
+}
@@ -20,6 +20,7 @@ package gore
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"reflect"
@@ -29,8 +30,15 @@ const (
 	intSize32            = 4
 	intSize64            = intSize32 * 2
 	kindMask             = (1 << 5) - 1
+	kindGCProg           = 1 << 6
 	tflagExtraStar uint8 = 1 << 1
 	tflagUncommon  uint8 = 1 << 0
+	// tflagRegularMemory is set since Go 1.14 if values of the type can be
+	// compared and hashed as memory.
+	tflagRegularMemory uint8 = 1 << 3
+	// tflagGCMaskOnDemand is set since Go 1.24 if the GC mask is built by
+	// the runtime when it's needed.
+	tflagGCMaskOnDemand uint8 = 1 << 4
 )
 
 type _typeField uint8
@@ -55,9 +63,23 @@ const (
 	ChanBoth = ChanRecv | ChanSend
 )
 
+// String returns the channel direction as it is written in Go code.
+func (c ChanDir) String() string {
+	switch c {
+	case ChanRecv:
+		return "<-chan"
+	case ChanSend:
+		return "chan<-"
+	case ChanBoth:
+		return "chan"
+	default:
+		return ""
+	}
+}
+
 func getTypes(fileInfo *FileInfo, f fileHandler, md moduledata) (map[uint64]*GoType, error) {
 	if GoVersionCompare(fileInfo.goversion.Name, "go1.7beta1") < 0 {
-		return getLegacyTypes(fileInfo, f, md)
+		return getLegacyTypes(fileInfo, f, md.TypelinkAddr, md.TypelinkLen)
 	}
 
 	types, err := md.Types().Data()
@@ -71,7 +93,20 @@ func getTypes(fileInfo *FileInfo, f fileHandler, md moduledata) (map[uint64]*GoT
 	}
 
 	// New parser
-	parser := newTypeParser(types, md.Types().Address, fileInfo)
+	parser, err := newTypeParser(types, md.Types().Address, fileInfo)
+	if err != nil {
+		return nil, err
+	}
+	parser.readBytes = func(address, length uint64) ([]byte, error) {
+		base, data, err := f.getSectionDataFromAddress(address)
+		if err != nil {
+			return nil, err
+		}
+		if address+length-base > uint64(len(data)) {
+			return nil, errors.New("length out of bounds")
+		}
+		return data[address-base : address+length-base], nil
+	}
 	for _, off := range typeLink {
 		typ, err := parser.parseType(uint64(off) + parser.base)
 		if err != nil || typ == nil {
@@ -81,19 +116,21 @@ func getTypes(fileInfo *FileInfo, f fileHandler, md moduledata) (map[uint64]*GoT
 	return parser.parsedTypes(), nil
 }
 
-func getLegacyTypes(fileInfo *FileInfo, f fileHandler, md moduledata) (map[uint64]*GoType, error) {
-	typelinkAddr, typelinkData, err := f.getSectionDataFromAddress(md.TypelinkAddr)
+// getLegacyTypes parses the types for binaries compiled before Go 1.7. The
+// typelinks are an array of pointers to the types.
+func getLegacyTypes(fileInfo *FileInfo, f fileHandler, typelinkAddr, typelinkLen uint64) (map[uint64]*GoType, error) {
+	sectionAddr, typelinkData, err := f.getSectionDataFromAddress(typelinkAddr)
 	if err != nil {
 		return nil, fmt.Errorf("no typelink section found: %w", err)
 	}
 	r := bytes.NewReader(typelinkData)
-	_, err = r.Seek(int64(md.TypelinkAddr)-int64(typelinkAddr), io.SeekStart)
+	_, err = r.Seek(int64(typelinkAddr)-int64(sectionAddr), io.SeekStart)
 	if err != nil {
 		return nil, err
 	}
 
 	goTypes := make(map[uint64]*GoType)
-	for i := uint64(0); i < md.TypelinkLen; i++ {
+	for i := uint64(0); i < typelinkLen; i++ {
 		// Type offsets are always *_type
 		address, err := readUIntTo64(r, fileInfo.ByteOrder, fileInfo.WordSize == intSize32)
 		if err != nil {
@@ -141,6 +178,8 @@ type GoType struct {
 	ChanDir ChanDir
 	// Key is the key type for a map.
 	Key *GoType
+	// Map holds the layout used by the runtime for a map type.
+	Map *MapLayout
 	// FuncArgs holds the argument types for the function if the type is a function kind.
 	FuncArgs []*GoType
 	// FuncReturnVals holds the return types for the function if the type is a function kind.
@@ -149,7 +188,60 @@ type GoType struct {
 	IsVariadic bool
 	// Methods holds information of the types methods.
 	Methods []*TypeMethod
-	flag    uint8
+	// Size is the size of a value of the type in bytes.
+	Size uint64
+	// PtrData is the size of the prefix of the type that can hold pointers.
+	PtrData uint64
+	// Align is the alignment of a variable of the type.
+	Align int
+	// FieldAlign is the alignment of a struct field of the type.
+	FieldAlign int
+	// FieldOffset is the offset of the field within the struct if the
+	// GoType is a struct field.
+	FieldOffset uint64
+	// GCMask holds one entry for each word in the pointer prefix of the
+	// type. An entry is true if the word holds a pointer. It's nil if the
+	// type has no pointers or if the mask isn't stored in the binary, as is
+	// the case for large types that use a GC program or, since Go 1.24, a
+	// mask built by the runtime.
+	GCMask []bool
+	// Instance is set if the type is an instantiation of a generic type.
+	Instance *Instantiation
+	flag     uint8
+}
+
+// MapLayout describes how the runtime stores the entries of a map type.
+// Before Go 1.24, maps are hash tables of buckets with 8 keys followed by
+// 8 elements. Since Go 1.24, maps are Swiss tables of groups with 8 control
+// bytes followed by 8 slots.
+type MapLayout struct {
+	// Swiss is true if the map is a Swiss table.
+	Swiss bool
+	// BucketAddr is the address of the bucket type, or the group type for a
+	// Swiss table.
+	BucketAddr uint64
+	// BucketSize is the size of a bucket or a group.
+	BucketSize uint64
+	// KeySize and ElemSize are the sizes of the key and element stored in a
+	// bucket. They are not recorded for Swiss tables.
+	KeySize  uint64
+	ElemSize uint64
+	// SlotSize is the size of a key and element slot and ElemOffset the
+	// offset of the element in the slot. They are only set for Swiss tables
+	// with interleaved keys and elements.
+	SlotSize   uint64
+	ElemOffset uint64
+	// KeysOffset and ElemsOffset are the offsets of the first key and
+	// element in a group, and KeyStride and ElemStride the distance between
+	// the keys and elements. Only set for Swiss tables.
+	KeysOffset  uint64
+	KeyStride   uint64
+	ElemsOffset uint64
+	ElemStride  uint64
+	// IndirectKey and IndirectElem are true if a pointer to the key or
+	// element is stored instead of the value.
+	IndirectKey  bool
+	IndirectElem bool
 }
 
 // String implements the fmt.Stringer interface.
@@ -341,10 +433,11 @@ func typeParse(types map[uint64]*GoType, fileInfo *FileInfo, offset uint64, sect
 	// Parse size
 	off := typeOffset(fileInfo, _typeFieldSize)
 	r.Seek(off, io.SeekStart)
-	_, err := readUIntTo64(r, fileInfo.ByteOrder, fileInfo.WordSize == intSize32)
+	size, err := readUIntTo64(r, fileInfo.ByteOrder, fileInfo.WordSize == intSize32)
 	if err != nil {
 		return nil
 	}
+	typ.Size = size
 
 	// Parse kind
 	off = typeOffset(fileInfo, _typeFieldKind)
@@ -634,7 +727,7 @@ func typeParse(types map[uint64]*GoType, fileInfo *FileInfo, offset uint64, sect
 		secR := bytes.NewReader(sectionData)
 		imethSize := uint64(2 * intSize32)
 		int32ptr := true
-		if GoVersionCompare(fileInfo.goversion.Name, "go1.7beta1") < 0 {
+		if GoVersionCompare(legacyTypeVersion(fileInfo), "go1.7beta1") < 0 {
 			imethSize = uint64(3 * fileInfo.WordSize)
 			int32ptr = fileInfo.WordSize == intSize32
 		}
@@ -765,12 +858,14 @@ func parseMethods(r *bytes.Reader, fileInfo *FileInfo, sectionData []byte, secti
 			typeParse(types, fileInfo, p-sectionBaseAddr, sectionData, sectionBaseAddr)
 		}
 
-		// ifn
-		ifn, err := readUIntTo64(r, fileInfo.ByteOrder, fileInfo.WordSize == intSize32)
-		if err != nil {
-			return nil
+		// ifn, gccgo only has the tfn.
+		if fileInfo.Compiler != CompilerGccgo {
+			ifn, err := readUIntTo64(r, fileInfo.ByteOrder, fileInfo.WordSize == intSize32)
+			if err != nil {
+				return nil
+			}
+			m.IfaceCallOffset = ifn
 		}
-		m.IfaceCallOffset = ifn
 
 		// tfn
 		tfn, err := readUIntTo64(r, fileInfo.ByteOrder, fileInfo.WordSize == intSize32)
@@ -783,12 +878,29 @@ func parseMethods(r *bytes.Reader, fileInfo *FileInfo, sectionData []byte, secti
 	return methods
 }
 
+// legacyTypeVersion returns the version whose type layout is used by the
+// legacy parser. The type descriptors of gccgo use the layout of Go 1.6.
+func legacyTypeVersion(fileInfo *FileInfo) string {
+	if fileInfo.Compiler == CompilerGccgo {
+		return "go1.6"
+	}
+	return fileInfo.goversion.Name
+}
+
 func typeOffset(fileInfo *FileInfo, field _typeField) int64 {
 	intSize := int64(intSize64)
 	if fileInfo.WordSize == intSize32 {
 		intSize = intSize32
 	}
 
+	version := legacyTypeVersion(fileInfo)
+
+	// Before Go 1.5, the type has no ptrdata field. The gc field was a
+	// single pointer before Go 1.4 and Go 1.3 added the zero field.
+	if GoVersionCompare(version, "go1.5beta1") < 0 {
+		return typeOffsetGo12(version, intSize, field)
+	}
+
 	switch field {
 	case _typeFieldSize:
 		return 0
@@ -803,10 +915,10 @@ func typeOffset(fileInfo *FileInfo, field _typeField) int64 {
 		return 2*intSize + 4
 
 	case _typeFieldEnd:
-		if GoVersionCompare(fileInfo.goversion.Name, "go1.6beta1") < 0 {
+		if GoVersionCompare(version, "go1.6beta1") < 0 {
 			return 8*intSize + 8
 		}
-		if GoVersionCompare(fileInfo.goversion.Name, "go1.7beta1") < 0 {
+		if GoVersionCompare(version, "go1.7beta1") < 0 {
 			return 7*intSize + 8
 		}
 		return 4*intSize + 16
@@ -815,3 +927,52 @@ func typeOffset(fileInfo *FileInfo, field _typeField) int64 {
 		return -1
 	}
 }
+
+/*
+Go 1.2 to Go 1.4
+type rtype struct {
+	size       uintptr 4 or 8
+	hash       uint32 4
+	_          uint8 1
+	align      uint8 1
+	fieldAlign uint8 1
+	kind       uint8 1
+	alg        *uintptr 4 or 8
+	gc         unsafe.Pointer 4 or 8, [2]unsafe.Pointer since Go 1.4
+	string     *string 4 or 8
+	*uncommonType 4 or 8
+	ptrToThis  *rtype 4 or 8
+	zero       unsafe.Pointer 4 or 8, since Go 1.3
+}
+*/
+
+func typeOffsetGo12(version string, intSize int64, field _typeField) int64 {
+	gcSize := intSize
+	if GoVersionCompare(version, "go1.4beta1") >= 0 {
+		gcSize = 2 * intSize
+	}
+
+	switch field {
+	case _typeFieldSize:
+		return 0
+
+	case _typeFieldKind:
+		return intSize + 4 + 3
+
+	case _typeFieldStr:
+		return 2*intSize + 8 + gcSize
+
+	case _typeFieldFlag:
+		// The unused byte, there are no flags.
+		return intSize + 4
+
+	case _typeFieldEnd:
+		if GoVersionCompare(version, "go1.3beta1") < 0 {
+			return 5*intSize + 8 + gcSize
+		}
+		return 6*intSize + 8 + gcSize
+
+	default:
+		return -1
+	}
+}
@@ -17,12 +17,18 @@
 
 package gore
 
+//go:generate go run ./gen types
+
 import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"reflect"
+	"strconv"
+
+	"github.com/ZxillyFork/gore/extern"
+	"github.com/ZxillyFork/gore/extern/gover"
 )
 
 /*
@@ -31,7 +37,7 @@ import (
 
 */
 
-func newTypeParser(typesData []byte, baseAddres uint64, fi *FileInfo) *typeParser {
+func newTypeParser(typesData []byte, baseAddres uint64, fi *FileInfo) (*typeParser, error) {
 	goversion := fi.goversion.Name
 
 	p := &typeParser{
@@ -44,15 +50,22 @@ func newTypeParser(typesData []byte, baseAddres uint64, fi *FileInfo) *typeParse
 		r:         bytes.NewReader(typesData),
 	}
 
+	layouts, err := selectTypeLayouts(typeLayoutVersion(goversion), fi.WordSize*8)
+	if err != nil {
+		return nil, fmt.Errorf("no type layouts for word size %d: %w", fi.WordSize, err)
+	}
+	p.parseArrayType = layouts.arrayType
+	p.parseChanType = layouts.chanType
+	p.parseFuncType = layouts.funcType
+	p.parseIMethod = layouts.imethod
+	p.parseInterface = layouts.interfaceType
+	p.parseMethod = layouts.method
+	p.parseRtype = layouts.rtype
+	p.parseStructFieldType = layouts.structField
+	p.parseStructType = layouts.structType
+	p.parseUncommon = layouts.uncommon
+
 	if fi.WordSize == 8 {
-		p.parseArrayType = arrayTypeParseFunc64
-		p.parseChanType = chanTypeParseFunc64
-		p.parseFuncType = funcTypeParseFunc64
-		p.parseIMethod = imethodTypeParseFunc64
-		p.parseInterface = interfaceTypeParseFunc64
-		p.parseRtype = rtypeParseFunc64
-		p.parseStructFieldType = structFieldTypeParseFunc64
-		p.parseStructType = structTypeParseFunc64
 		p.parseUint = readUintFunc64
 
 		// Use the correct map parser based on Go version.
@@ -62,18 +75,14 @@ func newTypeParser(typesData []byte, baseAddres uint64, fi *FileInfo) *typeParse
 			p.parseMap = mapTypeParseFunc1164
 		} else if GoVersionCompare(goversion, "go1.14beta1") < 0 {
 			p.parseMap = mapTypeParseFunc1264
-		} else {
+		} else if !swissMaps(fi) {
 			p.parseMap = mapTypeParseFunc64
+		} else if GoVersionCompare(goversion, "go1.27beta1") < 0 {
+			p.parseMap = mapTypeParseFunc2464
+		} else {
+			p.parseMap = mapTypeParseFunc2764
 		}
 	} else {
-		p.parseArrayType = arrayTypeParseFunc32
-		p.parseChanType = chanTypeParseFunc32
-		p.parseFuncType = funcTypeParseFunc32
-		p.parseIMethod = imethodTypeParseFunc64
-		p.parseInterface = interfaceTypeParseFunc32
-		p.parseRtype = rtypeParseFunc32
-		p.parseStructFieldType = structFieldTypeParseFunc32
-		p.parseStructType = structTypeParseFunc32
 		p.parseUint = readUintFunc32
 
 		// Use the correct map parser based on Go version.
@@ -83,16 +92,18 @@ func newTypeParser(typesData []byte, baseAddres uint64, fi *FileInfo) *typeParse
 			p.parseMap = mapTypeParseFunc1132
 		} else if GoVersionCompare(goversion, "go1.14beta1") < 0 {
 			p.parseMap = mapTypeParseFunc1232
-		} else {
+		} else if !swissMaps(fi) {
 			p.parseMap = mapTypeParseFunc32
+		} else if GoVersionCompare(goversion, "go1.27beta1") < 0 {
+			p.parseMap = mapTypeParseFunc2432
+		} else {
+			p.parseMap = mapTypeParseFunc2732
 		}
 	}
 
-	p.parseMethod = methodParseFunc64
+	// The uncommon type of the first beta differs from the released layout.
 	if fi.goversion.Name == "go1.7beta1" {
 		p.parseUncommon = uncommonTypeParseFunc17Beta1
-	} else {
-		p.parseUncommon = uncommonTypeParseFunc64
 	}
 
 	if GoVersionCompare(fi.goversion.Name, "go1.17beta1") < 0 {
@@ -104,7 +115,7 @@ func newTypeParser(typesData []byte, baseAddres uint64, fi *FileInfo) *typeParse
 		p.parseNameLen = nameLenParseFuncVarint
 	}
 
-	return p
+	return p, nil
 }
 
 // typeParser can parse the Go type structures for binaries compiled with the
@@ -127,6 +138,10 @@ type typeParser struct {
 	// located.
 	typesData []byte
 
+	// readBytes reads data at an address outside of the types data, for
+	// example the GC masks. It can be nil.
+	readBytes func(address, length uint64) ([]byte, error)
+
 	goversion string
 
 	// Parse functions
@@ -177,6 +192,25 @@ func (p *typeParser) resolveTag(o uint64) string {
 	return string(p.typesData[o : o+tl])
 }
 
+// gcMask decodes the pointer bitmap of the type. The bitmap has one bit for
+// each word in the pointer prefix, starting with the lowest bit.
+func (p *typeParser) gcMask(rtype rtypeGo64) []bool {
+	if p.readBytes == nil || rtype.Ptrdata == 0 || rtype.Gcdata == 0 ||
+		rtype.Kind&kindGCProg != 0 || rtype.Tflag&tflagGCMaskOnDemand != 0 {
+		return nil
+	}
+	words := rtype.Ptrdata / uint64(p.wordsize)
+	data, err := p.readBytes(rtype.Gcdata, (words+7)/8)
+	if err != nil {
+		return nil
+	}
+	mask := make([]bool, words)
+	for i := range mask {
+		mask[i] = data[i/8]&(1<<(i%8)) != 0
+	}
+	return mask
+}
+
 func (p *typeParser) readType(obj interface{}) (int, error) {
 	err := binary.Read(p.r, p.order, obj)
 	if err != nil {
@@ -230,10 +264,15 @@ func (p *typeParser) parseType(address uint64) (*GoType, error) {
 
 	// Create a new type and store it in the cache.
 	typ := &GoType{
-		Kind: reflect.Kind(rtype.Kind & kindMask),
-		flag: rtype.Tflag,
-		Addr: uint64(address),
+		Kind:       reflect.Kind(rtype.Kind & kindMask),
+		flag:       rtype.Tflag,
+		Addr:       uint64(address),
+		Size:       rtype.Size,
+		PtrData:    rtype.Ptrdata,
+		Align:      int(rtype.Align),
+		FieldAlign: int(rtype.FieldAlign),
 	}
+	typ.GCMask = p.gcMask(rtype)
 	p.cache[address] = typ
 
 	// Resolve name of the type.
@@ -313,6 +352,21 @@ func (p *typeParser) parseType(address uint64) (*GoType, error) {
 
 		child = maptyp.Elem
 		key = maptyp.Key
+		typ.Map = &MapLayout{
+			Swiss:        maptyp.Swiss,
+			BucketAddr:   maptyp.Bucket,
+			BucketSize:   maptyp.Bucketsize,
+			KeySize:      uint64(maptyp.Keysize),
+			ElemSize:     uint64(maptyp.Valuesize),
+			SlotSize:     maptyp.SlotSize,
+			ElemOffset:   maptyp.ElemOff,
+			KeysOffset:   maptyp.KeysOff,
+			KeyStride:    maptyp.KeyStride,
+			ElemsOffset:  maptyp.ElemsOff,
+			ElemStride:   maptyp.ElemStride,
+			IndirectKey:  maptyp.IndirectKey,
+			IndirectElem: maptyp.IndirectElem,
+		}
 
 	case reflect.Ptr, reflect.Slice:
 		ptr, c, err := p.parseUint(p)
@@ -584,6 +638,13 @@ func (p *typeParser) parseType(address uint64) (*GoType, error) {
 				name, nl := p.resolveName(sf.Name-p.base, 0)
 				field.FieldName = name
 
+				// From Go 1.9 to Go 1.18, the lowest bit of the offset is
+				// used to mark embedded fields.
+				field.FieldOffset = sf.OffsetEmbed
+				if GoVersionCompare(p.goversion, "go1.9beta1") >= 0 && GoVersionCompare(p.goversion, "go1.19rc1") < 0 {
+					field.FieldOffset >>= 1
+				}
+
 				if nl != 0 {
 					field.FieldTag = p.resolveTag(sf.Name - p.base)
 				}
@@ -610,128 +671,95 @@ func (p *typeParser) parseType(address uint64) (*GoType, error) {
 */
 
 // The following functions are used to parse architecture specific data
-// structures in the binary.
-
-// array
-
-type arrayTypeParseFunc func(p *typeParser) (arrayType64, int, error)
-
-var arrayTypeParseFunc64 = func(p *typeParser) (arrayType64, int, error) {
-	var typ arrayType64
-	c, err := p.readType(&typ)
-	return typ, c, err
-}
-
-var arrayTypeParseFunc32 = func(p *typeParser) (arrayType64, int, error) {
-	var typ arrayType32
+// structures in the binary. Most of them are generated from the Go sources,
+// see typelayout_gen.go, and selected by selectTypeLayouts.
+
+// typeLayouts holds the parse functions for the layouts of a Go version and
+// word size.
+type typeLayouts struct {
+	rtype         rtypeParseFunc
+	uncommon      uncommonTypeParseFunc
+	method        methodParseFunc
+	imethod       imethodTypeParseFunc
+	arrayType     arrayTypeParseFunc
+	chanType      chanTypeParseFunc
+	funcType      funcTypeParseFunc
+	interfaceType interfaceTypeParseFunc
+	structType    structTypeParseFunc
+	structField   structFieldTypeParseFunc
+}
+
+// typeLayout is a structure as it's stored in the binary. It's converted
+// to the type used by the parser.
+type typeLayout[T any] interface {
+	convert() T
+}
+
+// readTypeLayout reads the layout L from the binary and converts it to T.
+func readTypeLayout[L typeLayout[T], T any](p *typeParser) (T, int, error) {
+	var typ L
 	c, err := p.readType(&typ)
 	if err != nil {
-		return arrayType64{}, c, err
+		var zero T
+		return zero, c, err
 	}
-
-	return arrayType64{
-		Eem:   uint64(typ.Eem),
-		Slice: uint64(typ.Slice),
-		Len:   uint64(typ.Len),
-	}, c, err
-}
-
-// channel
-
-type chanTypeParseFunc func(p *typeParser) (chanType, int, error)
-
-var chanTypeParseFunc64 = func(p *typeParser) (chanType, int, error) {
-	var typ chanType
-	c, err := p.readType(&typ)
-	return typ, c, err
+	return typ.convert(), c, nil
 }
 
-var chanTypeParseFunc32 = func(p *typeParser) (chanType, int, error) {
-	var typ chanType32
-	c, err := p.readType(&typ)
-	if err != nil {
-		return chanType{}, c, err
+// typeLayoutVersion returns the minor version whose type layouts should be
+// used for the Go version. Versions newer than the generated layouts use the
+// newest ones.
+func typeLayoutVersion(goversion string) int {
+	v, err := strconv.Atoi(gover.Parse(extern.StripGo(goversion)).Minor)
+	if err != nil || v < 7 {
+		return 7
 	}
-
-	return chanType{
-		Elem: uint64(typ.Elem),
-		Dir:  uint64(typ.Dir),
-	}, c, err
+	return min(v, newestTypeLayoutVersion)
 }
 
-// func
+// array
 
-type funcTypeParseFunc func(p *typeParser) (funcType, int, error)
+type arrayTypeParseFunc func(p *typeParser) (arrayType64, int, error)
 
-var funcTypeParseFunc64 = func(p *typeParser) (funcType, int, error) {
-	var typ funcType64
-	c, err := p.readType(&typ)
-	if err != nil {
-		return funcType{}, c, err
-	}
+// channel
 
-	return funcType{
-		InCount:  uint64(typ.InCount),
-		OutCount: uint64(typ.OutCount),
-	}, c, err
-}
+type chanTypeParseFunc func(p *typeParser) (chanType, int, error)
 
-var funcTypeParseFunc32 = func(p *typeParser) (funcType, int, error) {
-	var typ funcType32
-	c, err := p.readType(&typ)
-	if err != nil {
-		return funcType{}, c, err
-	}
+// func
 
-	return funcType{
-		InCount:  uint64(typ.InCount),
-		OutCount: uint64(typ.OutCount),
-	}, c, err
-}
+type funcTypeParseFunc func(p *typeParser) (funcType, int, error)
 
 // imethod
 
 type imethodTypeParseFunc func(p *typeParser) (imethod, int, error)
 
-var imethodTypeParseFunc64 = func(p *typeParser) (imethod, int, error) {
-	var typ imethod
-	c, err := p.readType(&typ)
-	return typ, c, err
-}
-
 // interface
 
 type interfaceTypeParseFunc func(p *typeParser) (interfaceType, int, error)
 
-var interfaceTypeParseFunc64 = func(p *typeParser) (interfaceType, int, error) {
-	var typ interfaceType
-	c, err := p.readType(&typ)
-	return typ, c, err
-}
-
-var interfaceTypeParseFunc32 = func(p *typeParser) (interfaceType, int, error) {
-	var typ interfaceType32
-	c, err := p.readType(&typ)
-	if err != nil {
-		return interfaceType{}, c, err
-	}
-
-	return interfaceType{
-		PkgPath:    uint64(typ.PkgPath),
-		Methods:    uint64(typ.Methods),
-		MethodsLen: uint64(typ.MethodsLen),
-		MethodsCap: uint64(typ.MethodsCap),
-	}, c, err
-}
-
 // map
 
 type mapTypeParseFunc func(p *typeParser) (mapType, int, error)
 
 var mapTypeParseFunc64 = func(p *typeParser) (mapType, int, error) {
-	var typ mapType
+	var typ mapType64
 	c, err := p.readType(&typ)
-	return typ, c, err
+	if err != nil {
+		return mapType{}, c, err
+	}
+
+	return mapType{
+		Key:          typ.Key,
+		Elem:         typ.Elem,
+		Bucket:       typ.Bucket,
+		Hasher:       typ.Hasher,
+		Keysize:      typ.Keysize,
+		Valuesize:    typ.Valuesize,
+		Bucketsize:   uint64(typ.Bucketsize),
+		Flags:        typ.Flags,
+		IndirectKey:  typ.Flags&mapFlagIndirectKey != 0,
+		IndirectElem: typ.Flags&mapFlagIndirectElem != 0,
+	}, c, err
 }
 
 var mapTypeParseFunc32 = func(p *typeParser) (mapType, int, error) {
@@ -742,14 +770,16 @@ var mapTypeParseFunc32 = func(p *typeParser) (mapType, int, error) {
 	}
 
 	return mapType{
-		Key:        uint64(typ.Key),
-		Elem:       uint64(typ.Elem),
-		Bucket:     uint64(typ.Bucket),
-		Hasher:     uint64(typ.Hasher),
-		Keysize:    typ.Keysize,
-		Valuesize:  typ.Valuesize,
-		Bucketsize: typ.Bucketsize,
-		Flags:      typ.Flags,
+		Key:          uint64(typ.Key),
+		Elem:         uint64(typ.Elem),
+		Bucket:       uint64(typ.Bucket),
+		Hasher:       uint64(typ.Hasher),
+		Keysize:      typ.Keysize,
+		Valuesize:    typ.Valuesize,
+		Bucketsize:   uint64(typ.Bucketsize),
+		Flags:        typ.Flags,
+		IndirectKey:  typ.Flags&mapFlagIndirectKey != 0,
+		IndirectElem: typ.Flags&mapFlagIndirectElem != 0,
 	}, c, err
 }
 
@@ -762,12 +792,14 @@ var mapTypeParseFunc1764 = func(p *typeParser) (mapType, int, error) {
 	}
 
 	return mapType{
-		Key:        typ.Key,
-		Elem:       typ.Elem,
-		Bucket:     typ.Bucket,
-		Keysize:    typ.Keysize,
-		Valuesize:  typ.Valuesize,
-		Bucketsize: typ.Bucketsize,
+		Key:          typ.Key,
+		Elem:         typ.Elem,
+		Bucket:       typ.Bucket,
+		Keysize:      typ.Keysize,
+		Valuesize:    typ.Valuesize,
+		Bucketsize:   uint64(typ.Bucketsize),
+		IndirectKey:  typ.Indirectkey != 0,
+		IndirectElem: typ.Indirectvalue != 0,
 	}, c, err
 }
 
@@ -780,12 +812,14 @@ var mapTypeParseFunc1732 = func(p *typeParser) (mapType, int, error) {
 	}
 
 	return mapType{
-		Key:        uint64(typ.Key),
-		Elem:       uint64(typ.Elem),
-		Bucket:     uint64(typ.Bucket),
-		Keysize:    typ.Keysize,
-		Valuesize:  typ.Valuesize,
-		Bucketsize: typ.Bucketsize,
+		Key:          uint64(typ.Key),
+		Elem:         uint64(typ.Elem),
+		Bucket:       uint64(typ.Bucket),
+		Keysize:      typ.Keysize,
+		Valuesize:    typ.Valuesize,
+		Bucketsize:   uint64(typ.Bucketsize),
+		IndirectKey:  typ.Indirectkey != 0,
+		IndirectElem: typ.Indirectvalue != 0,
 	}, c, err
 }
 
@@ -798,12 +832,14 @@ var mapTypeParseFunc1164 = func(p *typeParser) (mapType, int, error) {
 	}
 
 	return mapType{
-		Key:        typ.Key,
-		Elem:       typ.Elem,
-		Bucket:     typ.Bucket,
-		Keysize:    typ.Keysize,
-		Valuesize:  typ.Valuesize,
-		Bucketsize: typ.Bucketsize,
+		Key:          typ.Key,
+		Elem:         typ.Elem,
+		Bucket:       typ.Bucket,
+		Keysize:      typ.Keysize,
+		Valuesize:    typ.Valuesize,
+		Bucketsize:   uint64(typ.Bucketsize),
+		IndirectKey:  typ.Indirectkey != 0,
+		IndirectElem: typ.Indirectvalue != 0,
 	}, c, err
 }
 
@@ -816,12 +852,14 @@ var mapTypeParseFunc1132 = func(p *typeParser) (mapType, int, error) {
 	}
 
 	return mapType{
-		Key:        uint64(typ.Key),
-		Elem:       uint64(typ.Elem),
-		Bucket:     uint64(typ.Bucket),
-		Keysize:    typ.Keysize,
-		Valuesize:  typ.Valuesize,
-		Bucketsize: typ.Bucketsize,
+		Key:          uint64(typ.Key),
+		Elem:         uint64(typ.Elem),
+		Bucket:       uint64(typ.Bucket),
+		Keysize:      typ.Keysize,
+		Valuesize:    typ.Valuesize,
+		Bucketsize:   uint64(typ.Bucketsize),
+		IndirectKey:  typ.Indirectkey != 0,
+		IndirectElem: typ.Indirectvalue != 0,
 	}, c, err
 }
 
@@ -834,12 +872,15 @@ var mapTypeParseFunc1264 = func(p *typeParser) (mapType, int, error) {
 	}
 
 	return mapType{
-		Key:        typ.Key,
-		Elem:       typ.Elem,
-		Bucket:     typ.Bucket,
-		Keysize:    typ.Keysize,
-		Valuesize:  typ.Valuesize,
-		Bucketsize: typ.Bucketsize,
+		Key:          typ.Key,
+		Elem:         typ.Elem,
+		Bucket:       typ.Bucket,
+		Keysize:      typ.Keysize,
+		Valuesize:    typ.Valuesize,
+		Bucketsize:   uint64(typ.Bucketsize),
+		Flags:        typ.Flags,
+		IndirectKey:  typ.Flags&mapFlagIndirectKey != 0,
+		IndirectElem: typ.Flags&mapFlagIndirectElem != 0,
 	}, c, err
 }
 
@@ -852,109 +893,134 @@ var mapTypeParseFunc1232 = func(p *typeParser) (mapType, int, error) {
 	}
 
 	return mapType{
-		Key:        uint64(typ.Key),
-		Elem:       uint64(typ.Elem),
-		Bucket:     uint64(typ.Bucket),
-		Keysize:    typ.Keysize,
-		Valuesize:  typ.Valuesize,
-		Bucketsize: typ.Bucketsize,
+		Key:          uint64(typ.Key),
+		Elem:         uint64(typ.Elem),
+		Bucket:       uint64(typ.Bucket),
+		Keysize:      typ.Keysize,
+		Valuesize:    typ.Valuesize,
+		Bucketsize:   uint64(typ.Bucketsize),
+		Flags:        typ.Flags,
+		IndirectKey:  typ.Flags&mapFlagIndirectKey != 0,
+		IndirectElem: typ.Flags&mapFlagIndirectElem != 0,
 	}, c, err
 }
 
-// method
-
-type methodParseFunc func(p *typeParser) (method, int, error)
-
-var methodParseFunc64 = func(p *typeParser) (method, int, error) {
-	var typ method
+// Map parser for the Swiss table maps in Go 1.24 to 1.26 (64 bit)
+var mapTypeParseFunc2464 = func(p *typeParser) (mapType, int, error) {
+	var typ mapTypeGo2464
 	c, err := p.readType(&typ)
 	if err != nil {
-		return method{}, c, err
+		return mapType{}, c, err
 	}
 
-	return typ, c, err
-}
-
-// rtype
-
-type rtypeParseFunc func(p *typeParser) (rtypeGo64, int, error)
-
-var rtypeParseFunc64 = func(p *typeParser) (rtypeGo64, int, error) {
-	var typ rtypeGo64
-	c, err := p.readType(&typ)
-	return typ, c, err
+	return newSwissMapType(typ.Key, typ.Elem, typ.Group, typ.Hasher, typ.GroupSize, typ.Flags,
+		swissGroupSlotsOffset, typ.SlotSize, swissGroupSlotsOffset+typ.ElemOff, typ.SlotSize, typ.ElemOff), c, err
 }
 
-var rtypeParseFunc32 = func(p *typeParser) (rtypeGo64, int, error) {
-	var typ rtypeGo32
+// Map parser for the Swiss table maps in Go 1.24 to 1.26 (32 bit)
+var mapTypeParseFunc2432 = func(p *typeParser) (mapType, int, error) {
+	var typ mapTypeGo2432
 	c, err := p.readType(&typ)
 	if err != nil {
-		return rtypeGo64{}, c, err
+		return mapType{}, c, err
 	}
 
-	return rtypeGo64{
-		Size:       uint64(typ.Size),
-		Ptrdata:    uint64(typ.Ptrdata),
-		Hash:       typ.Hash,
-		Tflag:      typ.Tflag,
-		Align:      typ.Align,
-		FieldAlign: typ.FieldAlign,
-		Kind:       typ.Kind,
-		Equal:      uint64(typ.Equal),
-		Gcdata:     uint64(typ.Gcdata),
-		Str:        typ.Str,
-		PtrToThis:  typ.PtrToThis,
-	}, c, err
+	return newSwissMapType(uint64(typ.Key), uint64(typ.Elem), uint64(typ.Group), uint64(typ.Hasher), uint64(typ.GroupSize), typ.Flags,
+		swissGroupSlotsOffset, uint64(typ.SlotSize), swissGroupSlotsOffset+uint64(typ.ElemOff), uint64(typ.SlotSize), uint64(typ.ElemOff)), c, err
 }
 
-// struct
-
-type structTypeParseFunc func(p *typeParser) (structType64, int, error)
-
-var structTypeParseFunc64 = func(p *typeParser) (structType64, int, error) {
-	var typ structType64
+// Map parser for Go 1.27 and newer, where the group can either hold key and
+// element slots or separate key and element arrays (64 bit)
+var mapTypeParseFunc2764 = func(p *typeParser) (mapType, int, error) {
+	var typ mapTypeGo2764
 	c, err := p.readType(&typ)
-	return typ, c, err
+	if err != nil {
+		return mapType{}, c, err
+	}
+
+	return newSwissMapType(typ.Key, typ.Elem, typ.Group, typ.Hasher, typ.GroupSize, typ.Flags,
+		typ.KeysOff, typ.KeyStride, typ.ElemsOff, typ.ElemStride, typ.ElemOff), c, err
 }
 
-var structTypeParseFunc32 = func(p *typeParser) (structType64, int, error) {
-	var typ structType32
+// Map parser for Go 1.27 and newer (32 bit)
+var mapTypeParseFunc2732 = func(p *typeParser) (mapType, int, error) {
+	var typ mapTypeGo2732
 	c, err := p.readType(&typ)
 	if err != nil {
-		return structType64{}, c, err
+		return mapType{}, c, err
 	}
 
-	return structType64{
-		PkgPath:    uint64(typ.PkgPath),
-		FieldsData: uint64(typ.FieldsData),
-		FieldsLen:  uint64(typ.FieldsLen),
-		FieldsCap:  uint64(typ.FieldsCap),
-	}, c, nil
+	return newSwissMapType(uint64(typ.Key), uint64(typ.Elem), uint64(typ.Group), uint64(typ.Hasher), uint64(typ.GroupSize), typ.Flags,
+		uint64(typ.KeysOff), uint64(typ.KeyStride), uint64(typ.ElemsOff), uint64(typ.ElemStride), uint64(typ.ElemOff)), c, err
+}
+
+func newSwissMapType(key, elem, group, hasher, groupSize uint64, flags uint32, keysOff, keyStride, elemsOff, elemStride, elemOff uint64) mapType {
+	m := mapType{
+		Key:          key,
+		Elem:         elem,
+		Bucket:       group,
+		Hasher:       hasher,
+		Bucketsize:   groupSize,
+		Flags:        flags,
+		Swiss:        true,
+		KeysOff:      keysOff,
+		KeyStride:    keyStride,
+		ElemsOff:     elemsOff,
+		ElemStride:   elemStride,
+		IndirectKey:  flags&swissMapFlagIndirectKey != 0,
+		IndirectElem: flags&swissMapFlagIndirectElem != 0,
+	}
+	if interleavedSlots(keysOff, keyStride, elemsOff, elemStride, elemOff) {
+		m.SlotSize = keyStride
+		m.ElemOff = elemOff
+	}
+	return m
+}
+
+// interleavedSlots returns true if the keys and the elements of a Swiss
+// table group are interleaved in slots. The key and element strides are then
+// the slot size and the elements follow the keys within the slot. A split
+// group can have equal strides too, for example map[int]int, but its
+// elements follow the key array. The element offset alone can't tell them
+// apart, it's 0 in a split group and in a slot with a zero-size key.
+func interleavedSlots(keysOff, keyStride, elemsOff, elemStride, elemOff uint64) bool {
+	return keyStride == elemStride && elemsOff == keysOff+elemOff
+}
+
+// swissMaps returns true if the maps in the binary are Swiss tables. They
+// replaced the bucket based maps in Go 1.24. Until Go 1.26, the old maps
+// could be selected with GOEXPERIMENT=noswissmap.
+func swissMaps(fi *FileInfo) bool {
+	v := fi.goversion.Name
+	if GoVersionCompare(v, "go1.24beta1") < 0 {
+		return false
+	}
+	if GoVersionCompare(v, "go1.26beta1") >= 0 {
+		return true
+	}
+	for _, e := range fi.experiments {
+		if e == "noswissmap" {
+			return false
+		}
+	}
+	return true
 }
 
-// struct field
+// method
 
-type structFieldTypeParseFunc func(p *typeParser) (structField, int, error)
+type methodParseFunc func(p *typeParser) (method, int, error)
 
-var structFieldTypeParseFunc64 = func(p *typeParser) (structField, int, error) {
-	var typ structField
-	c, err := p.readType(&typ)
-	return typ, c, err
-}
+// rtype
 
-var structFieldTypeParseFunc32 = func(p *typeParser) (structField, int, error) {
-	var typ structField32
-	c, err := p.readType(&typ)
-	if err != nil {
-		return structField{}, c, err
-	}
+type rtypeParseFunc func(p *typeParser) (rtypeGo64, int, error)
 
-	return structField{
-		Name:        uint64(typ.Name),
-		Typ:         uint64(typ.Typ),
-		OffsetEmbed: uint64(typ.OffsetEmbed),
-	}, c, nil
-}
+// struct
+
+type structTypeParseFunc func(p *typeParser) (structType64, int, error)
+
+// struct field
+
+type structFieldTypeParseFunc func(p *typeParser) (structField, int, error)
 
 // uintptr
 
@@ -977,12 +1043,6 @@ var readUintFunc32 = func(p *typeParser) (uint64, int, error) {
 
 type uncommonTypeParseFunc func(p *typeParser) (uncommonType, int, error)
 
-var uncommonTypeParseFunc64 = func(p *typeParser) (uncommonType, int, error) {
-	var typ uncommonType
-	c, err := p.readType(&typ)
-	return typ, c, err
-}
-
 var uncommonTypeParseFunc17Beta1 = func(p *typeParser) (uncommonType, int, error) {
 	var typ uncommonTypeGo1_7beta1
 	c, err := p.readType(&typ)
@@ -1023,42 +1083,17 @@ type rtypeGo64 struct {
 	PtrToThis  int32
 }
 
-type rtypeGo32 struct {
-	Size       uint32
-	Ptrdata    uint32
-	Hash       uint32
-	Tflag      uint8
-	Align      uint8
-	FieldAlign uint8
-	Kind       uint8
-	Equal      uint32 //func(uint32, uint32) bool
-	Gcdata     uint32
-	Str        int32
-	PtrToThis  int32
-}
-
 type arrayType64 struct {
 	Eem   uint64
 	Slice uint64
 	Len   uint64
 }
 
-type arrayType32 struct {
-	Eem   uint32
-	Slice uint32
-	Len   uint32
-}
-
 type chanType struct {
 	Elem uint64
 	Dir  uint64
 }
 
-type chanType32 struct {
-	Elem uint32
-	Dir  uint32
-}
-
 // funcType is a unified type for both the current funcTypes. This type is
 // returned by the parse function while the other funcTypes are used to read
 // the data from the binary.
@@ -1069,17 +1104,6 @@ type funcType struct {
 	OutCount uint64
 }
 
-type funcType64 struct {
-	InCount  uint16
-	OutCount uint16
-	_        uint32 // padding
-}
-
-type funcType32 struct {
-	InCount  uint16
-	OutCount uint16
-}
-
 type imethod struct {
 	Name int32
 	Typ  int32
@@ -1092,15 +1116,52 @@ type interfaceType struct {
 	MethodsCap uint64
 }
 
-type interfaceType32 struct {
-	PkgPath    uint32
-	Methods    uint32
-	MethodsLen uint32
-	MethodsCap uint32
+// mapType holds the information of a map type for all map
+// implementations.
+type mapType struct {
+	Key    uint64
+	Elem   uint64
+	Bucket uint64
+	Hasher uint64
+	// Keysize and Valuesize are only recorded by the bucket based maps.
+	Keysize    uint8
+	Valuesize  uint8
+	Bucketsize uint64
+	Flags      uint32
+
+	IndirectKey  bool
+	IndirectElem bool
+
+	// Swiss is true if the map is a Swiss table. Bucket and Bucketsize are
+	// then the group type and size.
+	Swiss      bool
+	KeysOff    uint64
+	KeyStride  uint64
+	ElemsOff   uint64
+	ElemStride uint64
+	SlotSize   uint64
+	ElemOff    uint64
 }
 
-// Map structure used for Go 1.14 to current.
-type mapType struct {
+// Flags of the bucket based maps, since Go 1.12.
+const (
+	mapFlagIndirectKey  = 1 << 0
+	mapFlagIndirectElem = 1 << 1
+)
+
+// Flags of the Swiss table maps.
+const (
+	swissMapFlagIndirectKey  = 1 << 2
+	swissMapFlagIndirectElem = 1 << 3
+)
+
+// swissGroupSlotsOffset is the offset of the slots in a group, after the
+// 8 control bytes.
+const swissGroupSlotsOffset = 8
+
+// Map structure used for Go 1.14 to Go 1.23, and later with
+// GOEXPERIMENT=noswissmap.
+type mapType64 struct {
 	Key        uint64
 	Elem       uint64
 	Bucket     uint64
@@ -1122,6 +1183,58 @@ type mapType32 struct {
 	Flags      uint32
 }
 
+// Map structure used for the Swiss table maps in Go 1.24 to Go 1.26
+type mapTypeGo2464 struct {
+	Key       uint64
+	Elem      uint64
+	Group     uint64
+	Hasher    uint64
+	GroupSize uint64
+	SlotSize  uint64
+	ElemOff   uint64
+	Flags     uint32
+}
+
+type mapTypeGo2432 struct {
+	Key       uint32
+	Elem      uint32
+	Group     uint32
+	Hasher    uint32
+	GroupSize uint32
+	SlotSize  uint32
+	ElemOff   uint32
+	Flags     uint32
+}
+
+// Map structure used for Go 1.27 and newer
+type mapTypeGo2764 struct {
+	Key        uint64
+	Elem       uint64
+	Group      uint64
+	Hasher     uint64
+	GroupSize  uint64
+	KeysOff    uint64
+	KeyStride  uint64
+	ElemsOff   uint64
+	ElemStride uint64
+	ElemOff    uint64
+	Flags      uint32
+}
+
+type mapTypeGo2732 struct {
+	Key        uint32
+	Elem       uint32
+	Group      uint32
+	Hasher     uint32
+	GroupSize  uint32
+	KeysOff    uint32
+	KeyStride  uint32
+	ElemsOff   uint32
+	ElemStride uint32
+	ElemOff    uint32
+	Flags      uint32
+}
+
 // Map structure for Go 1.7 to Go 1.10
 type mapTypeGo1764 struct {
 	Key           uint64
@@ -1214,19 +1327,6 @@ type structType64 struct {
 	FieldsCap  uint64
 }
 
-type structType32 struct {
-	PkgPath    uint32
-	FieldsData uint32
-	FieldsLen  uint32
-	FieldsCap  uint32
-}
-
-type structField32 struct {
-	Name        uint32
-	Typ         uint32
-	OffsetEmbed uint32
-}
-
 type structField struct {
 	Name        uint64
 	Typ         uint64
@@ -0,0 +1,279 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwissMaps(t *testing.T) {
+	tests := []struct {
+		version     string
+		experiments []string
+		expected    bool
+	}{
+		{"go1.23.4", nil, false},
+		{"go1.24.0", nil, true},
+		{"go1.24rc1", nil, true},
+		{"go1.24.0", []string{"noswissmap"}, false},
+		{"go1.25.1", []string{"boringcrypto", "noswissmap"}, false},
+		{"go1.26.0", []string{"noswissmap"}, true},
+	}
+	for _, test := range tests {
+		fi := &FileInfo{goversion: &GoVersion{Name: test.version}, experiments: test.experiments}
+		assert.Equal(t, test.expected, swissMaps(fi), test.version)
+	}
+}
+
+func TestGoExperiments(t *testing.T) {
+	info := &debug.BuildInfo{
+		GoVersion: "go1.24.2 X:noswissmap,boringcrypto",
+		Settings:  []debug.BuildSetting{{Key: "GOEXPERIMENT", Value: "noswissmap"}},
+	}
+	assert.Equal(t, []string{"noswissmap", "boringcrypto", "noswissmap"}, goExperiments(info))
+	assert.Empty(t, goExperiments(&debug.BuildInfo{GoVersion: "go1.24.2"}))
+}
+
+func TestParseMapTypes(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     string
+		experiments []string
+		wordsize    int
+		data        any
+		expected    mapType
+	}{
+		{
+			"bucket map",
+			"go1.23",
+			nil,
+			intSize64,
+			mapType64{Key: 1, Elem: 2, Bucket: 3, Hasher: 4, Keysize: 16, Valuesize: 8, Bucketsize: 208, Flags: 0b10},
+			mapType{Key: 1, Elem: 2, Bucket: 3, Hasher: 4, Keysize: 16, Valuesize: 8, Bucketsize: 208, Flags: 0b10, IndirectElem: true},
+		},
+		{
+			"bucket map go1.10",
+			"go1.10",
+			nil,
+			intSize32,
+			mapTypeGo1732{Key: 1, Elem: 2, Bucket: 3, Hmap: 4, Keysize: 4, Indirectkey: 1, Valuesize: 4, Bucketsize: 72},
+			mapType{Key: 1, Elem: 2, Bucket: 3, Keysize: 4, Valuesize: 4, Bucketsize: 72, IndirectKey: true},
+		},
+		{
+			"noswissmap",
+			"go1.24.0",
+			[]string{"noswissmap"},
+			intSize64,
+			mapType64{Key: 1, Elem: 2, Bucket: 3, Hasher: 4, Keysize: 16, Valuesize: 8, Bucketsize: 208},
+			mapType{Key: 1, Elem: 2, Bucket: 3, Hasher: 4, Keysize: 16, Valuesize: 8, Bucketsize: 208},
+		},
+		{
+			"swiss map",
+			"go1.24.0",
+			nil,
+			intSize64,
+			mapTypeGo2464{Key: 1, Elem: 2, Group: 3, Hasher: 4, GroupSize: 200, SlotSize: 24, ElemOff: 16, Flags: 0b1000},
+			mapType{
+				Key: 1, Elem: 2, Bucket: 3, Hasher: 4, Bucketsize: 200, Flags: 0b1000, IndirectElem: true, Swiss: true,
+				KeysOff: 8, KeyStride: 24, ElemsOff: 24, ElemStride: 24, SlotSize: 24, ElemOff: 16,
+			},
+		},
+		{
+			"swiss map 32 bit",
+			"go1.25.3",
+			nil,
+			intSize32,
+			mapTypeGo2432{Key: 1, Elem: 2, Group: 3, Hasher: 4, GroupSize: 136, SlotSize: 16, ElemOff: 8, Flags: 0b100},
+			mapType{
+				Key: 1, Elem: 2, Bucket: 3, Hasher: 4, Bucketsize: 136, Flags: 0b100, IndirectKey: true, Swiss: true,
+				KeysOff: 8, KeyStride: 16, ElemsOff: 16, ElemStride: 16, SlotSize: 16, ElemOff: 8,
+			},
+		},
+		{
+			"split group",
+			"go1.27.0",
+			[]string{"mapsplitgroup"},
+			intSize64,
+			mapTypeGo2764{Key: 1, Elem: 2, Group: 3, Hasher: 4, GroupSize: 200, KeysOff: 8, KeyStride: 16, ElemsOff: 136, ElemStride: 8},
+			mapType{
+				Key: 1, Elem: 2, Bucket: 3, Hasher: 4, Bucketsize: 200, Swiss: true,
+				KeysOff: 8, KeyStride: 16, ElemsOff: 136, ElemStride: 8,
+			},
+		},
+		{
+			// map[int]int, the strides are equal but the group is split.
+			"split group equal strides",
+			"go1.27.0",
+			[]string{"mapsplitgroup"},
+			intSize64,
+			mapTypeGo2764{Key: 1, Elem: 2, Group: 3, Hasher: 4, GroupSize: 136, KeysOff: 8, KeyStride: 8, ElemsOff: 72, ElemStride: 8},
+			mapType{
+				Key: 1, Elem: 2, Bucket: 3, Hasher: 4, Bucketsize: 136, Swiss: true,
+				KeysOff: 8, KeyStride: 8, ElemsOff: 72, ElemStride: 8,
+			},
+		},
+		{
+			"interleaved slots",
+			"go1.27.0",
+			nil,
+			intSize64,
+			mapTypeGo2764{Key: 1, Elem: 2, Group: 3, Hasher: 4, GroupSize: 136, KeysOff: 8, KeyStride: 16, ElemsOff: 16, ElemStride: 16, ElemOff: 8},
+			mapType{
+				Key: 1, Elem: 2, Bucket: 3, Hasher: 4, Bucketsize: 136, Swiss: true,
+				KeysOff: 8, KeyStride: 16, ElemsOff: 16, ElemStride: 16, SlotSize: 16, ElemOff: 8,
+			},
+		},
+		{
+			// map[struct{}]int, the element is at the start of the slot.
+			"interleaved slots zero-size key",
+			"go1.27.0",
+			nil,
+			intSize64,
+			mapTypeGo2764{Key: 1, Elem: 2, Group: 3, Hasher: 4, GroupSize: 72, KeysOff: 8, KeyStride: 8, ElemsOff: 8, ElemStride: 8},
+			mapType{
+				Key: 1, Elem: 2, Bucket: 3, Hasher: 4, Bucketsize: 72, Swiss: true,
+				KeysOff: 8, KeyStride: 8, ElemsOff: 8, ElemStride: 8, SlotSize: 8,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, binary.Write(&buf, binary.LittleEndian, test.data))
+
+			fi := &FileInfo{
+				ByteOrder:   binary.LittleEndian,
+				WordSize:    test.wordsize,
+				goversion:   &GoVersion{Name: test.version},
+				experiments: test.experiments,
+			}
+			p, err := newTypeParser(buf.Bytes(), 0, fi)
+			require.NoError(t, err)
+
+			m, n, err := p.parseMap(p)
+			require.NoError(t, err)
+			assert.Equal(t, buf.Len(), n)
+			assert.Equal(t, test.expected, m)
+		})
+	}
+}
+
+func TestNewTypeParserWordSize(t *testing.T) {
+	fi := &FileInfo{ByteOrder: binary.LittleEndian, WordSize: 2, goversion: &GoVersion{Name: "go1.22.0"}}
+	_, err := newTypeParser(nil, 0, fi)
+	assert.Error(t, err)
+}
+
+func TestParseTypeLayout(t *testing.T) {
+	for _, test := range []struct {
+		version     string
+		offsetShift uint
+	}{
+		{"go1.22.0", 0},
+		{"go1.18.10", 1},
+	} {
+		t.Run(test.version, func(t *testing.T) {
+			r := require.New(t)
+			const base = 0x1000
+
+			data := make([]byte, 0x130)
+			put := func(off int, v any) {
+				var buf bytes.Buffer
+				r.NoError(binary.Write(&buf, binary.LittleEndian, v))
+				copy(data[off:], buf.Bytes())
+			}
+			name := func(off int, flags byte, s string) {
+				put(off, append([]byte{flags, byte(len(s))}, s...))
+			}
+			name(0x00, 0, "main.T")
+			name(0x10, 1, "a")
+			name(0x18, 1, "b")
+			name(0x28, 0, "int")
+
+			put(0x30, rtypeGo64{Size: 8, Align: 8, FieldAlign: 8, Kind: uint8(reflect.Int), Str: 0x28})
+			put(0x60, rtypeGo64{Size: 8, Ptrdata: 8, Align: 8, FieldAlign: 8, Kind: uint8(reflect.Ptr), Gcdata: 0x5000, Str: 0x28})
+			put(0x60+48, uint64(base+0x30))
+			put(0xa0, rtypeGo64{Size: 16, Ptrdata: 16, Align: 8, FieldAlign: 8, Kind: uint8(reflect.Struct), Gcdata: 0x5001, Str: 0})
+			put(0xa0+48, structType64{FieldsData: base + 0x100, FieldsLen: 2, FieldsCap: 2})
+			put(0x100, structField{Name: base + 0x10, Typ: base + 0x30, OffsetEmbed: 0})
+			put(0x118, structField{Name: base + 0x18, Typ: base + 0x60, OffsetEmbed: 8 << test.offsetShift})
+
+			fi := &FileInfo{ByteOrder: binary.LittleEndian, WordSize: intSize64, goversion: &GoVersion{Name: test.version}}
+			p, err := newTypeParser(data, base, fi)
+			r.NoError(err)
+			p.readBytes = func(address, length uint64) ([]byte, error) {
+				masks := []byte{0b1, 0b10}
+				return masks[address-0x5000:][:length], nil
+			}
+
+			typ, err := p.parseType(base + 0xa0)
+			r.NoError(err)
+			assert.Equal(t, "main.T", typ.Name)
+			assert.Equal(t, uint64(16), typ.Size)
+			assert.Equal(t, uint64(16), typ.PtrData)
+			assert.Equal(t, 8, typ.Align)
+			assert.Equal(t, 8, typ.FieldAlign)
+			assert.Equal(t, []bool{false, true}, typ.GCMask)
+
+			r.Len(typ.Fields, 2)
+			assert.Equal(t, "a", typ.Fields[0].FieldName)
+			assert.Equal(t, uint64(0), typ.Fields[0].FieldOffset)
+			assert.Nil(t, typ.Fields[0].GCMask)
+			assert.Equal(t, "b", typ.Fields[1].FieldName)
+			assert.Equal(t, uint64(8), typ.Fields[1].FieldOffset)
+			assert.Equal(t, []bool{true}, typ.Fields[1].GCMask)
+		})
+	}
+}
+
+func TestSelectTypeLayouts(t *testing.T) {
+	for v := 7; v <= newestTypeLayoutVersion; v++ {
+		for _, bits := range []int{32, 64} {
+			layouts, err := selectTypeLayouts(v, bits)
+			require.NoError(t, err, "go1.%d %d bit", v, bits)
+
+			word := bits / 8
+			p := &typeParser{order: binary.LittleEndian, r: bytes.NewReader(make([]byte, 128))}
+			read := func(parse func(*typeParser) (int, error)) int {
+				p.r.Reset(make([]byte, 128))
+				c, err := parse(p)
+				require.NoError(t, err)
+				return c
+			}
+			assert.Equal(t, 4*word+16, read(func(p *typeParser) (int, error) { _, c, err := layouts.rtype(p); return c, err }))
+			assert.Equal(t, 16, read(func(p *typeParser) (int, error) { _, c, err := layouts.uncommon(p); return c, err }))
+			assert.Equal(t, 3*word, read(func(p *typeParser) (int, error) { _, c, err := layouts.structField(p); return c, err }))
+			assert.Equal(t, 4*word, read(func(p *typeParser) (int, error) { _, c, err := layouts.structType(p); return c, err }))
+			assert.Equal(t, word, read(func(p *typeParser) (int, error) { _, c, err := layouts.funcType(p); return c, err }))
+		}
+	}
+
+	_, err := selectTypeLayouts(6, 64)
+	assert.Error(t, err)
+
+	assert.Equal(t, 7, typeLayoutVersion("go1.7beta1"))
+	assert.Equal(t, 18, typeLayoutVersion("go1.18.10"))
+	assert.Equal(t, newestTypeLayoutVersion, typeLayoutVersion("go1.99.1"))
+}
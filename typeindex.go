@@ -0,0 +1,455 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// TypeRefKind is how a type references another type.
+type TypeRefKind int
+
+const (
+	// TypeRefField is a named struct field.
+	TypeRefField TypeRefKind = iota
+	// TypeRefEmbedded is an embedded struct field.
+	TypeRefEmbedded
+	// TypeRefElement is the element type of a pointer, slice, array,
+	// channel or map.
+	TypeRefElement
+	// TypeRefKey is the key type of a map.
+	TypeRefKey
+	// TypeRefArg is a function argument.
+	TypeRefArg
+	// TypeRefResult is a function return value.
+	TypeRefResult
+	// TypeRefMethod is the function type of a method.
+	TypeRefMethod
+)
+
+// String returns a short name of the kind.
+func (k TypeRefKind) String() string {
+	switch k {
+	case TypeRefField:
+		return "field"
+	case TypeRefEmbedded:
+		return "embedded field"
+	case TypeRefElement:
+		return "element"
+	case TypeRefKey:
+		return "key"
+	case TypeRefArg:
+		return "argument"
+	case TypeRefResult:
+		return "result"
+	case TypeRefMethod:
+		return "method"
+	default:
+		return "unknown"
+	}
+}
+
+// TypeRef is a reference from one type to another.
+type TypeRef struct {
+	// From is the type that holds the reference.
+	From *GoType
+	// Kind is how the type is referenced.
+	Kind TypeRefKind
+	// Name is the field or method name for field, embedded field and method
+	// references.
+	Name string
+}
+
+// MethodSetEntry is a method in the method set of a type.
+type MethodSetEntry struct {
+	// Name is the name of the method.
+	Name string
+	// Type is the function type of the method. It can be nil, see
+	// TypeMethod.
+	Type *GoType
+	// Receiver is the type that declares the method.
+	Receiver *GoType
+	// Path holds the names of the embedded fields the method is promoted
+	// through. It is empty if the method is declared by the type.
+	Path []string
+}
+
+// TypeIndex indexes the types extracted from a binary. Besides lookups it
+// answers which types reference a type and what the method set of a type
+// is.
+//
+// Struct fields are copies of the types they have, so all queries resolve
+// types by their address. A field can be passed in place of its type.
+type TypeIndex struct {
+	types  []*GoType
+	byAddr map[uint64]*GoType
+	byName map[string][]*GoType
+	byPkg  map[string][]*GoType
+	// ptrTo maps the address of a type to the pointer type to it.
+	ptrTo map[uint64]*GoType
+	refs  map[uint64][]*TypeRef
+}
+
+// TypeIndex returns an index of the types in the file.
+func (f *GoFile) TypeIndex() (*TypeIndex, error) {
+	types, err := f.GetTypes()
+	if err != nil {
+		return nil, err
+	}
+	return NewTypeIndex(types), nil
+}
+
+// NewTypeIndex indexes the types, for example the ones returned by
+// GetTypes. Types that are only reachable from the given types are
+// indexed too.
+func NewTypeIndex(types []*GoType) *TypeIndex {
+	x := &TypeIndex{
+		byAddr: make(map[uint64]*GoType),
+		byName: make(map[string][]*GoType),
+		byPkg:  make(map[string][]*GoType),
+		ptrTo:  make(map[uint64]*GoType),
+		refs:   make(map[uint64][]*TypeRef),
+	}
+	for _, t := range types {
+		x.add(t)
+	}
+	// Add the types only known as a part of other types. The slice grows
+	// while it's walked.
+	for i := 0; i < len(x.types); i++ {
+		t := x.types[i]
+		for _, r := range typeRefs(t) {
+			x.add(r.typ)
+		}
+	}
+
+	for _, t := range x.types {
+		if t.Kind == reflect.Ptr && t.Element != nil {
+			x.ptrTo[x.resolve(t.Element).Addr] = t
+		}
+		for _, r := range typeRefs(t) {
+			to := x.resolve(r.typ)
+			x.refs[to.Addr] = append(x.refs[to.Addr], &TypeRef{From: t, Kind: r.kind, Name: r.name})
+		}
+	}
+	return x
+}
+
+func (x *TypeIndex) add(t *GoType) {
+	if t == nil || t.Addr == 0 {
+		return
+	}
+	if _, ok := x.byAddr[t.Addr]; ok {
+		return
+	}
+	if t.FieldName != "" || t.FieldAnon {
+		// Index a copy without the field information.
+		c := *t
+		c.FieldName, c.FieldTag, c.FieldAnon = "", "", false
+		t = &c
+	}
+	x.byAddr[t.Addr] = t
+	x.types = append(x.types, t)
+	if t.Name != "" {
+		x.byName[t.Name] = append(x.byName[t.Name], t)
+	}
+	if t.PackagePath != "" {
+		x.byPkg[t.PackagePath] = append(x.byPkg[t.PackagePath], t)
+	}
+}
+
+// resolve returns the indexed type for the type or field.
+func (x *TypeIndex) resolve(t *GoType) *GoType {
+	if r, ok := x.byAddr[t.Addr]; ok {
+		return r
+	}
+	return t
+}
+
+type typeRef struct {
+	typ  *GoType
+	kind TypeRefKind
+	name string
+}
+
+// typeRefs returns the types directly referenced by the type.
+func typeRefs(t *GoType) []typeRef {
+	var refs []typeRef
+	add := func(to *GoType, kind TypeRefKind, name string) {
+		if to != nil {
+			refs = append(refs, typeRef{to, kind, name})
+		}
+	}
+	for _, f := range t.Fields {
+		if f.FieldAnon {
+			add(f, TypeRefEmbedded, f.FieldName)
+		} else {
+			add(f, TypeRefField, f.FieldName)
+		}
+	}
+	add(t.Element, TypeRefElement, "")
+	add(t.Key, TypeRefKey, "")
+	for _, a := range t.FuncArgs {
+		add(a, TypeRefArg, "")
+	}
+	for _, r := range t.FuncReturnVals {
+		add(r, TypeRefResult, "")
+	}
+	for _, m := range t.Methods {
+		add(m.Type, TypeRefMethod, m.Name)
+	}
+	return refs
+}
+
+// Types returns all indexed types.
+func (x *TypeIndex) Types() []*GoType {
+	return x.types
+}
+
+// ByAddress returns the type at the address or nil.
+func (x *TypeIndex) ByAddress(addr uint64) *GoType {
+	return x.byAddr[addr]
+}
+
+// ByName returns the types with the name, for example "main.T" or
+// "*main.T". Types in different packages can have the same name.
+func (x *TypeIndex) ByName(name string) []*GoType {
+	return x.byName[name]
+}
+
+// ByPackage returns the types defined in the package with the import path.
+func (x *TypeIndex) ByPackage(path string) []*GoType {
+	return x.byPkg[path]
+}
+
+// PointerTo returns the pointer type to the type or nil if the binary
+// doesn't have it.
+func (x *TypeIndex) PointerTo(t *GoType) *GoType {
+	return x.ptrTo[t.Addr]
+}
+
+// ReferencedBy returns the direct references to the type.
+func (x *TypeIndex) ReferencedBy(t *GoType) []*TypeRef {
+	return x.refs[t.Addr]
+}
+
+// StructsContaining returns the structs with a field of the type. Fields
+// with an unnamed type built from the type, like a pointer or a slice, are
+// included, fields of named types that contain the type are not.
+func (x *TypeIndex) StructsContaining(t *GoType) []*GoType {
+	return x.users(t, TypeRefField, TypeRefEmbedded)
+}
+
+// StructsEmbedding returns the structs that embed the type or a pointer to
+// it.
+func (x *TypeIndex) StructsEmbedding(t *GoType) []*GoType {
+	var structs []*GoType
+	seen := make(map[uint64]bool)
+	targets := []*GoType{x.resolve(t)}
+	if p := x.PointerTo(t); p != nil {
+		targets = append(targets, p)
+	}
+	for _, target := range targets {
+		for _, r := range x.refs[target.Addr] {
+			if r.Kind == TypeRefEmbedded && !seen[r.From.Addr] {
+				seen[r.From.Addr] = true
+				structs = append(structs, r.From)
+			}
+		}
+	}
+	sortTypeList(structs)
+	return structs
+}
+
+// FuncsTaking returns the function types with an argument of the type. As
+// for StructsContaining, unnamed types built from the type are followed.
+func (x *TypeIndex) FuncsTaking(t *GoType) []*GoType {
+	return x.users(t, TypeRefArg)
+}
+
+// FuncsReturning returns the function types with a return value of the
+// type. As for StructsContaining, unnamed types built from the type are
+// followed.
+func (x *TypeIndex) FuncsReturning(t *GoType) []*GoType {
+	return x.users(t, TypeRefResult)
+}
+
+// users returns the types that reference the type, or an unnamed type
+// built from it, with one of the kinds.
+func (x *TypeIndex) users(t *GoType, kinds ...TypeRefKind) []*GoType {
+	var found []*GoType
+	seen := make(map[uint64]bool)
+	visited := make(map[uint64]bool)
+	var walk func(t *GoType)
+	walk = func(t *GoType) {
+		if visited[t.Addr] {
+			return
+		}
+		visited[t.Addr] = true
+		for _, r := range x.refs[t.Addr] {
+			for _, k := range kinds {
+				if r.Kind == k && !seen[r.From.Addr] {
+					seen[r.From.Addr] = true
+					found = append(found, r.From)
+				}
+			}
+			if (r.Kind == TypeRefElement || r.Kind == TypeRefKey) && isUnnamedComposite(r.From) {
+				walk(r.From)
+			}
+		}
+	}
+	walk(x.resolve(t))
+	sortTypeList(found)
+	return found
+}
+
+// isUnnamedComposite returns true for type literals like []T and *T.
+func isUnnamedComposite(t *GoType) bool {
+	switch t.Kind {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+		// Named types have a qualified name, like main.List.
+		for _, prefix := range []string{"*", "[", "map[", "chan", "<-chan"} {
+			if strings.HasPrefix(t.Name, prefix) {
+				return true
+			}
+		}
+		return t.Name == ""
+	}
+	return false
+}
+
+func sortTypeList(types []*GoType) {
+	sort.Slice(types, func(i, j int) bool {
+		if types[i].Name != types[j].Name {
+			return types[i].Name < types[j].Name
+		}
+		return types[i].Addr < types[j].Addr
+	})
+}
+
+// MethodSet returns the method set of the type, including the methods
+// promoted from embedded fields. For a pointer type, the methods with value
+// and pointer receivers are included. Methods at the same depth with the
+// same name are ambiguous and left out, as the compiler does.
+//
+// The methods of a pointer type are only known if the binary has the
+// pointer type, see PointerTo.
+func (x *TypeIndex) MethodSet(t *GoType) []*MethodSetEntry {
+	t = x.resolve(t)
+	ptr := false
+	if t.Kind == reflect.Ptr && t.Element != nil {
+		ptr = true
+		t = x.resolve(t.Element)
+	}
+
+	// A candidate is a method or a field, with a nil entry, at the
+	// current depth.
+	type candidate struct {
+		entry *MethodSetEntry
+		count int
+	}
+	var result []*MethodSetEntry
+	found := make(map[string]bool)
+
+	type level struct {
+		typ  *GoType
+		ptr  bool
+		path []string
+	}
+	current := []level{{t, ptr, nil}}
+	// A type reached by two paths at the same depth is counted twice, so
+	// its methods are ambiguous. Only the types of shallower depths are
+	// skipped.
+	visited := make(map[uint64]bool)
+	for len(current) > 0 {
+		depth := make(map[string]*candidate)
+		var order []string
+		var next []level
+		for _, l := range current {
+			if visited[l.typ.Addr] {
+				continue
+			}
+
+			for _, m := range x.declaredMethods(l.typ, l.ptr) {
+				if found[m.Name] {
+					continue
+				}
+				c, ok := depth[m.Name]
+				if !ok {
+					c = &candidate{entry: &MethodSetEntry{Name: m.Name, Type: m.Type, Receiver: l.typ, Path: l.path}}
+					depth[m.Name] = c
+					order = append(order, m.Name)
+				}
+				c.count++
+			}
+
+			if l.typ.Kind != reflect.Struct {
+				continue
+			}
+			for _, f := range l.typ.Fields {
+				// Fields hide methods with the same name at deeper levels.
+				if !found[f.FieldName] {
+					if _, ok := depth[f.FieldName]; !ok {
+						depth[f.FieldName] = &candidate{}
+						order = append(order, f.FieldName)
+					}
+					depth[f.FieldName].count++
+				}
+				if !f.FieldAnon {
+					continue
+				}
+				ft := x.resolve(f)
+				// Embedding *E or embedding E in an addressable value gives
+				// the methods of *E.
+				fptr := l.ptr
+				if ft.Kind == reflect.Ptr && ft.Element != nil {
+					ft = x.resolve(ft.Element)
+					fptr = true
+				}
+				path := append(append([]string{}, l.path...), f.FieldName)
+				next = append(next, level{ft, fptr, path})
+			}
+		}
+		for _, l := range current {
+			visited[l.typ.Addr] = true
+		}
+		for _, name := range order {
+			found[name] = true
+			if c := depth[name]; c.count == 1 && c.entry != nil {
+				result = append(result, c.entry)
+			}
+		}
+		current = next
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// declaredMethods returns the methods the type declares. If ptr is true,
+// the methods of the pointer type are returned if it exists. Interfaces
+// list all their methods.
+func (x *TypeIndex) declaredMethods(t *GoType, ptr bool) []*TypeMethod {
+	if ptr {
+		if p := x.PointerTo(t); p != nil {
+			return p.Methods
+		}
+	}
+	return t.Methods
+}
@@ -0,0 +1,150 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypeIndex(t *testing.T) {
+	// field returns a struct field like the type parser does, a copy of
+	// the field's type.
+	field := func(typ *GoType, name string, anon bool) *GoType {
+		f := *typ
+		f.FieldName, f.FieldAnon = name, anon
+		return &f
+	}
+	methods := func(names ...string) []*TypeMethod {
+		var m []*TypeMethod
+		for _, n := range names {
+			m = append(m, &TypeMethod{Name: n})
+		}
+		return m
+	}
+
+	str := &GoType{Kind: reflect.String, Name: "string", Addr: 0x10}
+	errType := &GoType{Kind: reflect.Interface, Name: "error", Addr: 0x20, Methods: methods("Error")}
+
+	base := &GoType{Kind: reflect.Struct, Name: "main.Base", PackagePath: "main", Addr: 0x100, Methods: methods("Close")}
+	ptrBase := &GoType{Kind: reflect.Ptr, Name: "*main.Base", PackagePath: "main", Addr: 0x110, Element: base, Methods: methods("Close", "Reset")}
+	inner := &GoType{Kind: reflect.Struct, Name: "main.Inner", PackagePath: "main", Addr: 0x120, Methods: methods("Close", "Open")}
+	sliceBase := &GoType{Kind: reflect.Slice, Name: "[]*main.Base", Addr: 0x130, Element: ptrBase}
+	list := &GoType{Kind: reflect.Slice, Name: "main.List", PackagePath: "main", Addr: 0x140, Element: ptrBase}
+
+	outer := &GoType{Kind: reflect.Struct, Name: "main.Outer", PackagePath: "main", Addr: 0x200, Methods: methods("Run")}
+	outer.Fields = []*GoType{field(base, "Base", true), field(str, "Name", false), field(sliceBase, "Items", false)}
+	ptrOuter := &GoType{Kind: reflect.Ptr, Name: "*main.Outer", PackagePath: "main", Addr: 0x210, Element: outer, Methods: methods("Run", "Stop")}
+
+	both := &GoType{Kind: reflect.Struct, Name: "main.Both", PackagePath: "main", Addr: 0x300}
+	both.Fields = []*GoType{field(ptrBase, "Base", true), field(inner, "Inner", true)}
+	shadow := &GoType{Kind: reflect.Struct, Name: "main.Shadow", PackagePath: "main", Addr: 0x310}
+	shadow.Fields = []*GoType{field(base, "Base", true), field(str, "Close", false)}
+	deep := &GoType{Kind: reflect.Struct, Name: "main.Deep", PackagePath: "main", Addr: 0x320}
+	deep.Fields = []*GoType{field(outer, "Outer", true), field(list, "List", false)}
+
+	fn := &GoType{Kind: reflect.Func, Name: "func(*main.Base) error", Addr: 0x400, FuncArgs: []*GoType{ptrBase}, FuncReturnVals: []*GoType{errType}}
+
+	x := NewTypeIndex([]*GoType{base, ptrBase, inner, outer, ptrOuter, both, shadow, deep, fn})
+
+	t.Run("lookup", func(t *testing.T) {
+		assert.Equal(t, []*GoType{outer}, x.ByName("main.Outer"))
+		assert.Equal(t, base, x.ByAddress(0x100))
+		assert.Nil(t, x.ByAddress(0x999))
+		assert.Contains(t, x.ByPackage("main"), inner)
+		assert.Equal(t, ptrBase, x.PointerTo(base))
+		assert.Nil(t, x.PointerTo(inner))
+
+		// Types only used by other types are indexed without the field
+		// information.
+		s := x.ByAddress(0x10)
+		require.NotNil(t, s)
+		assert.Empty(t, s.FieldName)
+		assert.Equal(t, str.Name, s.Name)
+		assert.NotNil(t, x.ByAddress(sliceBase.Addr))
+		assert.NotNil(t, x.ByAddress(errType.Addr))
+	})
+
+	t.Run("references", func(t *testing.T) {
+		refs := x.ReferencedBy(base)
+		kinds := make(map[TypeRefKind]int)
+		for _, r := range refs {
+			kinds[r.Kind]++
+		}
+		assert.Equal(t, 2, kinds[TypeRefEmbedded])
+		assert.Equal(t, 1, kinds[TypeRefElement])
+
+		// A field is resolved to its type.
+		assert.Equal(t, refs, x.ReferencedBy(outer.Fields[0]))
+
+		// Deep only contains Base through the named types Outer and List.
+		assert.Equal(t, []*GoType{both, outer, shadow}, x.StructsContaining(base))
+		assert.Equal(t, []*GoType{deep}, x.StructsContaining(list))
+		assert.Equal(t, []*GoType{both, outer, shadow}, x.StructsEmbedding(base))
+		assert.Equal(t, []*GoType{both}, x.StructsEmbedding(inner))
+		assert.Equal(t, []*GoType{fn}, x.FuncsTaking(base))
+		assert.Empty(t, x.FuncsTaking(str))
+		assert.Equal(t, []*GoType{fn}, x.FuncsReturning(errType))
+	})
+
+	t.Run("method sets", func(t *testing.T) {
+		names := func(set []*MethodSetEntry) []string {
+			var n []string
+			for _, m := range set {
+				n = append(n, m.Name)
+			}
+			return n
+		}
+
+		set := x.MethodSet(outer)
+		assert.Equal(t, []string{"Close", "Run"}, names(set))
+		assert.Equal(t, base, set[0].Receiver)
+		assert.Equal(t, []string{"Base"}, set[0].Path)
+		assert.Equal(t, outer, set[1].Receiver)
+		assert.Empty(t, set[1].Path)
+
+		assert.Equal(t, []string{"Close", "Reset", "Run", "Stop"}, names(x.MethodSet(ptrOuter)))
+
+		// Close is declared by both embedded types and is ambiguous. The
+		// embedded *Base gives the pointer methods.
+		assert.Equal(t, []string{"Open", "Reset"}, names(x.MethodSet(both)))
+
+		// The field hides the promoted method.
+		assert.Empty(t, x.MethodSet(shadow))
+
+		set = x.MethodSet(deep)
+		assert.Equal(t, []string{"Close", "Run"}, names(set))
+		assert.Equal(t, []string{"Outer", "Base"}, set[0].Path)
+
+		assert.Equal(t, []string{"Error"}, names(x.MethodSet(errType)))
+
+		// Base is reached through Left and Right at the same depth, so
+		// Close is ambiguous.
+		left := &GoType{Kind: reflect.Struct, Name: "main.Left", PackagePath: "main", Addr: 0x500, Methods: methods("Open")}
+		left.Fields = []*GoType{field(base, "Base", true)}
+		right := &GoType{Kind: reflect.Struct, Name: "main.Right", PackagePath: "main", Addr: 0x510}
+		right.Fields = []*GoType{field(base, "Base", true)}
+		diamond := &GoType{Kind: reflect.Struct, Name: "main.Diamond", PackagePath: "main", Addr: 0x520}
+		diamond.Fields = []*GoType{field(left, "Left", true), field(right, "Right", true)}
+		dx := NewTypeIndex([]*GoType{base, left, right, diamond})
+		assert.Equal(t, []string{"Open"}, names(dx.MethodSet(diamond)))
+	})
+}
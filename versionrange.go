@@ -0,0 +1,451 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/ZxillyFork/gore/extern"
+	"github.com/ZxillyFork/gore/extern/gover"
+)
+
+// GoVersionRange is an estimate of the Go version used to compile a binary.
+// The bounds are minor releases, for example go1.18.
+type GoVersionRange struct {
+	// Min is the oldest release that matches the evidence.
+	Min string `json:"min"`
+	// Max is the newest release that matches the evidence. It is empty if
+	// any newer release matches.
+	Max string `json:"max,omitempty"`
+	// Evidence describes the observations the estimate is based on.
+	Evidence []string `json:"evidence"`
+}
+
+// String returns the range, for example "go1.18–go1.19".
+func (r *GoVersionRange) String() string {
+	switch r.Max {
+	case "":
+		return r.Min + " or newer"
+	case r.Min:
+		return r.Min
+	default:
+		return r.Min + "–" + r.Max
+	}
+}
+
+// Contains returns true if the version, for example go1.18.3, is within the
+// range.
+func (r *GoVersionRange) Contains(version string) bool {
+	lang := gover.Lang(extern.StripGo(version))
+	if lang == "" {
+		return false
+	}
+	if gover.Compare(lang, extern.StripGo(r.Min)) < 0 {
+		return false
+	}
+	return r.Max == "" || gover.Compare(lang, extern.StripGo(r.Max)) <= 0
+}
+
+// versionBounds narrows a range of minor versions. A negative upper bound
+// means that the range is open.
+type versionBounds struct {
+	lo, hi   int
+	evidence []string
+}
+
+func (b *versionBounds) add(lo, hi int, format string, args ...any) {
+	if lo > b.lo {
+		b.lo = lo
+	}
+	if hi >= 0 && (b.hi < 0 || hi < b.hi) {
+		b.hi = hi
+	}
+	b.evidence = append(b.evidence, fmt.Sprintf(format, args...))
+}
+
+// EstimateGoVersion returns the range of Go versions that could have
+// compiled the binary. If the version is known from the build information or
+// is found by GetCompilerVersion, the range only holds the release of the
+// version. Otherwise it's estimated from the PCLN table version, the
+// moduledata layouts that match, the type descriptors and the runtime
+// functions in the binary. The type descriptors give the encoding of the
+// type names, the type flags that were added by newer releases and the
+// layout of the map types.
+//
+// If the evidence is contradicting, for example because the binary has been
+// tampered with, an error is returned.
+func (f *GoFile) EstimateGoVersion() (*GoVersionRange, error) {
+	if v := f.goVersionName(); v != "" {
+		if lang := gover.Lang(extern.StripGo(v)); lang != "" {
+			return &GoVersionRange{Min: "go" + lang, Max: "go" + lang, Evidence: []string{"version string " + v}}, nil
+		}
+	}
+
+	if err := f.initPclntab(); err != nil {
+		return nil, err
+	}
+	b := &versionBounds{lo: 2, hi: -1}
+
+	switch f.FileInfo.ByteOrder.Uint32(f.pclntabBytes) {
+	case gopclntab12magic:
+		b.add(2, 15, "PCLN table version 1.2")
+	case gopclntab116magic:
+		b.add(16, 17, "PCLN table version 1.16")
+	case gopclntab118magic:
+		b.add(18, 19, "PCLN table version 1.18")
+	case gopclntab120magic:
+		b.add(20, -1, "PCLN table version 1.20")
+	}
+
+	moduledataEvidence(f, b)
+	runtimeFunctionEvidence(f, b)
+
+	if b.hi >= 0 && b.lo > b.hi {
+		return nil, fmt.Errorf("the evidence is contradicting: %s", strings.Join(b.evidence, ", "))
+	}
+	r := &GoVersionRange{Min: fmt.Sprintf("go1.%d", b.lo), Evidence: b.evidence}
+	if b.hi >= 0 {
+		r.Max = fmt.Sprintf("go1.%d", b.hi)
+	}
+	return r, nil
+}
+
+// moduledataEvidence narrows the range to the moduledata layouts that
+// validate. If the structure is in the file but no known layout matches,
+// the binary was compiled by a release newer than the known ones.
+func moduledataEvidence(f *GoFile, b *versionBounds) {
+	bits := 64
+	if f.FileInfo.WordSize == intSize32 {
+		bits = 32
+	}
+	textStart, textData, err := f.fh.getCodeSection()
+	if err != nil {
+		return
+	}
+	textEnd := textStart + uint64(len(textData))
+
+	lo, hi := moduledataCandidates(f)
+	var matched []int
+	var md moduledata
+	for v := hi; v >= lo; v-- {
+		vmd, err := selectModuleData(v, bits)
+		if err != nil {
+			continue
+		}
+		m, err := findModuledata(f, vmd, func(md moduledata) bool {
+			return validModuledata(f, md, v, textStart, textEnd)
+		})
+		if err != nil {
+			continue
+		}
+		if len(matched) == 0 {
+			md = m
+		}
+		matched = append(matched, v)
+	}
+
+	if len(matched) == 0 {
+		if lo == 20 && moduledataPresent(f) {
+			b.add(newestModuleDataVersion+1, -1, "no known moduledata layout matches")
+		}
+		return
+	}
+	newest, oldest := matched[0], matched[len(matched)-1]
+	if newest == newestModuleDataVersion {
+		// The layout may not have changed in newer releases.
+		b.add(oldest, -1, "moduledata layout of go1.%d or newer", oldest)
+	} else {
+		b.add(oldest, newest, "moduledata layout of go1.%d–go1.%d", oldest, newest)
+	}
+	typeDescriptorEvidence(md, oldest, b)
+}
+
+// moduledataPresent returns true if the moduledata structure can be located
+// without knowing its layout.
+func moduledataPresent(f *GoFile) bool {
+	if _, err := f.fh.getSymbol("runtime.firstmoduledata"); err == nil {
+		return true
+	}
+	_, data, err := f.fh.getSectionData(f.fh.moduledataSection())
+	if err != nil {
+		return false
+	}
+	return bytes.Contains(data, buildPclnTabAddrBinary(f.FileInfo.WordSize, f.FileInfo.ByteOrder, f.pclntabAddr))
+}
+
+// typeDescriptorEvidence narrows the range by the type descriptors of the
+// type links. The type descriptors are only in the types section since
+// Go 1.7.
+//
+// Go 1.17 switched the length of the type names from a fixed 2-byte value to
+// a varint. Go 1.14 added the flag for types that are compared as memory,
+// which is set for all pointer, channel and integer types, and Go 1.24 the
+// flag for GC masks built on demand. The map types have different layouts
+// for the bucket based maps of Go 1.14 to 1.25 and the Swiss tables.
+func typeDescriptorEvidence(md moduledata, v int, b *versionBounds) {
+	if v < 7 {
+		return
+	}
+	links, err := md.TypeLinkData()
+	if err != nil || len(links) == 0 {
+		return
+	}
+	types, err := md.Types().Data()
+	if err != nil {
+		return
+	}
+	fi := md.fh.getFileInfo()
+
+	varint, fixed := countTypeNameEncodings(types, links, fi)
+	switch {
+	case varint > 2*fixed:
+		b.add(17, -1, "type names with varint lengths")
+	case fixed > 2*varint:
+		b.add(7, 16, "type names with fixed lengths")
+	}
+
+	regular, irregular, onDemand := countTypeFlags(types, links, fi)
+	switch {
+	case regular > 2*irregular:
+		b.add(14, -1, "types with the regular memory flag")
+	case irregular > 2*regular:
+		b.add(7, 13, "types without the regular memory flag")
+	}
+	if onDemand != 0 {
+		b.add(24, -1, "types with GC masks built on demand")
+	}
+
+	counts := countMapLayouts(types, links, fi)
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	for i, l := range mapLayouts {
+		if counts[i] != 0 && counts[i] > 2*(total-counts[i]) {
+			b.add(l.lo, l.hi, "%s", l.name)
+		}
+	}
+}
+
+// countTypeNameEncodings returns the number of types, out of the first 100,
+// with a name that is valid if the length is a varint and if it is a fixed
+// 2-byte value.
+func countTypeNameEncodings(types []byte, links []int32, fi *FileInfo) (varint, fixed int) {
+	// The rtype name offset follows the size, ptrdata, hash, flags,
+	// alignments, kind, equal function and GC data fields.
+	strOff := 4*fi.WordSize + 8
+
+	for i, off := range links {
+		if i == 100 {
+			break
+		}
+		if off < 0 || int(off)+strOff+4 > len(types) {
+			continue
+		}
+		name := int(int32(fi.ByteOrder.Uint32(types[int(off)+strOff:])))
+		if name < 0 || name+3 > len(types) {
+			continue
+		}
+		data := types[name+1:]
+		if l, n := binary.Uvarint(data); n > 0 && l > 0 && isPrintable(data[n:], int(l)) {
+			varint++
+		}
+		if l := int(data[0])<<8 | int(data[1]); l > 0 && isPrintable(data[2:], l) {
+			fixed++
+		}
+	}
+	return varint, fixed
+}
+
+// regularMemoryKinds are the kinds of the types that are always compared as
+// memory.
+var regularMemoryKinds = []reflect.Kind{
+	reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+	reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+	reflect.Uintptr, reflect.Chan, reflect.Ptr, reflect.UnsafePointer,
+}
+
+// countTypeFlags returns the number of types with a kind in
+// regularMemoryKinds that have and don't have the regular memory flag, and
+// the number of types with the flag for GC masks built on demand.
+func countTypeFlags(types []byte, links []int32, fi *FileInfo) (regular, irregular, onDemand int) {
+	// The flags and the kind follow the size, ptrdata and hash fields.
+	tflagOff := 2*fi.WordSize + 4
+	kindOff := tflagOff + 3
+
+	for _, off := range links {
+		if off < 0 || int(off)+kindOff >= len(types) {
+			continue
+		}
+		tflag := types[int(off)+tflagOff]
+		if tflag&tflagGCMaskOnDemand != 0 {
+			onDemand++
+		}
+		if !slices.Contains(regularMemoryKinds, reflect.Kind(types[int(off)+kindOff]&kindMask)) {
+			continue
+		}
+		if tflag&tflagRegularMemory != 0 {
+			regular++
+		} else {
+			irregular++
+		}
+	}
+	return regular, irregular, onDemand
+}
+
+// mapLayouts are the layouts of the map type descriptors that can be told
+// apart. The valid function is called with the data after the rtype, which
+// holds at least size bytes, and returns true if it is consistent for the
+// layout.
+var mapLayouts = []struct {
+	name   string
+	lo, hi int
+	// size is the size of the structure in words.
+	size  int
+	valid func(data []byte, fi *FileInfo) bool
+}{
+	{
+		// Key, Elem, Bucket, Hasher, Keysize, Valuesize, Bucketsize and
+		// Flags. The bucket has 8 top hashes, 8 keys, 8 values and the
+		// overflow pointer.
+		name: "bucket based map types of go1.14–go1.25", lo: 14, hi: 25, size: 5,
+		valid: func(data []byte, fi *FileInfo) bool {
+			sizes := data[4*fi.WordSize:]
+			keySize, valueSize := int(sizes[0]), int(sizes[1])
+			bucketSize := int(fi.ByteOrder.Uint16(sizes[2:]))
+			min := 8 + 8*(keySize+valueSize) + fi.WordSize
+			return keySize != 0 && bucketSize >= min && bucketSize < min+8
+		},
+	},
+	{
+		// Key, Elem, Group, Hasher, GroupSize, SlotSize, ElemOff and Flags.
+		// The group has 8 control bytes and 8 slots.
+		name: "Swiss table map types of go1.24–go1.26", lo: 24, hi: 26, size: 8,
+		valid: func(data []byte, fi *FileInfo) bool {
+			groupSize, slotSize, elemOff := readWord(data[4*fi.WordSize:], fi), readWord(data[5*fi.WordSize:], fi), readWord(data[6*fi.WordSize:], fi)
+			return slotSize != 0 && elemOff <= slotSize && groupSize == swissGroupSlotsOffset+8*slotSize
+		},
+	},
+	{
+		// Key, Elem, Group, Hasher, GroupSize, KeysOff, KeyStride, ElemsOff,
+		// ElemStride, ElemOff and Flags. The keys and the elements are either
+		// in slots or in separate arrays after the control bytes.
+		name: "Swiss table map types of go1.27 or newer", lo: 27, hi: -1, size: 11,
+		valid: func(data []byte, fi *FileInfo) bool {
+			w := make([]uint64, 10)
+			for i := range w {
+				w[i] = readWord(data[i*fi.WordSize:], fi)
+			}
+			groupSize, keysOff, keyStride, elemsOff, elemStride, elemOff := w[4], w[5], w[6], w[7], w[8], w[9]
+			if keysOff != swissGroupSlotsOffset {
+				return false
+			}
+			if interleavedSlots(keysOff, keyStride, elemsOff, elemStride, elemOff) {
+				return keyStride != 0 && elemOff < keyStride && groupSize == swissGroupSlotsOffset+8*keyStride
+			}
+			return keyStride+elemStride != 0 && elemsOff >= keysOff+8*keyStride && groupSize >= elemsOff+8*elemStride
+		},
+	},
+}
+
+// countMapLayouts returns the number of map types, by the index in
+// mapLayouts, that are valid for the layout.
+func countMapLayouts(types []byte, links []int32, fi *FileInfo) []int {
+	ws := fi.WordSize
+	kindOff := 2*ws + 7
+	// The rtype ends with the equal function, the GC data and the name and
+	// pointer offsets.
+	rtypeSize := 4*ws + 16
+
+	counts := make([]int, len(mapLayouts))
+	for _, off := range links {
+		if off < 0 || int(off)+kindOff >= len(types) || reflect.Kind(types[int(off)+kindOff]&kindMask) != reflect.Map {
+			continue
+		}
+		if int(off)+rtypeSize > len(types) {
+			continue
+		}
+		data := types[int(off)+rtypeSize:]
+		for i, l := range mapLayouts {
+			if len(data) >= l.size*ws && l.valid(data, fi) {
+				counts[i]++
+			}
+		}
+	}
+	return counts
+}
+
+func isPrintable(data []byte, n int) bool {
+	if n > len(data) {
+		return false
+	}
+	for _, c := range data[:n] {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// versionMarkers are runtime functions or packages that only exist in some
+// releases. An upper bound of -1 means that they exist in all releases
+// since the lower bound.
+var versionMarkers = []struct {
+	prefix string
+	lo, hi int
+}{
+	{"runtime.asyncPreempt", 14, -1},
+	{"runtime.(*unwinder).", 21, -1},
+	{"runtime/internal/atomic.", 2, 22},
+	{"internal/runtime/atomic.", 23, -1},
+	{"internal/runtime/maps.", 24, -1},
+}
+
+// runtimeFunctionEvidence narrows the range by the runtime functions in the
+// binary. Since functions can be removed by the linker, only the presence of
+// a function is used.
+func runtimeFunctionEvidence(f *GoFile, b *versionBounds) {
+	tab, err := f.PCLNTab()
+	if err != nil {
+		return
+	}
+	found := make([]bool, len(versionMarkers))
+	for _, fn := range tab.Funcs {
+		for i, m := range versionMarkers {
+			if !found[i] && strings.HasPrefix(fn.Name, m.prefix) {
+				found[i] = true
+			}
+		}
+	}
+	for i, m := range versionMarkers {
+		if !found[i] {
+			continue
+		}
+		name := strings.TrimSuffix(m.prefix, ".")
+		if m.hi < 0 {
+			b.add(m.lo, m.hi, "%s exists since go1.%d", name, m.lo)
+		} else {
+			b.add(m.lo, m.hi, "%s exists until go1.%d", name, m.hi)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoVersionRange(t *testing.T) {
+	r := &GoVersionRange{Min: "go1.18", Max: "go1.19"}
+	assert.Equal(t, "go1.18–go1.19", r.String())
+	assert.True(t, r.Contains("go1.18"))
+	assert.True(t, r.Contains("go1.18rc1"))
+	assert.True(t, r.Contains("go1.19.13"))
+	assert.False(t, r.Contains("go1.17.2"))
+	assert.False(t, r.Contains("go1.20"))
+	assert.False(t, r.Contains("garbage"))
+
+	r = &GoVersionRange{Min: "go1.24"}
+	assert.Equal(t, "go1.24 or newer", r.String())
+	assert.True(t, r.Contains("go1.30.1"))
+
+	r = &GoVersionRange{Min: "go1.21", Max: "go1.21"}
+	assert.Equal(t, "go1.21", r.String())
+}
+
+func TestVersionBounds(t *testing.T) {
+	b := &versionBounds{lo: 2, hi: -1}
+	b.add(20, -1, "a")
+	b.add(14, -1, "b")
+	assert.Equal(t, 20, b.lo)
+	assert.Equal(t, -1, b.hi)
+	b.add(2, 22, "c")
+	b.add(2, 23, "d")
+	assert.Equal(t, 22, b.hi)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, b.evidence)
+}
+
+func TestCountTypeNameEncodings(t *testing.T) {
+	fi := &FileInfo{WordSize: intSize64, ByteOrder: binary.LittleEndian}
+	strOff := 4*fi.WordSize + 8
+	build := func(varint bool, names ...string) ([]byte, []int32) {
+		types := make([]byte, len(names)*(strOff+8))
+		var links []int32
+		for i, n := range names {
+			off := i * (strOff + 8)
+			links = append(links, int32(off))
+			fi.ByteOrder.PutUint32(types[off+strOff:], uint32(len(types)))
+			types = append(types, 0)
+			if varint {
+				types = binary.AppendUvarint(types, uint64(len(n)))
+			} else {
+				types = append(types, byte(len(n)>>8), byte(len(n)))
+			}
+			types = append(types, n...)
+		}
+		return types, links
+	}
+
+	names := []string{"*main.T", "[]string", "map[string]int"}
+	types, links := build(true, names...)
+	varint, fixed := countTypeNameEncodings(types, links, fi)
+	assert.Equal(t, 3, varint)
+	assert.Equal(t, 0, fixed)
+
+	types, links = build(false, names...)
+	varint, fixed = countTypeNameEncodings(types, links, fi)
+	assert.Equal(t, 0, varint)
+	assert.Equal(t, 3, fixed)
+
+	varint, fixed = countTypeNameEncodings(types, []int32{-4, int32(len(types))}, fi)
+	assert.Zero(t, varint+fixed)
+}
+
+func TestEstimateGoVersion(t *testing.T) {
+	exe := buildTestBinary(t, testresourcesrc)
+	f, err := Open(exe)
+	require.NoError(t, err)
+	defer f.Close()
+	require.NotNil(t, f.BuildInfo)
+	version := f.BuildInfo.ModInfo.GoVersion
+
+	r, err := f.EstimateGoVersion()
+	require.NoError(t, err)
+	assert.Equal(t, r.Min, r.Max)
+	assert.True(t, r.Contains(version))
+
+	// Without the build information, the version is estimated unless it's
+	// found in the binary.
+	f.BuildInfo = nil
+	r, err = f.EstimateGoVersion()
+	require.NoError(t, err)
+	assert.True(t, r.Contains(version), "%s not in %s", version, r)
+	assert.NotEmpty(t, r.Evidence)
+}
+
+func TestCountTypeFlags(t *testing.T) {
+	fi := &FileInfo{WordSize: intSize64, ByteOrder: binary.LittleEndian}
+	tflagOff := 2*fi.WordSize + 4
+	rtypeSize := 4*fi.WordSize + 16
+	build := func(types ...[2]byte) ([]byte, []int32) {
+		data := make([]byte, len(types)*rtypeSize)
+		var links []int32
+		for i, typ := range types {
+			off := i * rtypeSize
+			data[off+tflagOff] = typ[0]
+			data[off+tflagOff+3] = typ[1]
+			links = append(links, int32(off))
+		}
+		return data, links
+	}
+
+	// The flag is only counted for the kinds that are always compared as
+	// memory.
+	types, links := build(
+		[2]byte{tflagRegularMemory, byte(reflect.Int)},
+		[2]byte{tflagRegularMemory | tflagUncommon, byte(reflect.Ptr)},
+		[2]byte{0, byte(reflect.Chan)},
+		[2]byte{0, byte(reflect.Slice)},
+		[2]byte{tflagGCMaskOnDemand, byte(reflect.Struct)},
+	)
+	regular, irregular, onDemand := countTypeFlags(types, links, fi)
+	assert.Equal(t, 2, regular)
+	assert.Equal(t, 1, irregular)
+	assert.Equal(t, 1, onDemand)
+
+	regular, irregular, onDemand = countTypeFlags(types, []int32{-1, int32(len(types))}, fi)
+	assert.Zero(t, regular+irregular+onDemand)
+}
+
+func TestCountMapLayouts(t *testing.T) {
+	for _, ws := range []int{intSize32, intSize64} {
+		fi := &FileInfo{WordSize: ws, ByteOrder: binary.BigEndian}
+		kindOff := 2*ws + 7
+		rtypeSize := 4*ws + 16
+		putWord := func(b []byte, v uint64) {
+			if ws == intSize64 {
+				fi.ByteOrder.PutUint64(b, v)
+			} else {
+				fi.ByteOrder.PutUint32(b, uint32(v))
+			}
+		}
+		build := func(words ...uint64) []byte {
+			data := make([]byte, rtypeSize+11*ws)
+			data[kindOff] = byte(reflect.Map)
+			for i, w := range words {
+				putWord(data[rtypeSize+(4+i)*ws:], w)
+			}
+			return data
+		}
+
+		// map[int64]string with buckets: the key and value sizes and the
+		// bucket size follow the 4 pointers.
+		bucket := make([]byte, rtypeSize+11*ws)
+		bucket[kindOff] = byte(reflect.Map)
+		sizes := bucket[rtypeSize+4*ws:]
+		sizes[0], sizes[1] = 8, byte(2*ws)
+		fi.ByteOrder.PutUint16(sizes[2:], uint16(8+8*8+8*2*ws+ws))
+
+		for i, data := range [][]byte{
+			bucket,
+			// Swiss table with 24-byte slots.
+			build(8+8*24, 24, 8),
+			// Swiss table with interleaved slots and with separate arrays.
+			build(8+8*24, 8, 24, 16, 24, 8),
+		} {
+			expected := make([]int, len(mapLayouts))
+			expected[i] = 1
+			assert.Equal(t, expected, countMapLayouts(data, []int32{0}, fi), "word size %d, layout %d", ws, i)
+		}
+		data := build(8+8*8+8*16, 8, 8, 8+8*8, 16)
+		assert.Equal(t, []int{0, 0, 1}, countMapLayouts(data, []int32{0}, fi))
+		// map[int]int with a split group, the strides are equal.
+		data = build(8+8*8+8*8, 8, 8, 8+8*8, 8, 0)
+		assert.Equal(t, []int{0, 0, 1}, countMapLayouts(data, []int32{0}, fi))
+
+		// Other kinds and truncated types are skipped.
+		data[kindOff] = byte(reflect.Slice)
+		assert.Equal(t, []int{0, 0, 0}, countMapLayouts(data, []int32{0}, fi))
+		assert.Equal(t, []int{0, 0, 0}, countMapLayouts(bucket[:rtypeSize+2*ws], []int32{0}, fi))
+	}
+}
@@ -0,0 +1,527 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// osvStdlibModule is the module name used by the Go vulnerability
+	// database for the standard library.
+	osvStdlibModule = "stdlib"
+	// osvRangeSemver is the only range type used for Go modules.
+	osvRangeSemver = "SEMVER"
+)
+
+// OSVEntry is a vulnerability report in the OSV format. Only the fields used
+// for matching against a binary are decoded.
+type OSVEntry struct {
+	ID       string         `json:"id"`
+	Aliases  []string       `json:"aliases,omitempty"`
+	Summary  string         `json:"summary,omitempty"`
+	Details  string         `json:"details,omitempty"`
+	Affected []*OSVAffected `json:"affected"`
+}
+
+// OSVAffected describes the versions of a module that are affected.
+type OSVAffected struct {
+	Package           OSVPackage            `json:"package"`
+	Ranges            []*OSVRange           `json:"ranges,omitempty"`
+	EcosystemSpecific *OSVEcosystemSpecific `json:"ecosystem_specific,omitempty"`
+}
+
+// OSVPackage identifies the affected module. For the standard library, the
+// name is "stdlib".
+type OSVPackage struct {
+	Name      string `json:"name"`
+	Ecosystem string `json:"ecosystem"`
+}
+
+// OSVRange is a list of version events.
+type OSVRange struct {
+	Type   string      `json:"type"`
+	Events []*OSVEvent `json:"events"`
+}
+
+// OSVEvent marks a version where the vulnerability was introduced or fixed.
+// Only one of the fields is set.
+type OSVEvent struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+}
+
+// OSVEcosystemSpecific holds the Go specific part of an affected module.
+type OSVEcosystemSpecific struct {
+	Imports []*OSVImport `json:"imports,omitempty"`
+}
+
+// OSVImport lists the vulnerable symbols of a package.
+type OSVImport struct {
+	Path    string   `json:"path"`
+	GOOS    []string `json:"goos,omitempty"`
+	GOARCH  []string `json:"goarch,omitempty"`
+	Symbols []string `json:"symbols,omitempty"`
+}
+
+// VulnDB is an offline vulnerability database.
+type VulnDB struct {
+	Entries []*OSVEntry
+}
+
+// LoadVulnDB loads an OSV vulnerability database from a directory or a zip
+// file. All JSON files are read and files that are not OSV entries, for
+// example the index files of the Go vulnerability database, are skipped.
+func LoadVulnDB(path string) (*VulnDB, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	db := &VulnDB{}
+	if info.IsDir() {
+		err = db.loadFS(os.DirFS(path))
+	} else {
+		var r *zip.ReadCloser
+		r, err = zip.OpenReader(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open vulnerability database: %w", err)
+		}
+		defer r.Close()
+		err = db.loadFS(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(db.Entries, func(i, j int) bool {
+		return db.Entries[i].ID < db.Entries[j].ID
+	})
+	return db, nil
+}
+
+func (db *VulnDB) loadFS(fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(p) != ".json" {
+			return nil
+		}
+		file, err := fsys.Open(p)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return err
+		}
+		var entry OSVEntry
+		if err = json.Unmarshal(data, &entry); err != nil || entry.ID == "" || len(entry.Affected) == 0 {
+			return nil
+		}
+		db.Entries = append(db.Entries, &entry)
+		return nil
+	})
+}
+
+// VulnLevel indicates how precisely a vulnerability was matched.
+type VulnLevel uint8
+
+const (
+	// VulnModule is used if the affected module version is used but the
+	// entry doesn't list the vulnerable packages.
+	VulnModule VulnLevel = iota
+	// VulnPackage is used if code from a vulnerable package is in the binary.
+	VulnPackage
+	// VulnSymbol is used if a vulnerable function is in the binary.
+	VulnSymbol
+)
+
+// String returns a lower case name of the level.
+func (l VulnLevel) String() string {
+	switch l {
+	case VulnPackage:
+		return "package"
+	case VulnSymbol:
+		return "symbol"
+	default:
+		return "module"
+	}
+}
+
+// Vulnerability is a vulnerability that affects the binary.
+type Vulnerability struct {
+	// Entry is the database entry.
+	Entry *OSVEntry `json:"entry"`
+	// Module is the affected module. The standard library is "stdlib".
+	Module string `json:"module"`
+	// Version is the version of the module in the binary.
+	Version string `json:"version"`
+	// FixedVersion is the first version with a fix. It is empty if no fix
+	// is available.
+	FixedVersion string `json:"fixedVersion,omitempty"`
+	// Level is the most precise level the vulnerability was matched at.
+	Level VulnLevel `json:"level"`
+	// Symbols are the vulnerable functions found in the binary. They are
+	// given as the package path followed by the symbol name as used by the
+	// database, for example "net/http.Header.Write".
+	Symbols []string `json:"symbols,omitempty"`
+}
+
+// Vulnerabilities returns the vulnerabilities in the database that affect
+// the binary. The module versions are taken from the build information and
+// the standard library version from the compiler version. If the file has no
+// build information, the modules inferred by InferBuildInfo are used.
+//
+// A vulnerability is reported when an affected version of a module is used
+// and, if the entry lists the vulnerable packages, code from one of the
+// packages is in the binary. If the entry also lists the vulnerable symbols,
+// at least one of them has to be in the pclntab. Since the linker removes
+// functions that are never called, this is the same level of reachability
+// as binary analysis of govulncheck.
+func (f *GoFile) Vulnerabilities(db *VulnDB) ([]*Vulnerability, error) {
+	pkgs, err := f.allPackages()
+	if err != nil {
+		return nil, err
+	}
+
+	target := &vulnTarget{
+		modules:   make(map[string]string),
+		packages:  make(map[string]bool),
+		functions: make(map[string]bool),
+	}
+	for _, p := range pkgs {
+		target.packages[p.Name] = true
+		for _, fn := range p.Functions {
+			target.functions[vulnSymbolName(fn)] = true
+		}
+		for _, m := range p.Methods {
+			target.functions[vulnSymbolName(m.Function)] = true
+		}
+	}
+
+	if v, err := f.GetCompilerVersion(); err == nil {
+		target.modules[osvStdlibModule] = goVersionToSemver(v.Name)
+	}
+	info := f.BuildInfo
+	if info == nil || info.ModInfo == nil {
+		if info, err = f.InferBuildInfo(); err != nil {
+			return nil, err
+		}
+	}
+	if bi := info.ModInfo; bi != nil {
+		if _, ok := target.modules[osvStdlibModule]; !ok && bi.GoVersion != "" {
+			target.modules[osvStdlibModule] = goVersionToSemver(bi.GoVersion)
+		}
+		for _, dep := range bi.Deps {
+			m := dep
+			if dep.Replace != nil {
+				m = dep.Replace
+			}
+			// Modules replaced by a local directory have no version and
+			// inferred modules may only have the major version.
+			if strings.Count(m.Version, ".") >= 2 {
+				target.modules[m.Path] = m.Version
+			}
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "GOOS":
+				target.goos = s.Value
+			case "GOARCH":
+				target.goarch = s.Value
+			}
+		}
+	}
+
+	return target.match(db), nil
+}
+
+// vulnTarget holds what is needed from the binary to match vulnerabilities.
+type vulnTarget struct {
+	// modules maps module paths to the version in the binary.
+	modules   map[string]string
+	packages  map[string]bool
+	functions map[string]bool
+	// goos and goarch are empty if unknown.
+	goos, goarch string
+}
+
+func (t *vulnTarget) match(db *VulnDB) []*Vulnerability {
+	var result []*Vulnerability
+	for _, entry := range db.Entries {
+		for _, aff := range entry.Affected {
+			version, ok := t.modules[aff.Package.Name]
+			if !ok || version == "" || version == "(devel)" {
+				continue
+			}
+			fixed, ok := aff.affects(version)
+			if !ok {
+				continue
+			}
+			vuln := &Vulnerability{
+				Entry:        entry,
+				Module:       aff.Package.Name,
+				Version:      version,
+				FixedVersion: fixed,
+			}
+			if !t.matchImports(aff, vuln) {
+				continue
+			}
+			result = append(result, vuln)
+		}
+	}
+	return result
+}
+
+// matchImports refines the match using the vulnerable packages and symbols.
+// It returns false if none of them are in the binary.
+func (t *vulnTarget) matchImports(aff *OSVAffected, vuln *Vulnerability) bool {
+	if aff.EcosystemSpecific == nil || len(aff.EcosystemSpecific.Imports) == 0 {
+		vuln.Level = VulnModule
+		return true
+	}
+	found := false
+	for _, imp := range aff.EcosystemSpecific.Imports {
+		if !t.matchPlatform(imp) || !t.packages[imp.Path] {
+			continue
+		}
+		if len(imp.Symbols) == 0 {
+			found = true
+			if vuln.Level < VulnPackage {
+				vuln.Level = VulnPackage
+			}
+			continue
+		}
+		for _, sym := range imp.Symbols {
+			name := imp.Path + "." + sym
+			if t.functions[name] {
+				found = true
+				vuln.Level = VulnSymbol
+				vuln.Symbols = append(vuln.Symbols, name)
+			}
+		}
+	}
+	return found
+}
+
+func (t *vulnTarget) matchPlatform(imp *OSVImport) bool {
+	contains := func(list []string, v string) bool {
+		if len(list) == 0 || v == "" {
+			return true
+		}
+		for _, s := range list {
+			if s == v {
+				return true
+			}
+		}
+		return false
+	}
+	return contains(imp.GOOS, t.goos) && contains(imp.GOARCH, t.goarch)
+}
+
+// affects returns true if the version is in one of the affected ranges. The
+// first version with a fix is also returned.
+func (a *OSVAffected) affects(version string) (string, bool) {
+	affected := false
+	var fixed string
+	for _, r := range a.Ranges {
+		if r.Type != osvRangeSemver {
+			continue
+		}
+		events := make([]*OSVEvent, len(r.Events))
+		copy(events, r.Events)
+		sort.SliceStable(events, func(i, j int) bool {
+			return compareSemver(events[i].version(), events[j].version()) < 0
+		})
+
+		inRange := false
+		var rangeFixed string
+		for _, e := range events {
+			switch {
+			case e.Introduced != "":
+				if e.Introduced == "0" || compareSemver(version, e.Introduced) >= 0 {
+					inRange = true
+					rangeFixed = ""
+				}
+			case e.Fixed != "":
+				if compareSemver(version, e.Fixed) >= 0 {
+					inRange = false
+				} else if inRange && rangeFixed == "" {
+					rangeFixed = e.Fixed
+				}
+			case e.LastAffected != "":
+				if compareSemver(version, e.LastAffected) > 0 {
+					inRange = false
+				}
+			}
+		}
+		if inRange {
+			affected = true
+			if fixed == "" {
+				fixed = rangeFixed
+			}
+		}
+	}
+	return fixed, affected
+}
+
+func (e *OSVEvent) version() string {
+	switch {
+	case e.Introduced != "":
+		return e.Introduced
+	case e.Fixed != "":
+		return e.Fixed
+	default:
+		return e.LastAffected
+	}
+}
+
+// vulnSymbolName returns the name of the function in the format used by the
+// vulnerability database. The receiver is written without pointer and type
+// parameters are removed, for example "pkg.T.Method".
+func vulnSymbolName(fn *Function) string {
+	name := fn.PackageName + "." + fn.Name
+	if fn.Func != nil {
+		name = fn.Func.Name
+	}
+	if !strings.HasPrefix(name, fn.PackageName+".") {
+		return name
+	}
+	sym := name[len(fn.PackageName)+1:]
+	sym = strings.NewReplacer("(*", "", ")", "").Replace(sym)
+	for {
+		start := strings.Index(sym, "[")
+		end := strings.LastIndex(sym, "]")
+		if start == -1 || end < start {
+			break
+		}
+		sym = sym[:start] + sym[end+1:]
+	}
+	return fn.PackageName + "." + sym
+}
+
+// goVersionToSemver converts a Go version like go1.21rc1 into the semver
+// format used by the vulnerability database, 1.21.0-rc.1.
+func goVersionToSemver(v string) string {
+	// Experiments are appended after a space, e.g. "go1.24 X:boringcrypto".
+	if i := strings.IndexByte(v, ' '); i != -1 {
+		v = v[:i]
+	}
+	v = strings.TrimPrefix(v, "go")
+	var pre string
+	if i := strings.IndexAny(v, "abcdefghijklmnopqrstuvwxyz"); i != -1 {
+		v, pre = v[:i], v[i:]
+	}
+	parts := strings.Split(v, ".")
+	for len(parts) < 3 {
+		parts = append(parts, "0")
+	}
+	v = strings.Join(parts, ".")
+	if pre != "" {
+		if i := strings.IndexAny(pre, "0123456789"); i != -1 {
+			pre = pre[:i] + "." + pre[i:]
+		}
+		v += "-" + pre
+	}
+	return v
+}
+
+// compareSemver compares two semantic versions. The "v" prefix is optional
+// and build metadata is ignored.
+func compareSemver(a, b string) int {
+	parse := func(v string) (nums []string, pre []string) {
+		v = strings.TrimPrefix(v, "v")
+		if i := strings.IndexByte(v, '+'); i != -1 {
+			v = v[:i]
+		}
+		if i := strings.IndexByte(v, '-'); i != -1 {
+			pre = strings.Split(v[i+1:], ".")
+			v = v[:i]
+		}
+		nums = strings.Split(v, ".")
+		for len(nums) < 3 {
+			nums = append(nums, "0")
+		}
+		return nums, pre
+	}
+	aNums, aPre := parse(a)
+	bNums, bPre := parse(b)
+
+	for i := 0; i < 3; i++ {
+		if c := compareNumeric(aNums[i], bNums[i]); c != 0 {
+			return c
+		}
+	}
+
+	// A version without pre-release identifiers has a higher precedence.
+	switch {
+	case len(aPre) == 0 && len(bPre) == 0:
+		return 0
+	case len(aPre) == 0:
+		return 1
+	case len(bPre) == 0:
+		return -1
+	}
+	for i := 0; i < len(aPre) && i < len(bPre); i++ {
+		_, aErr := strconv.ParseUint(aPre[i], 10, 64)
+		_, bErr := strconv.ParseUint(bPre[i], 10, 64)
+		var c int
+		switch {
+		case aErr == nil && bErr == nil:
+			c = compareNumeric(aPre[i], bPre[i])
+		case aErr == nil:
+			c = -1
+		case bErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(aPre[i], bPre[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(aPre) < len(bPre):
+		return -1
+	case len(aPre) > len(bPre):
+		return 1
+	}
+	return 0
+}
+
+// compareNumeric compares two decimal strings without leading zeros.
+func compareNumeric(a, b string) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
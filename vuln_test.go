@@ -0,0 +1,214 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ZxillyFork/gosym"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareSemver(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2.3", "1.10.0", -1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.21.0-rc.1", "1.21.0", -1},
+		{"1.21.0-rc.2", "1.21.0-rc.10", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.beta", "1.0.0-alpha.1", 1},
+		{"v2.0.0+incompatible", "2.0.0", 0},
+		{"0", "0.0.1", -1},
+		{"v0.0.0-20230101000000-abcdef123456", "0.0.1", -1},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, compareSemver(test.a, test.b), "%s vs %s", test.a, test.b)
+	}
+}
+
+func TestGoVersionToSemver(t *testing.T) {
+	tests := map[string]string{
+		"go1":                     "1.0.0",
+		"go1.21":                  "1.21.0",
+		"go1.21.3":                "1.21.3",
+		"go1.21rc1":               "1.21.0-rc.1",
+		"go1.9beta2":              "1.9.0-beta.2",
+		"go1.22.1 X:boringcrypto": "1.22.1",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, goVersionToSemver(in), in)
+	}
+}
+
+func TestOSVAffects(t *testing.T) {
+	aff := &OSVAffected{Ranges: []*OSVRange{{
+		Type: osvRangeSemver,
+		Events: []*OSVEvent{
+			{Introduced: "0"},
+			{Fixed: "1.20.10"},
+			{Introduced: "1.21.0-0"},
+			{Fixed: "1.21.3"},
+		},
+	}}}
+	tests := []struct {
+		version  string
+		affected bool
+		fixed    string
+	}{
+		{"1.19.0", true, "1.20.10"},
+		{"1.20.10", false, ""},
+		{"1.21.0-rc.1", true, "1.21.3"},
+		{"1.21.2", true, "1.21.3"},
+		{"1.21.3", false, ""},
+		{"1.22.0", false, ""},
+	}
+	for _, test := range tests {
+		fixed, affected := aff.affects(test.version)
+		assert.Equal(t, test.affected, affected, test.version)
+		assert.Equal(t, test.fixed, fixed, test.version)
+	}
+
+	lastAffected := &OSVAffected{Ranges: []*OSVRange{{
+		Type:   osvRangeSemver,
+		Events: []*OSVEvent{{Introduced: "1.0.0"}, {LastAffected: "1.2.0"}},
+	}}}
+	_, affected := lastAffected.affects("v1.2.0")
+	assert.True(t, affected)
+	_, affected = lastAffected.affects("v1.2.1")
+	assert.False(t, affected)
+}
+
+func TestVulnSymbolName(t *testing.T) {
+	fn := func(pkg, name string) *Function {
+		return &Function{PackageName: pkg, Func: &gosym.Func{Sym: &gosym.Sym{Name: name}}}
+	}
+	assert.Equal(t, "net/http.Header.Write", vulnSymbolName(fn("net/http", "net/http.Header.Write")))
+	assert.Equal(t, "net/http.Request.Write", vulnSymbolName(fn("net/http", "net/http.(*Request).Write")))
+	assert.Equal(t, "example.com/m.List.Push", vulnSymbolName(fn("example.com/m", "example.com/m.(*List[go.shape.int]).Push")))
+	assert.Equal(t, "fmt.Println", vulnSymbolName(&Function{PackageName: "fmt", Name: "Println"}))
+}
+
+func TestLoadVulnDB(t *testing.T) {
+	entry := `{"id":"GO-2024-0001","affected":[{"package":{"name":"example.com/a","ecosystem":"Go"}}]}`
+	index := `{"modified":"2024-01-01T00:00:00Z"}`
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "ID"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "index"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ID", "GO-2024-0001.json"), []byte(entry), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index", "db.json"), []byte(index), 0644))
+
+	db, err := LoadVulnDB(dir)
+	require.NoError(t, err)
+	require.Len(t, db.Entries, 1)
+	assert.Equal(t, "GO-2024-0001", db.Entries[0].ID)
+
+	zipPath := filepath.Join(t.TempDir(), "all.zip")
+	zf, err := os.Create(zipPath)
+	require.NoError(t, err)
+	zw := zip.NewWriter(zf)
+	w, err := zw.Create("GO-2024-0001.json")
+	require.NoError(t, err)
+	_, err = w.Write([]byte(entry))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.NoError(t, zf.Close())
+
+	db, err = LoadVulnDB(zipPath)
+	require.NoError(t, err)
+	require.Len(t, db.Entries, 1)
+	assert.Equal(t, "GO-2024-0001", db.Entries[0].ID)
+}
+
+func TestVulnTargetMatch(t *testing.T) {
+	allVersions := []*OSVRange{{Type: osvRangeSemver, Events: []*OSVEvent{{Introduced: "0"}, {Fixed: "1.5.0"}}}}
+	affected := func(imports ...*OSVImport) []*OSVAffected {
+		a := &OSVAffected{Package: OSVPackage{Name: "example.com/a", Ecosystem: "Go"}, Ranges: allVersions}
+		if len(imports) != 0 {
+			a.EcosystemSpecific = &OSVEcosystemSpecific{Imports: imports}
+		}
+		return []*OSVAffected{a}
+	}
+	db := &VulnDB{Entries: []*OSVEntry{
+		{ID: "module", Affected: affected()},
+		{ID: "package", Affected: affected(&OSVImport{Path: "example.com/a/pkg"})},
+		{ID: "symbol", Affected: affected(&OSVImport{Path: "example.com/a/pkg", Symbols: []string{"T.Bad", "Unused"}})},
+		{ID: "unused package", Affected: affected(&OSVImport{Path: "example.com/a/other"})},
+		{ID: "unused symbol", Affected: affected(&OSVImport{Path: "example.com/a/pkg", Symbols: []string{"Unused"}})},
+		{ID: "other platform", Affected: affected(&OSVImport{Path: "example.com/a/pkg", GOOS: []string{"windows"}})},
+		{ID: "other module", Affected: []*OSVAffected{{Package: OSVPackage{Name: "example.com/b"}, Ranges: allVersions}}},
+	}}
+	target := &vulnTarget{
+		modules:   map[string]string{"example.com/a": "v1.4.0"},
+		packages:  map[string]bool{"example.com/a/pkg": true},
+		functions: map[string]bool{"example.com/a/pkg.T.Bad": true},
+		goos:      "linux",
+	}
+
+	vulns := target.match(db)
+	require.Len(t, vulns, 3)
+	assert.Equal(t, "module", vulns[0].Entry.ID)
+	assert.Equal(t, VulnModule, vulns[0].Level)
+	assert.Equal(t, "package", vulns[1].Entry.ID)
+	assert.Equal(t, VulnPackage, vulns[1].Level)
+	assert.Equal(t, "symbol", vulns[2].Entry.ID)
+	assert.Equal(t, VulnSymbol, vulns[2].Level)
+	assert.Equal(t, []string{"example.com/a/pkg.T.Bad"}, vulns[2].Symbols)
+	assert.Equal(t, "1.5.0", vulns[2].FixedVersion)
+
+	target.modules["example.com/a"] = "v1.5.0"
+	assert.Empty(t, target.match(db), "Fixed version should not be affected")
+}
+
+func TestVulnerabilities(t *testing.T) {
+	r := require.New(t)
+
+	f, err := Open(buildTestBinary(t, testresourcesrc))
+	r.NoError(err)
+	defer f.Close()
+
+	stdlib := func(symbols ...string) []*OSVAffected {
+		return []*OSVAffected{{
+			Package: OSVPackage{Name: osvStdlibModule, Ecosystem: "Go"},
+			Ranges:  []*OSVRange{{Type: osvRangeSemver, Events: []*OSVEvent{{Introduced: "0"}}}},
+			EcosystemSpecific: &OSVEcosystemSpecific{Imports: []*OSVImport{
+				{Path: "fmt", Symbols: symbols},
+			}},
+		}}
+	}
+	db := &VulnDB{Entries: []*OSVEntry{
+		{ID: "GO-0000-0001", Affected: stdlib("Fprintln", "Sscanf")},
+		{ID: "GO-0000-0002", Affected: stdlib("Sscanf")},
+	}}
+
+	vulns, err := f.Vulnerabilities(db)
+	r.NoError(err)
+	r.Len(vulns, 1)
+	r.Equal("GO-0000-0001", vulns[0].Entry.ID)
+	r.Equal(VulnSymbol, vulns[0].Level)
+	r.Equal([]string{"fmt.Fprintln"}, vulns[0].Symbols)
+}
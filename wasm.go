@@ -0,0 +1,521 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// WebAssembly modules have two address spaces. The code addresses are the
+// offsets into the code section, which is also what the DWARF data uses,
+// and the data addresses are the addresses in the linear memory where the
+// data segments are loaded.
+
+var wasmMagic = []byte{0x00, 0x61, 0x73, 0x6d}
+
+// The section IDs used by the handler.
+const (
+	wasmSectionCustom = 0
+	wasmSectionImport = 2
+	wasmSectionCode   = 10
+	wasmSectionData   = 11
+)
+
+// The subsections of the name section.
+const (
+	wasmNameFunction    = 1
+	wasmNameDataSegment = 9
+)
+
+// wasmBuildIDSection is the custom section the Go linker writes the build
+// ID to.
+const wasmBuildIDSection = "go:buildid"
+
+var errInvalidWasm = errors.New("invalid WebAssembly module")
+
+type wasmSection struct {
+	id   byte
+	name string
+	data []byte
+}
+
+// wasmSegment is an active data segment.
+type wasmSegment struct {
+	name string
+	addr uint64
+	data []byte
+}
+
+func openWasm(r io.ReaderAt) (*wasmFile, error) {
+	ret := &wasmFile{reader: r}
+	if err := ret.parse(); err != nil {
+		return nil, fmt.Errorf("error when parsing the WebAssembly module: %w", err)
+	}
+	return ret, nil
+}
+
+var _ fileHandler = (*wasmFile)(nil)
+
+type wasmFile struct {
+	reader   io.ReaderAt
+	sections []wasmSection
+	code     []byte
+	segments []wasmSegment
+	symtab   map[string]Symbol
+	imports  []string
+}
+
+func (w *wasmFile) parse() error {
+	hdr := make([]byte, 8)
+	if _, err := w.reader.ReadAt(hdr, 0); err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(hdr, wasmMagic) || binary.LittleEndian.Uint32(hdr[4:]) != 1 {
+		return errInvalidWasm
+	}
+
+	for off := int64(len(hdr)); ; {
+		buf := make([]byte, 1+binary.MaxVarintLen32)
+		n, err := w.reader.ReadAt(buf, off)
+		if n == 0 && errors.Is(err, io.EOF) {
+			break
+		}
+		if n < 2 {
+			return errInvalidWasm
+		}
+		size, m := binary.Uvarint(buf[1:n])
+		if m <= 0 {
+			return errInvalidWasm
+		}
+		// The size is not trusted, so the buffer only grows with the data
+		// that can be read instead of being allocated up front.
+		if size > math.MaxInt64-uint64(off) {
+			return errInvalidWasm
+		}
+		data, err := io.ReadAll(io.NewSectionReader(w.reader, off+1+int64(m), int64(size)))
+		if err != nil || uint64(len(data)) != size {
+			return errInvalidWasm
+		}
+		s := wasmSection{id: buf[0], data: data}
+		if s.id == wasmSectionCustom {
+			name, l := readWasmName(data)
+			if l <= 0 {
+				return errInvalidWasm
+			}
+			s.name, s.data = name, data[l:]
+		}
+		w.sections = append(w.sections, s)
+		off += 1 + int64(m) + int64(size)
+	}
+
+	var importedFuncs uint64
+	for _, s := range w.sections {
+		switch s.id {
+		case wasmSectionImport:
+			importedFuncs = w.parseImports(s.data)
+		case wasmSectionCode:
+			w.code = s.data
+		case wasmSectionData:
+			w.segments = parseWasmSegments(s.data)
+		}
+	}
+
+	names, segmentNames := w.parseNames()
+	for i, name := range segmentNames {
+		if i < uint64(len(w.segments)) {
+			w.segments[i].name = name
+		}
+	}
+
+	// Imported functions have no body, so the indices of the bodies in the
+	// code section start after them.
+	w.symtab = make(map[string]Symbol)
+	for i, body := range wasmFunctionBodies(w.code) {
+		if name, ok := names[importedFuncs+uint64(i)]; ok {
+			w.symtab[name] = Symbol{Name: name, Value: body[0], Size: body[1] - body[0]}
+		}
+	}
+	// Passive segments are not loaded to a known address.
+	w.segments = slices.DeleteFunc(w.segments, func(s wasmSegment) bool { return s.data == nil })
+	sort.Slice(w.segments, func(i, j int) bool { return w.segments[i].addr < w.segments[j].addr })
+	return nil
+}
+
+// parseImports records the modules the functions are imported from and
+// returns the number of imported functions.
+func (w *wasmFile) parseImports(data []byte) uint64 {
+	count, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0
+	}
+	data = data[n:]
+	var funcs uint64
+	for i := uint64(0); i < count; i++ {
+		module, l := readWasmName(data)
+		if l <= 0 {
+			break
+		}
+		data = data[l:]
+		_, l = readWasmName(data)
+		if l <= 0 || l >= len(data) {
+			break
+		}
+		kind := data[l]
+		data = data[l+1:]
+
+		var ok bool
+		switch kind {
+		case 0: // function
+			funcs++
+			w.imports = append(w.imports, module)
+			data, ok = skipUvarints(data, 1)
+		case 1: // table
+			if len(data) == 0 {
+				return funcs
+			}
+			data, ok = skipWasmLimits(data[1:])
+		case 2: // memory
+			data, ok = skipWasmLimits(data)
+		case 3: // global
+			ok = len(data) >= 2
+			if ok {
+				data = data[2:]
+			}
+		case 4: // tag
+			if len(data) == 0 {
+				return funcs
+			}
+			data, ok = skipUvarints(data[1:], 1)
+		}
+		if !ok {
+			break
+		}
+	}
+	return funcs
+}
+
+// parseNames returns the function names and the data segment names of the
+// name section by their index.
+func (w *wasmFile) parseNames() (map[uint64]string, map[uint64]string) {
+	funcs := make(map[uint64]string)
+	segments := make(map[uint64]string)
+	data, err := w.customSection("name")
+	if err != nil {
+		return funcs, segments
+	}
+	for len(data) > 1 {
+		id := data[0]
+		size, n := binary.Uvarint(data[1:])
+		if n <= 0 || uint64(len(data)-1-n) < size {
+			break
+		}
+		sub := data[1+n : 1+n+int(size)]
+		data = data[1+n+int(size):]
+
+		var names map[uint64]string
+		switch id {
+		case wasmNameFunction:
+			names = funcs
+		case wasmNameDataSegment:
+			names = segments
+		default:
+			continue
+		}
+		count, n := binary.Uvarint(sub)
+		if n <= 0 {
+			continue
+		}
+		sub = sub[n:]
+		for i := uint64(0); i < count; i++ {
+			idx, n := binary.Uvarint(sub)
+			if n <= 0 {
+				break
+			}
+			name, l := readWasmName(sub[n:])
+			if l <= 0 {
+				break
+			}
+			names[idx] = name
+			sub = sub[n+l:]
+		}
+	}
+	return funcs, segments
+}
+
+// wasmFunctionBodies returns the start and the end of the function bodies
+// in the code section. The start is the offset after the size of the body.
+func wasmFunctionBodies(code []byte) [][2]uint64 {
+	count, n := binary.Uvarint(code)
+	if n <= 0 {
+		return nil
+	}
+	off := uint64(n)
+	var ret [][2]uint64
+	for i := uint64(0); i < count && off < uint64(len(code)); i++ {
+		size, n := binary.Uvarint(code[off:])
+		if n <= 0 {
+			break
+		}
+		start := off + uint64(n)
+		if size > uint64(len(code))-start {
+			break
+		}
+		ret = append(ret, [2]uint64{start, start + size})
+		off = start + size
+	}
+	return ret
+}
+
+// parseWasmSegments returns the data segments. The address is only known for
+// active segments that are initialized with a constant, the data of the
+// other segments is set to nil.
+func parseWasmSegments(data []byte) []wasmSegment {
+	count, n := binary.Uvarint(data)
+	if n <= 0 {
+		return nil
+	}
+	data = data[n:]
+	var ret []wasmSegment
+	for i := uint64(0); i < count; i++ {
+		flags, n := binary.Uvarint(data)
+		if n <= 0 {
+			break
+		}
+		data = data[n:]
+		if flags == 2 {
+			var ok bool
+			if data, ok = skipUvarints(data, 1); !ok {
+				break
+			}
+		}
+
+		addr, active := uint64(0), flags != 1
+		if active {
+			// The offset is an i32.const or i64.const instruction
+			// followed by end.
+			if len(data) < 2 || data[0] != 0x41 && data[0] != 0x42 {
+				break
+			}
+			v, n := readSLEB128(data[1:])
+			if n <= 0 || 1+n >= len(data) || data[1+n] != 0x0b {
+				break
+			}
+			addr = uint64(v)
+			data = data[2+n:]
+		}
+
+		size, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < size {
+			break
+		}
+		seg := wasmSegment{addr: addr}
+		if active {
+			seg.data = data[n : n+int(size)]
+		}
+		ret = append(ret, seg)
+		data = data[n+int(size):]
+	}
+	return ret
+}
+
+// readSLEB128 reads a signed LEB128 number. Like binary.Varint, it returns
+// the number of bytes read or zero on failure.
+func readSLEB128(b []byte) (int64, int) {
+	var v int64
+	var shift uint
+	for i, c := range b {
+		if i == binary.MaxVarintLen64 {
+			return 0, 0
+		}
+		v |= int64(c&0x7f) << shift
+		shift += 7
+		if c&0x80 == 0 {
+			if shift < 64 && c&0x40 != 0 {
+				v |= -1 << shift
+			}
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+func readWasmName(data []byte) (string, int) {
+	l, n := binary.Uvarint(data)
+	if n <= 0 || uint64(len(data)-n) < l {
+		return "", 0
+	}
+	return string(data[n : n+int(l)]), n + int(l)
+}
+
+func skipUvarints(data []byte, count int) ([]byte, bool) {
+	for i := 0; i < count; i++ {
+		_, n := binary.Uvarint(data)
+		if n <= 0 {
+			return data, false
+		}
+		data = data[n:]
+	}
+	return data, true
+}
+
+func skipWasmLimits(data []byte) ([]byte, bool) {
+	if len(data) == 0 {
+		return data, false
+	}
+	count := 1
+	if data[0]&1 != 0 {
+		count = 2
+	}
+	return skipUvarints(data[1:], count)
+}
+
+func (w *wasmFile) customSection(name string) ([]byte, error) {
+	for _, s := range w.sections {
+		if s.id == wasmSectionCustom && s.name == name {
+			return s.data, nil
+		}
+	}
+	return nil, ErrSectionDoesNotExist
+}
+
+func (w *wasmFile) getSymbol(name string) (Symbol, error) {
+	sym, ok := w.symtab[name]
+	if !ok {
+		return Symbol{}, ErrSymbolNotFound
+	}
+	return sym, nil
+}
+
+// getParsedFile returns nil, the module is parsed by the handler.
+func (w *wasmFile) getParsedFile() any {
+	return nil
+}
+
+func (w *wasmFile) getReader() io.ReaderAt {
+	return w.reader
+}
+
+func (w *wasmFile) Close() error {
+	return tryClose(w.reader)
+}
+
+func (w *wasmFile) getRData() ([]byte, error) {
+	_, data, err := w.getSectionData(".rodata")
+	if err == nil {
+		return data, nil
+	}
+	// Without the segment names, all the data is searched.
+	var ret []byte
+	for _, seg := range w.segments {
+		ret = append(ret, seg.data...)
+	}
+	if ret == nil {
+		return nil, ErrSectionDoesNotExist
+	}
+	return ret, nil
+}
+
+func (w *wasmFile) getCodeSection() (uint64, []byte, error) {
+	if w.code == nil {
+		return 0, nil, ErrSectionDoesNotExist
+	}
+	return 0, w.code, nil
+}
+
+// getSectionDataFromAddress returns the data segment the address of the
+// linear memory is in.
+func (w *wasmFile) getSectionDataFromAddress(address uint64) (uint64, []byte, error) {
+	for _, seg := range w.segments {
+		if seg.addr <= address && address < seg.addr+uint64(len(seg.data)) {
+			return seg.addr, seg.data, nil
+		}
+	}
+	return 0, nil, ErrSectionDoesNotExist
+}
+
+// getSectionData returns a named data segment or a custom section. The
+// custom sections are not loaded to memory, so their address is zero.
+func (w *wasmFile) getSectionData(s string) (uint64, []byte, error) {
+	for _, seg := range w.segments {
+		if seg.name == s {
+			return seg.addr, seg.data, nil
+		}
+	}
+	data, err := w.customSection(s)
+	return 0, data, err
+}
+
+func (w *wasmFile) getFileInfo() *FileInfo {
+	fi := &FileInfo{
+		ByteOrder: binary.LittleEndian,
+		WordSize:  intSize32,
+		Arch:      ArchWasm,
+		OS:        "js",
+	}
+	for _, module := range w.imports {
+		if strings.HasPrefix(module, "wasi_") {
+			fi.OS = "wasip1"
+			break
+		}
+	}
+	return fi
+}
+
+func (w *wasmFile) getPCLNTABData() (uint64, []byte, error) {
+	return 0, nil, ErrNoPCLNTab
+}
+
+func (w *wasmFile) moduledataSection() string {
+	return ".data"
+}
+
+func (w *wasmFile) getBuildID() (string, error) {
+	data, err := w.customSection(wasmBuildIDSection)
+	if err != nil {
+		return "", nil
+	}
+	return string(data), nil
+}
+
+func (w *wasmFile) getDwarf() (*dwarf.Data, error) {
+	section := func(name string) []byte {
+		data, _ := w.customSection(".debug_" + name)
+		return data
+	}
+	d, err := dwarf.New(section("abbrev"), nil, nil, section("info"), section("line"), nil, section("ranges"), section("str"))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"addr", "line_str", "str_offsets", "rnglists"} {
+		if data := section(name); data != nil {
+			if err := d.AddSection(".debug_"+name, data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return d, nil
+}
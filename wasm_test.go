@@ -0,0 +1,169 @@
+// This file is part of GoRE.
+//
+// Copyright (C) 2019-2024 GoRE Authors
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func wasmName(s string) []byte {
+	return append(binary.AppendUvarint(nil, uint64(len(s))), s...)
+}
+
+func wasmSectionBytes(id byte, payload []byte) []byte {
+	return append(binary.AppendUvarint([]byte{id}, uint64(len(payload))), payload...)
+}
+
+func wasmNameMap(names ...string) []byte {
+	b := binary.AppendUvarint(nil, uint64(len(names)))
+	for i, name := range names {
+		b = binary.AppendUvarint(b, uint64(i))
+		b = append(b, wasmName(name)...)
+	}
+	return b
+}
+
+// buildWasmModule builds a module with an imported function, the functions
+// with the names and bodies of the size, and a .rodata segment at 0x10000.
+func buildWasmModule(t *testing.T, names []string, rodata []byte) []byte {
+	t.Helper()
+	mod := append([]byte{}, wasmMagic...)
+	mod = binary.LittleEndian.AppendUint32(mod, 1)
+
+	imports := binary.AppendUvarint(nil, 1)
+	imports = append(imports, wasmName("wasi_snapshot_preview1")...)
+	imports = append(imports, wasmName("proc_exit")...)
+	imports = append(imports, 0, 0)
+	mod = append(mod, wasmSectionBytes(wasmSectionImport, imports)...)
+
+	code := binary.AppendUvarint(nil, uint64(len(names)))
+	for i := range names {
+		body := make([]byte, 4+i)
+		body[len(body)-1] = 0x0b
+		code = append(binary.AppendUvarint(code, uint64(len(body))), body...)
+	}
+	mod = append(mod, wasmSectionBytes(wasmSectionCode, code)...)
+
+	data := binary.AppendUvarint(nil, 2)
+	// An active segment at 0x10000 and a passive segment.
+	data = append(data, 0, 0x41, 0x80, 0x80, 0x04, 0x0b)
+	data = append(binary.AppendUvarint(data, uint64(len(rodata))), rodata...)
+	data = append(data, 1, 2, 0xaa, 0xbb)
+	mod = append(mod, wasmSectionBytes(wasmSectionData, data)...)
+
+	// The imported function has the index 0.
+	nameSec := wasmName("name")
+	funcNames := wasmNameMap(append([]string{"proc_exit"}, names...)...)
+	nameSec = append(nameSec, wasmSectionBytes(wasmNameFunction, funcNames)...)
+	nameSec = append(nameSec, wasmSectionBytes(wasmNameDataSegment, wasmNameMap(".rodata", ".data"))...)
+	mod = append(mod, wasmSectionBytes(wasmSectionCustom, nameSec)...)
+
+	return append(mod, wasmSectionBytes(wasmSectionCustom, append(wasmName(wasmBuildIDSection), "abc/def"...))...)
+}
+
+func TestOpenWasm(t *testing.T) {
+	rodata := []byte("hello go1.21.5 world")
+	mod := buildWasmModule(t, []string{"main.main", "runtime.initAll", "memcpy"}, rodata)
+
+	f, err := OpenReader(bytes.NewReader(mod))
+	require.NoError(t, err)
+	defer f.Close()
+
+	assert.Equal(t, ArchWasm, f.FileInfo.Arch)
+	assert.Equal(t, "wasip1", f.FileInfo.OS)
+	assert.Equal(t, intSize32, f.FileInfo.WordSize)
+	assert.Equal(t, CompilerTinyGo, f.FileInfo.Compiler)
+	assert.Equal(t, "abc/def", f.BuildID)
+
+	sym, err := f.fh.getSymbol("runtime.initAll")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), sym.Size)
+	_, code, err := f.fh.getCodeSection()
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x0b), code[sym.Value+sym.Size-1])
+	_, err = f.fh.getSymbol("proc_exit")
+	assert.ErrorIs(t, err, ErrSymbolNotFound)
+
+	addr, data, err := f.fh.getSectionData(".rodata")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0x10000), addr)
+	assert.Equal(t, rodata, data)
+	b, err := f.Bytes(0x10006, 8)
+	require.NoError(t, err)
+	assert.Equal(t, "go1.21.5", string(b))
+	_, _, err = f.fh.getSectionDataFromAddress(0x100)
+	assert.ErrorIs(t, err, ErrSectionDoesNotExist)
+
+	ver, err := f.GetCompilerVersion()
+	require.NoError(t, err)
+	assert.Equal(t, "go1.21.5", ver.Name)
+
+	_, err = f.Moduledata()
+	assert.ErrorIs(t, err, ErrNoModuledata)
+	// The data segments have no symbols, so the type descriptors can't be
+	// found.
+	_, err = f.GetTypes()
+	assert.ErrorIs(t, err, errNoTypeDescriptors)
+
+	pkgs, err := f.GetPackages()
+	require.NoError(t, err)
+	require.Len(t, pkgs, 1)
+	assert.Equal(t, "main", pkgs[0].Name)
+	require.Len(t, pkgs[0].Functions, 1)
+	assert.Equal(t, "main", pkgs[0].Functions[0].Name)
+}
+
+func TestOpenWasmInvalid(t *testing.T) {
+	mod := buildWasmModule(t, []string{"main.main"}, []byte("data"))
+	_, err := OpenReader(bytes.NewReader(mod[:len(mod)-3]))
+	assert.ErrorIs(t, err, errInvalidWasm)
+
+	// A section claiming about 32 GiB must not be allocated.
+	hdr := binary.LittleEndian.AppendUint32(append([]byte{}, wasmMagic...), 1)
+	_, err = OpenReader(bytes.NewReader(append(hdr, wasmSectionCode, 0xff, 0xff, 0xff, 0xff, 0x7f)))
+	assert.ErrorIs(t, err, errInvalidWasm)
+}
+
+func TestWasmFunctionBodiesOverflow(t *testing.T) {
+	// One body whose size wraps around when added to its start.
+	code := binary.AppendUvarint([]byte{1}, ^uint64(0))
+	assert.Empty(t, wasmFunctionBodies(code))
+}
+
+func TestReadSLEB128(t *testing.T) {
+	for _, test := range []struct {
+		data []byte
+		v    int64
+		n    int
+	}{
+		{[]byte{0x02}, 2, 1},
+		{[]byte{0x7e}, -2, 1},
+		{[]byte{0x80, 0x80, 0x04}, 0x10000, 3},
+		{[]byte{0x80, 0x7f}, -128, 2},
+		{[]byte{0x80}, 0, 0},
+	} {
+		v, n := readSLEB128(test.data)
+		assert.Equal(t, test.v, v)
+		assert.Equal(t, test.n, n)
+	}
+}